@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/mailer"
+	authrepo "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/repo/auth"
+	emailsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/repo/emails"
+	authusecase "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/usecase/auth"
+	emailsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/usecase/emails"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/worker"
+)
+
+func startWorker(cfg *config.Config) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	interval, err := time.ParseDuration(cfg.Worker.Interval)
+	if err != nil {
+		return err
+	}
+	leaderTTL, err := time.ParseDuration(cfg.Worker.LeaderTTL)
+	if err != nil {
+		return err
+	}
+	emailBackoff, err := time.ParseDuration(cfg.Worker.DeliverEmails.Backoff)
+	if err != nil {
+		return err
+	}
+
+	// connect the db
+	db, err := config.DBConnect(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("DB connected")
+
+	// connect redis
+	redisCli, err := config.RedisConnect(cfg)
+	if err != nil {
+		return err
+	}
+	log.Println("Redis connected")
+
+	// you can insert your behaviors here
+	authRepo, err := authrepo.New(db)
+	if err != nil {
+		return err
+	}
+	emailsRepo, err := emailsrepo.New(db)
+	if err != nil {
+		return err
+	}
+	authUsecase := authusecase.NewAuthUsecase(authRepo)
+	m := mailer.New(cfg.Mail.Server, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.App.Sender.String())
+	emailsUsecase := emailsusecase.NewEmailsUsecase(emailsRepo, m, &cfg.App,
+		cfg.Worker.DeliverEmails.MaxAttempts, emailBackoff)
+
+	w := worker.New(worker.NewLeader(redisCli, cfg.Worker.LeaderKey, leaderTTL), interval,
+		deliverEmailsJob(emailsUsecase, cfg.Worker.BatchSize),
+		purgeRefreshTokensJob(authUsecase),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Worker started")
+	w.Run(ctx)
+	log.Println("Worker stopped")
+
+	return nil
+}
+
+func deliverEmailsJob(uc *emailsusecase.EmailsUsecase, limit int) worker.Job {
+	return worker.Job{
+		Name: "deliver emails",
+		Run: func(ctx context.Context) error {
+			sent, err := uc.DeliverEmails(ctx, limit)
+			if sent > 0 {
+				log.Printf("%d emails delivered", sent)
+			}
+			return err
+		},
+	}
+}
+
+func purgeRefreshTokensJob(uc *authusecase.AuthUsecase) worker.Job {
+	return worker.Job{
+		Name: "purge refresh tokens",
+		Run: func(ctx context.Context) error {
+			purged, err := uc.PurgeRefreshTokens(ctx)
+			if purged > 0 {
+				log.Printf("%d expired refresh tokens purged", purged)
+			}
+			return err
+		},
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"time"
 
@@ -9,11 +10,15 @@ import (
 )
 
 type Config struct {
-	Server   Server   `yaml:"server"`
-	Database Database `yaml:"database"`
-	Redis    Redis    `yaml:"redis"`
-	JWT      JWT      `yaml:"jwt"`
-	Mail     Mail     `yaml:"mail"`
+	App           App           `yaml:"app"`
+	Server        Server        `yaml:"server"`
+	Database      Database      `yaml:"database"`
+	Redis         Redis         `yaml:"redis"`
+	JWT           JWT           `yaml:"jwt"`
+	LoginThrottle LoginThrottle `yaml:"login_throttle"`
+	MFA           MFA           `yaml:"mfa"`
+	Mail          Mail          `yaml:"mail"`
+	Worker        Worker        `yaml:"worker"`
 }
 
 func New() (*Config, error) {
@@ -42,6 +47,10 @@ func New() (*Config, error) {
 		return nil, parseFileErr
 	}
 
+	if parseNetworkErr := cfg.parseNetwork(); parseNetworkErr != nil {
+		return nil, parseNetworkErr
+	}
+
 	return &cfg, nil
 }
 
@@ -60,6 +69,41 @@ func (cfg *Config) parseDuration() error {
 
 	cfg.JWT.RefreshExpires = refreshExpired
 
+	window, err := time.ParseDuration(cfg.LoginThrottle.Window)
+	if err != nil {
+		return err
+	}
+
+	cfg.LoginThrottle.WindowDuration = window
+
+	baseDelay, err := time.ParseDuration(cfg.LoginThrottle.BaseDelay)
+	if err != nil {
+		return err
+	}
+
+	cfg.LoginThrottle.BaseDelayDuration = baseDelay
+
+	maxDelay, err := time.ParseDuration(cfg.LoginThrottle.MaxDelay)
+	if err != nil {
+		return err
+	}
+
+	cfg.LoginThrottle.MaxDelayDuration = maxDelay
+
+	lockout, err := time.ParseDuration(cfg.LoginThrottle.Lockout)
+	if err != nil {
+		return err
+	}
+
+	cfg.LoginThrottle.LockoutDuration = lockout
+
+	mfaTokenExpired, err := time.ParseDuration(cfg.MFA.TokenExpired)
+	if err != nil {
+		return err
+	}
+
+	cfg.MFA.TokenExpires = mfaTokenExpired
+
 	return nil
 }
 
@@ -80,3 +124,25 @@ func (cfg *Config) parseFile() error {
 
 	return nil
 }
+
+// parseNetwork read the trusted proxies, each one is a cidr or a single ip
+func (cfg *Config) parseNetwork() error {
+	for _, proxy := range cfg.Server.HTTP.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+
+		cfg.Server.HTTP.TrustedNets = append(cfg.Server.HTTP.TrustedNets, ipNet)
+	}
+
+	return nil
+}
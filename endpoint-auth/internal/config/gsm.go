@@ -55,6 +55,7 @@ func (cfg *Config) loadFromGsm() error {
 
 	cfg.Mail.Password = string(mailpassword)
 	cfg.JWT.SecretKey = string(secretkey)
+	cfg.MFA.EncryptionKey = data.EncryptionKey
 	cfg.Database.MasterDsn = fmt.Sprintf(cfg.Database.MasterDsnNoCred, pgtalkuser, pgtalkpassword)
 	cfg.Database.FollowerDsn = fmt.Sprintf(cfg.Database.FollowerDsnNoCred, pgtalkuser, pgtalkpassword)
 
@@ -1,15 +1,76 @@
 package config
 
-import "time"
+import (
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// App is how the service is seen from outside, every email is built from it
+// so the links point to the right site on each stage
+type App struct {
+	PublicBaseURL string                    `yaml:"public_base_url"`
+	Frontend      Frontend                  `yaml:"frontend"`
+	Sender        Sender                    `yaml:"sender"`
+	DefaultLocale string                    `yaml:"default_locale"`
+	Locales       map[string]EmailTemplates `yaml:"locales"`
+}
+
+// Frontend holds the url templates of the pages linked from the emails,
+// {base_url} is replaced with the public base url and {token} with the reference id
+type Frontend struct {
+	ConfirmURL        string `yaml:"confirm_url"`
+	PasswordResetURL  string `yaml:"password_reset_url"`
+	ForgotPasswordURL string `yaml:"forgot_password_url"`
+}
+
+type Sender struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
+}
+
+// EmailTemplates is the email of every event in one locale, keyed by the event
+type EmailTemplates map[string]EmailTemplate
+
+type EmailTemplate struct {
+	Subject  string `yaml:"subject"`
+	Template string `yaml:"template"`
+}
+
+// Link fill the url template with the public base url and the token
+func (a *App) Link(urlTemplate, token string) string {
+	return strings.NewReplacer("{base_url}", strings.TrimSuffix(a.PublicBaseURL, "/"), "{token}", token).Replace(urlTemplate)
+}
+
+// Email return the email of the event in the locale, the default locale
+// is used when the locale or the event in it is not configured
+func (a *App) Email(locale, event string) (EmailTemplate, bool) {
+	if t, ok := a.Locales[locale][event]; ok {
+		return t, true
+	}
+	t, ok := a.Locales[a.DefaultLocale][event]
+
+	return t, ok
+}
+
+// String format the sender for the From header, e.g. "Warung Pintar <dont-reply@example.com>"
+func (s Sender) String() string {
+	return (&mail.Address{Name: s.Name, Address: s.Address}).String()
+}
 
 type Server struct {
 	HTTP HTTP `yaml:"http"`
 }
 
+// HTTP is the listener of the service, the ip of the client is only taken from the
+// forwarded headers when the request comes from one of the trusted_proxies
 type HTTP struct {
-	Address      string `yaml:"address"`
-	ReadTimeout  string `yaml:"read_timeout"`
-	WriteTimeout string `yaml:"write_timeout"`
+	Address        string   `yaml:"address"`
+	ReadTimeout    string   `yaml:"read_timeout"`
+	WriteTimeout   string   `yaml:"write_timeout"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	TrustedNets    []*net.IPNet
 }
 
 type JWT struct {
@@ -23,6 +84,16 @@ type JWT struct {
 	SecretKey      string
 }
 
+// MFA is the totp second factor, a login of a user with it enabled waits for the code
+// with an mfa token living for token_expired. EncryptionKey encrypts the totp secrets
+type MFA struct {
+	Issuer        string `yaml:"issuer"`
+	TokenExpired  string `yaml:"token_expired"`
+	RecoveryCodes int    `yaml:"recovery_codes"`
+	TokenExpires  time.Duration
+	EncryptionKey string
+}
+
 type Database struct {
 	Driver          string `yaml:"driver"`
 	MaxOpenConns    string `yaml:"max_open_conns"`
@@ -50,3 +121,37 @@ type Mail struct {
 	Username string `yaml:"username"`
 	Password string
 }
+
+// LoginThrottle is how the failed logins are limited. Every failure of an email after
+// free_attempts delays its next login, starting at base_delay and doubling up to max_delay,
+// the email or the ip is locked for lockout after reaching its threshold. The failures
+// are forgotten when there is none for a window
+type LoginThrottle struct {
+	Window         string `yaml:"window"`
+	FreeAttempts   int    `yaml:"free_attempts"`
+	BaseDelay      string `yaml:"base_delay"`
+	MaxDelay       string `yaml:"max_delay"`
+	EmailThreshold int    `yaml:"email_threshold"`
+	IpThreshold    int    `yaml:"ip_threshold"`
+	Lockout        string `yaml:"lockout"`
+
+	WindowDuration    time.Duration
+	BaseDelayDuration time.Duration
+	MaxDelayDuration  time.Duration
+	LockoutDuration   time.Duration
+}
+
+type Worker struct {
+	Interval      string        `yaml:"interval"`
+	LeaderKey     string        `yaml:"leader_key"`
+	LeaderTTL     string        `yaml:"leader_ttl"`
+	BatchSize     int           `yaml:"batch_size"`
+	DeliverEmails DeliverEmails `yaml:"deliver_emails"`
+}
+
+// DeliverEmails is how the outbox is retried when the smtp server fails, the wait
+// before the next attempt starts at backoff and doubles on every failure
+type DeliverEmails struct {
+	MaxAttempts int    `yaml:"max_attempts"`
+	Backoff     string `yaml:"backoff"`
+}
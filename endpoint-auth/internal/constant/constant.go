@@ -2,9 +2,16 @@ package constant
 
 const (
 	FailedParseBody = "Invalid input type."
+	FailedSaveData  = "Failed to save the data, please try again."
 	UserNotFound    = "User not found."
 	AlreadyTaken    = "The %s has already been taken."
 	PrivilegesOnly  = "Only users with %s privileges can do this action."
+	TokenRevoked    = "Token is revoked."
+	TokenReused     = "Token has been used, please login again."
+	InvalidMfaCode  = "Invalid two-factor authentication code."
+	// TokenVersionKey is the redis key of the current token version of the user,
+	// shared with the other services
+	TokenVersionKey = "auth:token_version:%v"
 	// Key Response
 	Header = "_header"
 	Body   = "_body"
@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
-	"github.com/creent-production/cdk-go/auth"
-	"github.com/creent-production/cdk-go/mail"
 	"github.com/creent-production/cdk-go/parser"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/go-chi/chi/v5"
@@ -18,29 +18,36 @@ import (
 )
 
 type authUsecaseIface interface {
-	Register(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonRegisterSchema, m *mail.Mail)
-	UserConfirm(ctx context.Context, rw http.ResponseWriter, token string, cfg *config.Config)
-	ResendEmail(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonEmailSchema, m *mail.Mail)
-	Login(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonLoginSchema, cfg *config.Config)
-	FreshToken(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonPasswordOnlySchema, cfg *config.Config)
-	RefreshToken(ctx context.Context, rw http.ResponseWriter, cfg *config.Config)
+	Register(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonRegisterSchema, locale string)
+	UserConfirm(ctx context.Context, rw http.ResponseWriter, token string, session *authentity.Session, redisCli *redis.Pool, cfg *config.Config)
+	ResendEmail(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonEmailSchema, locale string)
+	Login(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonLoginSchema, session *authentity.Session, locale string, redisCli *redis.Pool, cfg *config.Config)
+	LoginUnlock(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonLoginUnlockSchema, redisCli *redis.Pool)
+	FreshToken(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonPasswordOnlySchema, redisCli *redis.Pool, cfg *config.Config)
+	RefreshToken(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, cfg *config.Config)
 	AccessRevoke(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, cfg *config.Config)
 	RefreshRevoke(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, cfg *config.Config)
-	PasswordResetSend(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonEmailSchema, m *mail.Mail)
-	PasswordReset(ctx context.Context, rw http.ResponseWriter, token string, payload *authentity.JsonPasswordResetSchema)
-	UpdatePassword(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonUpdatePasswordSchema)
+	PasswordResetSend(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonEmailSchema, locale string)
+	PasswordReset(ctx context.Context, rw http.ResponseWriter, token string, payload *authentity.JsonPasswordResetSchema, redisCli *redis.Pool, cfg *config.Config)
+	UpdatePassword(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonUpdatePasswordSchema, session *authentity.Session, redisCli *redis.Pool, cfg *config.Config)
 	UpdateAvatar(ctx context.Context, rw http.ResponseWriter, file *multipart.Form)
 	UpdateAccount(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonUpdateAccountSchema)
 	GetUser(ctx context.Context, rw http.ResponseWriter)
+	MfaEnroll(ctx context.Context, rw http.ResponseWriter, cfg *config.Config)
+	MfaVerify(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonMfaCodeSchema, redisCli *redis.Pool, cfg *config.Config)
+	MfaLogin(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonMfaLoginSchema, session *authentity.Session, locale string, redisCli *redis.Pool, cfg *config.Config)
+	GetSessions(ctx context.Context, rw http.ResponseWriter)
+	RevokeSession(ctx context.Context, rw http.ResponseWriter, sessionId string, redisCli *redis.Pool, cfg *config.Config)
+	RevokeAllSessions(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, cfg *config.Config)
 }
 
-func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.Config, m *mail.Mail) {
+func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.Config) {
 	r.Route("/auth", func(r chi.Router) {
 		// protected route
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtFreshRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtFreshRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -60,14 +67,29 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 					return
 				}
 
-				uc.UpdatePassword(r.Context(), rw, &p)
+				uc.UpdatePassword(r.Context(), rw, &p, newSession(r), redisCli, cfg)
+			})
+			r.Post("/2fa/enroll", func(rw http.ResponseWriter, r *http.Request) {
+				uc.MfaEnroll(r.Context(), rw, cfg)
+			})
+			r.Post("/2fa/verify", func(rw http.ResponseWriter, r *http.Request) {
+				var p authentity.JsonMfaCodeSchema
+
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.MfaVerify(r.Context(), rw, &p, redisCli, cfg)
 			})
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -112,17 +134,40 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 					return
 				}
 
-				uc.FreshToken(r.Context(), rw, &p, cfg)
+				uc.FreshToken(r.Context(), rw, &p, redisCli, cfg)
 			})
 			r.Delete("/access-revoke", func(rw http.ResponseWriter, r *http.Request) {
 				uc.AccessRevoke(r.Context(), rw, redisCli, cfg)
 			})
+			r.Get("/sessions", func(rw http.ResponseWriter, r *http.Request) {
+				uc.GetSessions(r.Context(), rw)
+			})
+			r.Delete("/sessions", func(rw http.ResponseWriter, r *http.Request) {
+				uc.RevokeAllSessions(r.Context(), rw, redisCli, cfg)
+			})
+			r.Delete("/sessions/{session_id}", func(rw http.ResponseWriter, r *http.Request) {
+				sessionId, _ := parser.ParsePathToStr("/auth/sessions/(.*)", r.URL.Path)
+
+				uc.RevokeSession(r.Context(), rw, sessionId, redisCli, cfg)
+			})
+			r.Post("/login-unlock", func(rw http.ResponseWriter, r *http.Request) {
+				var p authentity.JsonLoginUnlockSchema
+
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.LoginUnlock(r.Context(), rw, &p, redisCli)
+			})
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtRefreshRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtRefreshRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -133,7 +178,7 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				})
 			})
 			r.Post("/refresh-token", func(rw http.ResponseWriter, r *http.Request) {
-				uc.RefreshToken(r.Context(), rw, cfg)
+				uc.RefreshToken(r.Context(), rw, redisCli, cfg)
 			})
 			r.Delete("/refresh-revoke", func(rw http.ResponseWriter, r *http.Request) {
 				uc.RefreshRevoke(r.Context(), rw, redisCli, cfg)
@@ -150,12 +195,12 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				return
 			}
 
-			uc.Register(r.Context(), rw, &p, m)
+			uc.Register(r.Context(), rw, &p, acceptLanguage(r))
 		})
 		r.Get("/confirm/{token}", func(rw http.ResponseWriter, r *http.Request) {
 			token, _ := parser.ParsePathToStr("/auth/confirm/(.*)", r.URL.Path)
 
-			uc.UserConfirm(r.Context(), rw, token, cfg)
+			uc.UserConfirm(r.Context(), rw, token, newSession(r), redisCli, cfg)
 		})
 		r.Post("/resend-email", func(rw http.ResponseWriter, r *http.Request) {
 			var p authentity.JsonEmailSchema
@@ -167,7 +212,7 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				return
 			}
 
-			uc.ResendEmail(r.Context(), rw, &p, m)
+			uc.ResendEmail(r.Context(), rw, &p, acceptLanguage(r))
 		})
 		r.Post("/login", func(rw http.ResponseWriter, r *http.Request) {
 			var p authentity.JsonLoginSchema
@@ -179,7 +224,19 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				return
 			}
 
-			uc.Login(r.Context(), rw, &p, cfg)
+			uc.Login(r.Context(), rw, &p, newSession(r), acceptLanguage(r), redisCli, cfg)
+		})
+		r.Post("/2fa/login", func(rw http.ResponseWriter, r *http.Request) {
+			var p authentity.JsonMfaLoginSchema
+
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+					constant.Body: constant.FailedParseBody,
+				})
+				return
+			}
+
+			uc.MfaLogin(r.Context(), rw, &p, newSession(r), acceptLanguage(r), redisCli, cfg)
 		})
 		r.Post("/password-reset/send", func(rw http.ResponseWriter, r *http.Request) {
 			var p authentity.JsonEmailSchema
@@ -191,7 +248,7 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				return
 			}
 
-			uc.PasswordResetSend(r.Context(), rw, &p, m)
+			uc.PasswordResetSend(r.Context(), rw, &p, acceptLanguage(r))
 		})
 		r.Put("/password-reset/{token}", func(rw http.ResponseWriter, r *http.Request) {
 			token, _ := parser.ParsePathToStr("/auth/password-reset/(.*)", r.URL.Path)
@@ -205,7 +262,35 @@ func AddAuth(r *chi.Mux, uc authUsecaseIface, redisCli *redis.Pool, cfg *config.
 				return
 			}
 
-			uc.PasswordReset(r.Context(), rw, token, &p)
+			uc.PasswordReset(r.Context(), rw, token, &p, redisCli, cfg)
 		})
 	})
 }
+
+// acceptLanguage return the primary language of the most preferred tag in the
+// Accept-Language header, e.g. "id" for "id-ID,id;q=0.9,en;q=0.8"
+func acceptLanguage(r *http.Request) string {
+	tag := strings.Split(r.Header.Get("Accept-Language"), ",")[0]
+	tag = strings.Split(strings.Split(tag, ";")[0], "-")[0]
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) > 10 || tag == "*" {
+		return ""
+	}
+
+	return tag
+}
+
+// newSession describe the device of the request, the ip is set by RealIP
+func newSession(r *http.Request) *authentity.Session {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	// the column counts characters, cutting the bytes could split a multibyte one
+	device := []rune(r.UserAgent())
+	if len(device) > 255 {
+		device = device[:255]
+	}
+
+	return &authentity.Session{Device: string(device), Ip: ip}
+}
@@ -0,0 +1,41 @@
+package endpoint_http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
+	"github.com/creent-production/cdk-go/auth"
+	"github.com/go-chi/jwtauth"
+	"github.com/gomodule/redigo/redis"
+)
+
+// validateJWT check the token like auth.ValidateJWT, a token issued before the
+// password of the user changed is revoked too
+func validateJWT(ctx context.Context, redisCli *redis.Pool, typeJWT string) error {
+	if err := auth.ValidateJWT(ctx, redisCli, typeJWT); err != nil {
+		return err
+	}
+
+	_, claims, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		// optional token is not given
+		return nil
+	}
+
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	// the key only exists for a while after the password changed
+	version, err := redis.Int(conn.Do("GET", fmt.Sprintf(constant.TokenVersionKey, claims["sub"])))
+	if err != nil {
+		return nil
+	}
+	// json numbers of the claims are float64, a token without the claim is version 0
+	if ver, _ := claims["ver"].(float64); int(ver) < version {
+		return errors.New("token is revoked")
+	}
+
+	return nil
+}
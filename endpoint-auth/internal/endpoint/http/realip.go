@@ -0,0 +1,65 @@
+package endpoint_http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP set the RemoteAddr to the ip of the client sent by the proxy in the
+// X-Forwarded-For or X-Real-IP header. Anyone can write the headers, so they are
+// only read when the request comes from one of the trusted proxies, otherwise
+// the address of the connection is kept
+func RealIP(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIp(r, trustedProxies); len(ip) > 0 {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// forwardedIp walk the X-Forwarded-For from the closest hop and take the first one
+// that is not a trusted proxy, the hops further than it could be written by the client
+func forwardedIp(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(net.ParseIP(host), trustedProxies) {
+		return ""
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			}
+			if i == 0 || !isTrusted(ip, trustedProxies) {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+func isTrusted(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
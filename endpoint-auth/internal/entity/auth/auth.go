@@ -21,8 +21,23 @@ type JsonLoginSchema struct {
 	Password string `json:"password" validate:"required,min=6,max=100" db:"password"`
 }
 
+type JsonLoginUnlockSchema struct {
+	Email string `json:"email" validate:"required,email,min=3,max=100"`
+	Ip    string `json:"ip" validate:"omitempty,max=45"`
+}
+
 type JsonPasswordOnlySchema struct {
 	Password string `validate:"required,min=6,max=100"`
+	Code     string `validate:"omitempty,min=6,max=20"`
+}
+
+type JsonMfaCodeSchema struct {
+	Code string `json:"code" validate:"required,min=6,max=20"`
+}
+
+type JsonMfaLoginSchema struct {
+	MfaToken string `json:"mfa_token" validate:"required,max=100"`
+	Code     string `json:"code" validate:"required,min=6,max=20"`
 }
 
 type JsonPasswordResetSchema struct {
@@ -44,16 +59,19 @@ type JsonUpdateAccountSchema struct {
 }
 
 type User struct {
-	Id        int         `json:"id" db:"id"`
-	Fullname  null.String `json:"fullname" db:"fullname"`
-	Email     string      `json:"email" db:"email"`
-	Password  string      `json:"-" db:"password"`
-	Phone     null.String `json:"phone" db:"phone"`
-	Address   null.String `json:"address" db:"address"`
-	Role      string      `json:"role" db:"role"`
-	Avatar    string      `json:"avatar" db:"avatar"`
-	CreatedAt time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+	Id           int         `json:"id" db:"id"`
+	Fullname     null.String `json:"fullname" db:"fullname"`
+	Email        string      `json:"email" db:"email"`
+	Password     string      `json:"-" db:"password"`
+	Phone        null.String `json:"phone" db:"phone"`
+	Address      null.String `json:"address" db:"address"`
+	Role         string      `json:"role" db:"role"`
+	Avatar       string      `json:"avatar" db:"avatar"`
+	TokenVersion int         `json:"-" db:"token_version"`
+	TotpSecret   null.String `json:"-" db:"totp_secret"`
+	TotpEnabled  bool        `json:"totp_enabled" db:"totp_enabled"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 type UserConfirm struct {
@@ -69,3 +87,40 @@ type PasswordReset struct {
 	ResendExpired time.Time `json:"resend_expired" db:"resend_expired"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 }
+
+// RefreshToken is a signed refresh token, Id is the jti of it. Every rotation adds a
+// token to the family of the session, the used one can not be presented again
+type RefreshToken struct {
+	Id        string    `json:"id" db:"id"`
+	FamilyId  string    `json:"family_id" db:"family_id"`
+	UserId    int       `json:"user_id" db:"user_id"`
+	UsedAt    null.Time `json:"used_at" db:"used_at"`
+	Revoked   bool      `json:"revoked" db:"revoked"`
+	ExpiredAt time.Time `json:"expired_at" db:"expired_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Session is a login of the user on a device, the refresh tokens rotated from
+// the login are the family of it
+type Session struct {
+	Id         string    `json:"id" db:"id"`
+	UserId     int       `json:"-" db:"user_id"`
+	Device     string    `json:"device" db:"device"`
+	Ip         string    `json:"ip" db:"ip"`
+	Revoked    bool      `json:"-" db:"revoked"`
+	Current    bool      `json:"current" db:"-"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"-" db:"updated_at"`
+}
+
+// RecoveryCode let the user pass the second factor without the totp app, once.
+// Only the sha256 of the code is kept
+type RecoveryCode struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    int       `json:"user_id" db:"user_id"`
+	CodeHash  string    `json:"-" db:"code_hash"`
+	UsedAt    null.Time `json:"used_at" db:"used_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
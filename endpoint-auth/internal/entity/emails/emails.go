@@ -0,0 +1,35 @@
+package emails
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	EventEmailConfirm  = "email_confirm"
+	EventPasswordReset = "password_reset"
+	EventLoginLocked   = "login_locked"
+
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Email is waiting in the outbox to be delivered by the worker, ReferenceId is the
+// confirmation or the password reset the link of the email points to, empty when the
+// link needs none, and Locale is the language asked by the user, empty for the default one
+type Email struct {
+	Id            int         `json:"id" db:"id"`
+	Event         string      `json:"event" db:"event"`
+	Recipient     string      `json:"recipient" db:"recipient"`
+	ReferenceId   string      `json:"reference_id" db:"reference_id"`
+	Locale        string      `json:"locale" db:"locale"`
+	Status        string      `json:"status" db:"status"`
+	Attempts      int         `json:"attempts" db:"attempts"`
+	LastError     null.String `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time   `json:"next_attempt_at" db:"next_attempt_at"`
+	SentAt        null.Time   `json:"sent_at" db:"sent_at"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mailer send the account emails (confirmation, password reset and login locked)
+// through the smtp server, unlike mail.Mail of cdk-go the failure is returned so
+// the outbox can try again later
+type Mailer struct {
+	dialer *gomail.Dialer
+	sender string
+}
+
+func New(server string, port int, username, password, sender string) *Mailer {
+	return &Mailer{
+		dialer: gomail.NewDialer(server, port, username, password),
+		sender: sender,
+	}
+}
+
+func (m *Mailer) Send(to, subject, body string) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.sender)
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/html", body)
+
+	return m.dialer.DialAndSend(msg)
+}
+
+// Render execute the html template of the locale in the file with data
+func Render(filename string, data interface{}) (string, error) {
+	t, err := template.New(filepath.Base(filename)).ParseFiles(filename)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -2,8 +2,11 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/emails"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/guregu/null.v4"
@@ -16,9 +19,11 @@ type RepoAuth struct {
 }
 
 var queries = map[string]string{
-	"getUserByDynamic":          `SELECT id, fullname, email, password, phone, address, role, avatar, created_at, updated_at FROM account.users`,
+	"getUserByDynamic":          `SELECT id, fullname, email, password, phone, address, role, avatar, token_version, totp_secret, totp_enabled, created_at, updated_at FROM account.users`,
 	"getUserConfirmByDynamic":   `SELECT id, activated, resend_expired, user_id FROM account.confirmation_users`,
 	"getPasswordResetByDynamic": `SELECT id, email, resend_expired, created_at FROM account.password_resets`,
+	"getRefreshTokenByDynamic":  `SELECT id, family_id, user_id, used_at, revoked, expired_at, created_at, updated_at FROM account.refresh_tokens`,
+	"getSessionByDynamic":       `SELECT id, user_id, device, ip, revoked, last_used_at, created_at, updated_at FROM account.sessions`,
 }
 var execs = map[string]string{
 	"insertUser":                         `INSERT INTO account.users (email, password) VALUES (:email, :password) RETURNING id`,
@@ -29,9 +34,25 @@ var execs = map[string]string{
 	"generatePasswordResetResendExpired": `UPDATE account.password_resets SET resend_expired=(CURRENT_TIMESTAMP + INTERVAL '5 minute') WHERE id = :id`,
 	"resetUserConfirmResendExpired":      `UPDATE account.confirmation_users SET resend_expired=(CURRENT_TIMESTAMP - INTERVAL '10 minute') WHERE id = :id`,
 	"resetPasswordResetResendExpired":    `UPDATE account.password_resets SET resend_expired=(CURRENT_TIMESTAMP - INTERVAL '10 minute') WHERE id = :id`,
+	"insertEmail":                        `INSERT INTO account.email_outbox (event, recipient, reference_id, locale) VALUES (:event, :recipient, :reference_id, :locale)`,
 	"deleteUser":                         `DELETE FROM account.users WHERE id = :id`,
 	"deleteUserConfirm":                  `DELETE FROM account.confirmation_users WHERE id = :id`,
 	"deletePasswordReset":                `DELETE FROM account.password_resets WHERE id = :id`,
+	"insertRefreshToken":                 `INSERT INTO account.refresh_tokens (id, family_id, user_id, expired_at) VALUES (:id, :family_id, :user_id, CURRENT_TIMESTAMP + make_interval(secs => :expires))`,
+	"useRefreshToken":                    `UPDATE account.refresh_tokens SET used_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE id = :id AND used_at IS NULL AND revoked = false`,
+	"revokeRefreshTokenFamily":           `UPDATE account.refresh_tokens SET revoked=true, updated_at=CURRENT_TIMESTAMP WHERE family_id = :family_id`,
+	"insertSession":                      `INSERT INTO account.sessions (user_id, device, ip) VALUES (:user_id, :device, :ip) RETURNING id`,
+	"touchSession":                       `UPDATE account.sessions SET last_used_at=CURRENT_TIMESTAMP, updated_at=CURRENT_TIMESTAMP WHERE id = :id`,
+	"revokeSession":                      `UPDATE account.sessions SET revoked=true, updated_at=CURRENT_TIMESTAMP WHERE id = :id`,
+	"deleteSessionExpired":               `DELETE FROM account.sessions s WHERE NOT EXISTS (SELECT 1 FROM account.refresh_tokens t WHERE t.family_id = s.id)`,
+	"deleteSessionByUserId":              `DELETE FROM account.sessions WHERE user_id = :user_id`,
+	"deleteRefreshTokenExpired":          `DELETE FROM account.refresh_tokens WHERE expired_at < CURRENT_TIMESTAMP`,
+	"deleteRefreshTokenByUserId":         `DELETE FROM account.refresh_tokens WHERE user_id = :user_id`,
+	"setTotpSecret":                      `UPDATE account.users SET totp_secret=:totp_secret, updated_at=CURRENT_TIMESTAMP WHERE id = :id AND totp_enabled = false`,
+	"enableTotp":                         `UPDATE account.users SET totp_enabled=true, updated_at=CURRENT_TIMESTAMP WHERE id = :id AND totp_enabled = false AND totp_secret IS NOT NULL`,
+	"insertRecoveryCode":                 `INSERT INTO account.recovery_codes (user_id, code_hash) VALUES (:user_id, :code_hash)`,
+	"useRecoveryCode":                    `UPDATE account.recovery_codes SET used_at=CURRENT_TIMESTAMP WHERE user_id = :user_id AND code_hash = :code_hash AND used_at IS NULL`,
+	"deleteRecoveryCodeByUserId":         `DELETE FROM account.recovery_codes WHERE user_id = :user_id`,
 }
 
 func New(db *sqlx.DB) (*RepoAuth, error) {
@@ -127,15 +148,140 @@ func (r *RepoAuth) GetPasswordResetByEmail(ctx context.Context, email string) (*
 	return &t, stmt.GetContext(ctx, &t, authentity.PasswordReset{Email: email})
 }
 
-func (r *RepoAuth) InsertUser(ctx context.Context, payload *authentity.JsonRegisterSchema) int {
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
+// Register will save the user with the confirmation and put the confirmation email
+// into the outbox in one transaction, it returns the id of the user and the confirmation
+func (r *RepoAuth) Register(ctx context.Context, payload *authentity.JsonRegisterSchema, locale string) (int, string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, "", err
+	}
 	payload.Password = string(hashedPassword)
 
-	var id int
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertUser"])
-	stmt.QueryRowxContext(ctx, payload).Scan(&id)
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var userId int
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertUser"])
+	if err != nil {
+		return 0, "", err
+	}
+	if err := stmt.QueryRowxContext(ctx, payload).Scan(&userId); err != nil {
+		return 0, "", err
+	}
+
+	var confirmId string
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertUserConfirm"])
+	if err != nil {
+		return 0, "", err
+	}
+	if err := stmt.QueryRowxContext(ctx, authentity.User{Id: userId}).Scan(&confirmId); err != nil {
+		return 0, "", err
+	}
+
+	if err := r.insertEmail(ctx, tx, &emailsentity.Email{
+		Event:       emailsentity.EventEmailConfirm,
+		Recipient:   payload.Email,
+		ReferenceId: confirmId,
+		Locale:      locale,
+	}); err != nil {
+		return 0, "", err
+	}
+
+	return userId, confirmId, tx.Commit()
+}
+
+// ResendUserConfirm will delay the next resend and put the confirmation email into the outbox
+func (r *RepoAuth) ResendUserConfirm(ctx context.Context, confirmId, email, locale string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["generateUserConfirmResendExpired"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, authentity.UserConfirm{Id: confirmId}); err != nil {
+		return err
+	}
+
+	if err := r.insertEmail(ctx, tx, &emailsentity.Email{
+		Event:       emailsentity.EventEmailConfirm,
+		Recipient:   email,
+		ReferenceId: confirmId,
+		Locale:      locale,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SendPasswordReset will put the password reset email into the outbox, a new password
+// reset is created when resetId is empty otherwise the next resend of it is delayed.
+// It returns the id of the password reset
+func (r *RepoAuth) SendPasswordReset(ctx context.Context, payload *authentity.JsonEmailSchema, resetId, locale string) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if len(resetId) > 0 {
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs["generatePasswordResetResendExpired"])
+		if err != nil {
+			return "", err
+		}
+		if _, err := stmt.ExecContext(ctx, authentity.PasswordReset{Id: resetId}); err != nil {
+			return "", err
+		}
+	} else {
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertPasswordReset"])
+		if err != nil {
+			return "", err
+		}
+		if err := stmt.QueryRowxContext(ctx, payload).Scan(&resetId); err != nil {
+			return "", err
+		}
+	}
+
+	if err := r.insertEmail(ctx, tx, &emailsentity.Email{
+		Event:       emailsentity.EventPasswordReset,
+		Recipient:   payload.Email,
+		ReferenceId: resetId,
+		Locale:      locale,
+	}); err != nil {
+		return "", err
+	}
+
+	return resetId, tx.Commit()
+}
+
+// SendLoginLocked will put the email telling the user the login is locked into the outbox
+func (r *RepoAuth) SendLoginLocked(ctx context.Context, email, locale string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertEmail"])
+	_, err := stmt.ExecContext(ctx, &emailsentity.Email{
+		Event:     emailsentity.EventLoginLocked,
+		Recipient: email,
+		Locale:    locale,
+	})
 
-	return id
+	return err
+}
+
+// insertEmail put the email into the outbox, the worker deliver it later
+func (r *RepoAuth) insertEmail(ctx context.Context, tx *sqlx.Tx, payload *emailsentity.Email) error {
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertEmail"])
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, payload)
+
+	return err
 }
 
 func (r *RepoAuth) UpdateUser(ctx context.Context, payload *authentity.User) error {
@@ -151,7 +297,8 @@ func (r *RepoAuth) UpdateUser(ctx context.Context, payload *authentity.User) err
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
 		payload.Password = string(hashedPassword)
 
-		query += `, password=:password`
+		// every token issued before is revoked
+		query += `, password=:password, token_version=token_version+1`
 	}
 	if len(payload.Phone.String) > 0 {
 		query += `, phone=:phone`
@@ -176,22 +323,6 @@ func (r *RepoAuth) UpdateUser(ctx context.Context, payload *authentity.User) err
 
 }
 
-func (r *RepoAuth) InsertUserConfirm(ctx context.Context, user_id int) string {
-	var id string
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertUserConfirm"])
-	stmt.QueryRowxContext(ctx, authentity.User{Id: user_id}).Scan(&id)
-
-	return id
-}
-
-func (r *RepoAuth) InsertPasswordReset(ctx context.Context, payload *authentity.JsonEmailSchema) string {
-	var id string
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertPasswordReset"])
-	stmt.QueryRowxContext(ctx, payload).Scan(&id)
-
-	return id
-}
-
 func (r *RepoAuth) DeletePasswordReset(ctx context.Context, id string) error {
 	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deletePasswordReset"])
 	_, err := stmt.ExecContext(ctx, authentity.PasswordReset{Id: id})
@@ -228,8 +359,8 @@ func (r *RepoAuth) SetUserConfirmActivatedTrue(ctx context.Context, id string) e
 	return nil
 }
 
-func (r *RepoAuth) GenerateUserConfirmResendExpired(ctx context.Context, id string) error {
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["generateUserConfirmResendExpired"])
+func (r *RepoAuth) ResetUserConfirmResendExpired(ctx context.Context, id string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["resetUserConfirmResendExpired"])
 	_, err := stmt.ExecContext(ctx, authentity.UserConfirm{Id: id})
 	if err != nil {
 		return err
@@ -237,8 +368,8 @@ func (r *RepoAuth) GenerateUserConfirmResendExpired(ctx context.Context, id stri
 	return nil
 }
 
-func (r *RepoAuth) GeneratePasswordResetResendExpired(ctx context.Context, id string) error {
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["generatePasswordResetResendExpired"])
+func (r *RepoAuth) ResetPasswordResetResendExpired(ctx context.Context, id string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["resetPasswordResetResendExpired"])
 	_, err := stmt.ExecContext(ctx, authentity.PasswordReset{Id: id})
 	if err != nil {
 		return err
@@ -246,20 +377,252 @@ func (r *RepoAuth) GeneratePasswordResetResendExpired(ctx context.Context, id st
 	return nil
 }
 
-func (r *RepoAuth) ResetUserConfirmResendExpired(ctx context.Context, id string) error {
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["resetUserConfirmResendExpired"])
-	_, err := stmt.ExecContext(ctx, authentity.UserConfirm{Id: id})
+func (r *RepoAuth) GetRefreshTokenById(ctx context.Context, id string) (*authentity.RefreshToken, error) {
+	var t authentity.RefreshToken
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getRefreshTokenByDynamic"]+" WHERE id = :id")
+
+	return &t, stmt.GetContext(ctx, &t, authentity.RefreshToken{Id: id})
+}
+
+// CreateSession will save the session with the first refresh token of it in one transaction,
+// the session is the family of the token. It returns the id of the session
+func (r *RepoAuth) CreateSession(ctx context.Context, payload *authentity.Session,
+	refreshToken *authentity.RefreshToken, expires time.Duration) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertSession"])
+	if err != nil {
+		return "", err
+	}
+	if err := stmt.QueryRowxContext(ctx, payload).Scan(&payload.Id); err != nil {
+		return "", err
+	}
+
+	refreshToken.FamilyId = payload.Id
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertRefreshToken"])
+	if err != nil {
+		return "", err
+	}
+	if _, err := stmt.ExecContext(ctx, refreshTokenArgs(refreshToken, expires)); err != nil {
+		return "", err
+	}
+
+	return payload.Id, tx.Commit()
+}
+
+// RotateRefreshToken will mark the refresh token as used, save the next one of the family and
+// touch the session in one transaction, sql.ErrNoRows is returned when the token was already
+// used or revoked
+func (r *RepoAuth) RotateRefreshToken(ctx context.Context, usedId string, payload *authentity.RefreshToken, expires time.Duration) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["useRefreshToken"])
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, authentity.RefreshToken{Id: usedId})
+	if err != nil {
+		return err
+	}
+	// someone else used the token first
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertRefreshToken"])
 	if err != nil {
 		return err
 	}
+	if _, err := stmt.ExecContext(ctx, refreshTokenArgs(payload, expires)); err != nil {
+		return err
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["touchSession"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, authentity.Session{Id: payload.FamilyId}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func refreshTokenArgs(payload *authentity.RefreshToken, expires time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        payload.Id,
+		"family_id": payload.FamilyId,
+		"user_id":   payload.UserId,
+		"expires":   expires.Seconds(),
+	}
+}
+
+func (r *RepoAuth) GetSessionById(ctx context.Context, id string) (*authentity.Session, error) {
+	var t authentity.Session
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getSessionByDynamic"]+" WHERE id = :id")
+
+	return &t, stmt.GetContext(ctx, &t, authentity.Session{Id: id})
+}
+
+// GetSessionsByUserId return the active sessions of the user, a session is active
+// until it is revoked or every refresh token of it is expired
+func (r *RepoAuth) GetSessionsByUserId(ctx context.Context, userId int) ([]authentity.Session, error) {
+	var results []authentity.Session
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getSessionByDynamic"]+` s WHERE user_id = :user_id AND revoked = false
+		AND EXISTS (SELECT 1 FROM account.refresh_tokens t WHERE t.family_id = s.id AND t.used_at IS NULL AND t.expired_at > CURRENT_TIMESTAMP)
+		ORDER BY last_used_at DESC`)
+
+	return results, stmt.SelectContext(ctx, &results, authentity.Session{UserId: userId})
+}
+
+// TouchSession will set the last used of the session to now
+func (r *RepoAuth) TouchSession(ctx context.Context, id string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["touchSession"])
+	_, err := stmt.ExecContext(ctx, authentity.Session{Id: id})
+
+	return err
+}
+
+// RevokeSession will revoke the session with every refresh token of it in one transaction
+func (r *RepoAuth) RevokeSession(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["revokeSession"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, authentity.Session{Id: id}); err != nil {
+		return err
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["revokeRefreshTokenFamily"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, authentity.RefreshToken{FamilyId: id}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRefreshTokenExpired will remove the refresh tokens that can not be presented anymore,
+// it returns the number of deleted tokens
+func (r *RepoAuth) DeleteRefreshTokenExpired(ctx context.Context) (int64, error) {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteRefreshTokenExpired"])
+	result, err := stmt.ExecContext(ctx, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteSessionExpired will remove the sessions whose refresh tokens are all purged,
+// it returns the number of deleted sessions
+func (r *RepoAuth) DeleteSessionExpired(ctx context.Context) (int64, error) {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteSessionExpired"])
+	result, err := stmt.ExecContext(ctx, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *RepoAuth) DeleteRefreshTokenByUserId(ctx context.Context, userId int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteRefreshTokenByUserId"])
+	_, err := stmt.ExecContext(ctx, authentity.RefreshToken{UserId: userId})
+
+	return err
+}
+
+func (r *RepoAuth) DeleteSessionByUserId(ctx context.Context, userId int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteSessionByUserId"])
+	_, err := stmt.ExecContext(ctx, authentity.Session{UserId: userId})
+
+	return err
+}
+
+// SetTotpSecret save the secret of the totp being enrolled, the secret of an enabled one is kept
+func (r *RepoAuth) SetTotpSecret(ctx context.Context, userId int, secret string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["setTotpSecret"])
+	result, err := stmt.ExecContext(ctx, authentity.User{Id: userId, TotpSecret: null.StringFrom(secret)})
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
 
-func (r *RepoAuth) ResetPasswordResetResendExpired(ctx context.Context, id string) error {
-	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["resetPasswordResetResendExpired"])
-	_, err := stmt.ExecContext(ctx, authentity.PasswordReset{Id: id})
+// EnableTotp will enable the enrolled totp of the user and replace the recovery codes of it
+func (r *RepoAuth) EnableTotp(ctx context.Context, userId int, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["enableTotp"])
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, authentity.User{Id: userId})
+	if err != nil {
+		return err
+	}
+	// enabled already or never enrolled
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["deleteRecoveryCodeByUserId"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, authentity.RecoveryCode{UserId: userId}); err != nil {
+		return err
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertRecoveryCode"])
+	if err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := stmt.ExecContext(ctx, authentity.RecoveryCode{UserId: userId, CodeHash: codeHash}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode mark the code as used, it returns sql.ErrNoRows when the user
+// has no such code left
+func (r *RepoAuth) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["useRecoveryCode"])
+	result, err := stmt.ExecContext(ctx, authentity.RecoveryCode{UserId: userId, CodeHash: codeHash})
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
@@ -0,0 +1,112 @@
+package emails
+
+import (
+	"context"
+	"time"
+
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/emails"
+	"github.com/jmoiron/sqlx"
+)
+
+type RepoEmails struct {
+	db      *sqlx.DB
+	queries map[string]string
+	execs   map[string]string
+}
+
+var queries = map[string]string{
+	"getEmailByDynamic": `SELECT id, event, recipient, reference_id, locale, status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at FROM account.email_outbox`,
+}
+var execs = map[string]string{
+	"markSent":               `UPDATE account.email_outbox SET updated_at=CURRENT_TIMESTAMP, status='sent', attempts=attempts+1, last_error=NULL, sent_at=CURRENT_TIMESTAMP WHERE id = :id AND status = 'pending'`,
+	"markRetry":              `UPDATE account.email_outbox SET updated_at=CURRENT_TIMESTAMP, attempts=attempts+1, last_error=:last_error, next_attempt_at=CURRENT_TIMESTAMP + make_interval(secs => :delay) WHERE id = :id AND status = 'pending'`,
+	"deleteEmailByRecipient": `DELETE FROM account.email_outbox WHERE recipient = :recipient`,
+	"markFailed":             `UPDATE account.email_outbox SET updated_at=CURRENT_TIMESTAMP, status='failed', attempts=attempts+1, last_error=:last_error WHERE id = :id AND status = 'pending'`,
+}
+
+func New(db *sqlx.DB) (*RepoEmails, error) {
+	rp := &RepoEmails{
+		db:      db,
+		queries: queries,
+		execs:   execs,
+	}
+
+	err := rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Validate will validate sql query to db
+func (r *RepoEmails) Validate() error {
+	for _, q := range r.queries {
+		_, err := r.db.PrepareNamedContext(context.Background(), q)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.execs {
+		_, err := r.db.PrepareNamedContext(context.Background(), e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetEmailDue return at most limit pending emails whose next attempt already passed, oldest first
+func (r *RepoEmails) GetEmailDue(ctx context.Context, limit int) ([]emailsentity.Email, error) {
+	var results []emailsentity.Email
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getEmailByDynamic"]+
+		" WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP ORDER BY id ASC LIMIT :limit")
+
+	return results, stmt.SelectContext(ctx, &results, map[string]interface{}{"limit": limit})
+}
+
+func (r *RepoEmails) GetEmailsByRecipient(ctx context.Context, recipient string) ([]emailsentity.Email, error) {
+	var results []emailsentity.Email
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getEmailByDynamic"]+" WHERE recipient = :recipient ORDER BY id ASC")
+
+	return results, stmt.SelectContext(ctx, &results, emailsentity.Email{Recipient: recipient})
+}
+
+func (r *RepoEmails) MarkSent(ctx context.Context, emailId int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["markSent"])
+	_, err := stmt.ExecContext(ctx, emailsentity.Email{Id: emailId})
+
+	return err
+}
+
+// MarkRetry will record the failure and postpone the next attempt by delay
+func (r *RepoEmails) MarkRetry(ctx context.Context, emailId int, lastError string, delay time.Duration) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["markRetry"])
+	_, err := stmt.ExecContext(ctx, map[string]interface{}{
+		"id":         emailId,
+		"last_error": lastError,
+		"delay":      delay.Seconds(),
+	})
+
+	return err
+}
+
+// MarkFailed will give up the email after the last attempt
+func (r *RepoEmails) MarkFailed(ctx context.Context, emailId int, lastError string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["markFailed"])
+	_, err := stmt.ExecContext(ctx, map[string]interface{}{
+		"id":         emailId,
+		"last_error": lastError,
+	})
+
+	return err
+}
+
+func (r *RepoEmails) DeleteByRecipient(ctx context.Context, recipient string) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteEmailByRecipient"])
+	_, err := stmt.ExecContext(ctx, emailsentity.Email{Recipient: recipient})
+
+	return err
+}
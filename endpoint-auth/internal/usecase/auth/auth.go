@@ -2,19 +2,19 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/creent-production/cdk-go/magicimage"
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
 	"github.com/creent-production/cdk-go/auth"
-	"github.com/creent-production/cdk-go/mail"
-	"github.com/creent-production/cdk-go/queue"
+	"github.com/creent-production/cdk-go/magicimage"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/creent-production/cdk-go/validation"
 	"github.com/go-chi/jwtauth"
@@ -33,7 +33,7 @@ func NewAuthUsecase(authRepo authRepo) *AuthUsecase {
 }
 
 func (uc *AuthUsecase) Register(ctx context.Context, rw http.ResponseWriter,
-	payload *authentity.JsonRegisterSchema, m *mail.Mail) {
+	payload *authentity.JsonRegisterSchema, locale string) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -48,28 +48,21 @@ func (uc *AuthUsecase) Register(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
-	// save into db
-	user_id := uc.authRepo.InsertUser(ctx, payload)
-	confirm_id := uc.authRepo.InsertUserConfirm(ctx, user_id)
-
-	q := queue.NewQueue(func(val interface{}) {
-		m.SendEmail(
-			[]string{},
-			[]string{payload.Email},
-			"Activated User",
-			"dont-reply@example.com",
-			"/app/templates/email/EmailConfirm.html",
-			struct{ Link string }{Link: fmt.Sprintf("http://localhost:3000/auth/confirm/%s", confirm_id)},
-		)
-	}, 20)
-	q.Push("send")
+	// save into db, the confirmation email is sent by the worker
+	if _, _, err := uc.authRepo.Register(ctx, payload, locale); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
 	response.WriteJSONResponse(rw, 201, nil, map[string]interface{}{
 		constant.App: "Check your email to activated user.",
 	})
 }
 
-func (uc *AuthUsecase) UserConfirm(ctx context.Context, rw http.ResponseWriter, token string, cfg *config.Config) {
+func (uc *AuthUsecase) UserConfirm(ctx context.Context, rw http.ResponseWriter, token string,
+	session *authentity.Session, redisCli *redis.Pool, cfg *config.Config) {
 	confirm, err := uc.authRepo.GetUserConfirmById(ctx, token)
 	if err != nil {
 		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
@@ -82,18 +75,28 @@ func (uc *AuthUsecase) UserConfirm(ctx context.Context, rw http.ResponseWriter,
 		uc.authRepo.SetUserConfirmActivatedTrue(ctx, confirm.Id)
 	}
 
+	user, err := uc.authRepo.GetUserById(ctx, confirm.UserId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: constant.UserNotFound,
+		})
+		return
+	}
+
 	// create token
-	accessToken := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(confirm.UserId), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
-	refreshToken := auth.GenerateRefreshToken(&auth.RefreshToken{Sub: strconv.Itoa(confirm.UserId), Exp: jwtauth.ExpireIn(cfg.JWT.RefreshExpires)})
+	results, err := uc.newSession(ctx, user, session, redisCli, cfg)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
-	response.WriteJSONResponse(rw, 200, map[string]interface{}{
-		"access_token":  auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
-		"refresh_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, refreshToken),
-	}, nil)
+	response.WriteJSONResponse(rw, 200, results, nil)
 }
 
 func (uc *AuthUsecase) ResendEmail(ctx context.Context, rw http.ResponseWriter,
-	payload *authentity.JsonEmailSchema, m *mail.Mail) {
+	payload *authentity.JsonEmailSchema, locale string) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -131,34 +134,35 @@ func (uc *AuthUsecase) ResendEmail(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
-	q := queue.NewQueue(func(val interface{}) {
-		m.SendEmail(
-			[]string{},
-			[]string{user.Email},
-			"Activated User",
-			"dont-reply@example.com",
-			"/app/templates/email/EmailConfirm.html",
-			struct{ Link string }{Link: fmt.Sprintf("http://localhost:3000/auth/confirm/%s", confirm.Id)},
-		)
-	}, 20)
-	q.Push("send")
-
-	// generate resend expired 5 minute again
-	uc.authRepo.GenerateUserConfirmResendExpired(ctx, confirm.Id)
+	// generate resend expired 5 minute again and send the email
+	if err := uc.authRepo.ResendUserConfirm(ctx, confirm.Id, user.Email, locale); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
 		constant.App: "Email confirmation has send.",
 	})
 }
 
-func (uc *AuthUsecase) Login(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonLoginSchema, cfg *config.Config) {
+func (uc *AuthUsecase) Login(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonLoginSchema,
+	session *authentity.Session, locale string, redisCli *redis.Pool, cfg *config.Config) {
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
 		return
 	}
 
+	// refused before the password is checked, even the right one
+	if wait, locked := loginRetryAfter(redisCli, payload.Email, session.Ip); wait > 0 {
+		writeLoginThrottled(rw, wait, locked)
+		return
+	}
+
 	user, err := uc.authRepo.GetUserByEmail(ctx, payload.Email)
 	if err != nil {
+		uc.loginFailed(ctx, nil, payload.Email, session.Ip, locale, redisCli, cfg)
 		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
 			constant.App: "Invalid credential.",
 		})
@@ -166,6 +170,7 @@ func (uc *AuthUsecase) Login(ctx context.Context, rw http.ResponseWriter, payloa
 	}
 
 	if !uc.authRepo.IsPasswordSameAsHash(ctx, []byte(user.Password), []byte(payload.Password)) {
+		uc.loginFailed(ctx, user, payload.Email, session.Ip, locale, redisCli, cfg)
 		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
 			constant.App: "Invalid credential.",
 		})
@@ -181,18 +186,37 @@ func (uc *AuthUsecase) Login(ctx context.Context, rw http.ResponseWriter, payloa
 		return
 	}
 
+	// the login is finished by MfaLogin with the code, the failures are kept till then
+	if user.TotpEnabled {
+		mfaToken, err := newMfaToken(user.Id, redisCli, cfg)
+		if err != nil {
+			response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+				constant.App: constant.FailedSaveData,
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 200, map[string]interface{}{
+			"mfa_token": mfaToken,
+		}, nil)
+		return
+	}
+	resetLogin(redisCli, emailSubject(payload.Email))
+
 	// create token
-	accessToken := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
-	refreshToken := auth.GenerateRefreshToken(&auth.RefreshToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.RefreshExpires)})
+	results, err := uc.newSession(ctx, user, session, redisCli, cfg)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
-	response.WriteJSONResponse(rw, 200, map[string]interface{}{
-		"access_token":  auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
-		"refresh_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, refreshToken),
-	}, nil)
+	response.WriteJSONResponse(rw, 200, results, nil)
 }
 
 func (uc *AuthUsecase) FreshToken(ctx context.Context, rw http.ResponseWriter,
-	payload *authentity.JsonPasswordOnlySchema, cfg *config.Config) {
+	payload *authentity.JsonPasswordOnlySchema, redisCli *redis.Pool, cfg *config.Config) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -217,15 +241,28 @@ func (uc *AuthUsecase) FreshToken(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
-	// create token
+	if user.TotpEnabled && !uc.verifySecondFactor(ctx, user, payload.Code, redisCli, cfg) {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			constant.App: constant.InvalidMfaCode,
+		})
+		return
+	}
+
+	// create token, in the session of the current one
 	accessToken := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	accessToken["ver"] = user.TokenVersion
+	if sessionId, ok := claims["sid"].(string); ok {
+		accessToken["sid"] = sessionId
+		trackTokens(redisCli, sessionId, cfg, accessToken)
+		uc.authRepo.TouchSession(ctx, sessionId)
+	}
 
 	response.WriteJSONResponse(rw, 200, map[string]interface{}{
 		"access_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
 	}, nil)
 }
 
-func (uc *AuthUsecase) RefreshToken(ctx context.Context, rw http.ResponseWriter, cfg *config.Config) {
+func (uc *AuthUsecase) RefreshToken(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, cfg *config.Config) {
 	_, claims, _ := jwtauth.FromContext(ctx)
 	sub, _ := strconv.Atoi(claims["sub"].(string))
 
@@ -237,11 +274,48 @@ func (uc *AuthUsecase) RefreshToken(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
-	// create token
+	current, err := uc.authRepo.GetRefreshTokenById(ctx, claims["jti"].(string))
+	if err != nil || current.Revoked || current.UserId != user.Id {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.TokenRevoked,
+		})
+		return
+	}
+
+	// a used refresh token is presented again, either the owner or the thief has the
+	// newer one so the whole session is revoked and the user must login again
+	if current.UsedAt.Valid {
+		uc.revokeSession(ctx, current.FamilyId, redisCli, cfg)
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.TokenReused,
+		})
+		return
+	}
+
+	// create token, the refresh token replaces the current one in the session
 	accessToken := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires)})
+	accessToken["sid"], accessToken["ver"] = current.FamilyId, user.TokenVersion
+	refreshToken, next := newRefreshToken(user, current.FamilyId, cfg)
+
+	if err := uc.authRepo.RotateRefreshToken(ctx, current.Id, next, cfg.JWT.RefreshExpires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// used by a concurrent request in the meantime
+			uc.revokeSession(ctx, current.FamilyId, redisCli, cfg)
+			response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+				constant.Header: constant.TokenReused,
+			})
+			return
+		}
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+	trackTokens(redisCli, current.FamilyId, cfg, accessToken, refreshToken)
 
 	response.WriteJSONResponse(rw, 200, map[string]interface{}{
-		"access_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
+		"access_token":  auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
+		"refresh_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, refreshToken),
 	}, nil)
 }
 
@@ -264,13 +338,18 @@ func (uc *AuthUsecase) RefreshRevoke(ctx context.Context, rw http.ResponseWriter
 	_, claims, _ := jwtauth.FromContext(ctx)
 	conn.Do("SETEX", claims["jti"], cfg.JWT.RefreshExpires.Seconds(), "ok")
 
+	// the session is logged out with the tokens rotated from it
+	if current, err := uc.authRepo.GetRefreshTokenById(ctx, claims["jti"].(string)); err == nil {
+		uc.revokeSession(ctx, current.FamilyId, redisCli, cfg)
+	}
+
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
 		constant.App: "An refresh token has revoked.",
 	})
 }
 
 func (uc *AuthUsecase) PasswordResetSend(ctx context.Context,
-	rw http.ResponseWriter, payload *authentity.JsonEmailSchema, m *mail.Mail) {
+	rw http.ResponseWriter, payload *authentity.JsonEmailSchema, locale string) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -309,28 +388,18 @@ func (uc *AuthUsecase) PasswordResetSend(ctx context.Context,
 		return
 	}
 
+	// insert to db when there is no password reset yet,
+	// otherwise generate resend expired 5 minute again
 	var resetId string
-	if err != nil {
-		// insert to db
-		resetId = uc.authRepo.InsertPasswordReset(ctx, payload)
-	} else {
-		// update expired time
+	if err == nil {
 		resetId = passwordReset.Id
-		// generate resend expired 5 minute again
-		uc.authRepo.GeneratePasswordResetResendExpired(ctx, resetId)
-	}
-
-	q := queue.NewQueue(func(val interface{}) {
-		m.SendEmail(
-			[]string{},
-			[]string{user.Email},
-			"Reset Password",
-			"dont-reply@example.com",
-			"/app/templates/email/EmailResetPassword.html",
-			struct{ Link string }{Link: fmt.Sprintf("http://localhost:3000/auth/password-reset/%s", resetId)},
-		)
-	}, 20)
-	q.Push("send")
+	}
+	if _, err := uc.authRepo.SendPasswordReset(ctx, &authentity.JsonEmailSchema{Email: user.Email}, resetId, locale); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
 		constant.App: "We have sent a password reset link to your email.",
@@ -338,7 +407,7 @@ func (uc *AuthUsecase) PasswordResetSend(ctx context.Context,
 }
 
 func (uc *AuthUsecase) PasswordReset(ctx context.Context, rw http.ResponseWriter,
-	token string, payload *authentity.JsonPasswordResetSchema) {
+	token string, payload *authentity.JsonPasswordResetSchema, redisCli *redis.Pool, cfg *config.Config) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -368,11 +437,23 @@ func (uc *AuthUsecase) PasswordReset(ctx context.Context, rw http.ResponseWriter
 		return
 	}
 
-	// action on db
-	uc.authRepo.UpdateUser(ctx, &authentity.User{
+	// action on db, then logout everywhere
+	if err := uc.authRepo.UpdateUser(ctx, &authentity.User{
 		Id:       user.Id,
 		Password: payload.Password,
-	})
+	}); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+	// the reset link stays usable when the tokens cannot be revoked, so it can be retried
+	if err := uc.revokeTokens(ctx, user.Id, redisCli, cfg); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 	uc.authRepo.DeletePasswordReset(ctx, passwordReset.Id)
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
@@ -380,8 +461,8 @@ func (uc *AuthUsecase) PasswordReset(ctx context.Context, rw http.ResponseWriter
 	})
 }
 
-func (uc *AuthUsecase) UpdatePassword(ctx context.Context,
-	rw http.ResponseWriter, payload *authentity.JsonUpdatePasswordSchema) {
+func (uc *AuthUsecase) UpdatePassword(ctx context.Context, rw http.ResponseWriter,
+	payload *authentity.JsonUpdatePasswordSchema, session *authentity.Session, redisCli *redis.Pool, cfg *config.Config) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -407,12 +488,39 @@ func (uc *AuthUsecase) UpdatePassword(ctx context.Context,
 	}
 
 	// update password
-	uc.authRepo.UpdateUser(ctx, &authentity.User{
+	if err := uc.authRepo.UpdateUser(ctx, &authentity.User{
 		Id:       user.Id,
 		Password: payload.Password,
-	})
+	}); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
-	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+	// every other session is logged out, the current one continues with a new token pair
+	if err := uc.revokeTokens(ctx, user.Id, redisCli, cfg); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+	user, err = uc.authRepo.GetUserById(ctx, user.Id)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+	results, err := uc.newSession(ctx, user, session, redisCli, cfg)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, results, map[string]interface{}{
 		constant.App: "Success update your password.",
 	})
 }
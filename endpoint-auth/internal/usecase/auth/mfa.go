@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
+	"github.com/creent-production/cdk-go/encryption"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pquerna/otp/totp"
+)
+
+// mfaTokenKey is the login waiting for the second factor, it holds the user id
+func mfaTokenKey(token string) string {
+	return fmt.Sprintf("auth:mfa_token:%s", token)
+}
+
+// claimMfaTokenScript take the mfa token away with the time it had left, so only one
+// request at a time can finish the login with it
+var claimMfaTokenScript = redis.NewScript(1, `
+local userId = redis.call("GET", KEYS[1])
+if not userId then
+	return false
+end
+local ttl = redis.call("PTTL", KEYS[1])
+redis.call("DEL", KEYS[1])
+return {userId, ttl}`)
+
+// claimMfaToken return the user id of the token and the milliseconds it had left,
+// the token is gone until restoreMfaToken gives it back
+func claimMfaToken(conn redis.Conn, token string) (int, int64, error) {
+	values, err := redis.Values(claimMfaTokenScript.Do(conn, mfaTokenKey(token)))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		userId int
+		ttl    int64
+	)
+	if _, err := redis.Scan(values, &userId, &ttl); err != nil {
+		return 0, 0, err
+	}
+
+	return userId, ttl, nil
+}
+
+// restoreMfaToken give the token back after a wrong code, so the user doesn't have
+// to enter the password again
+func restoreMfaToken(conn redis.Conn, token string, userId int, ttl int64) {
+	if ttl > 0 {
+		conn.Do("SET", mfaTokenKey(token), userId, "PX", ttl, "NX")
+	}
+}
+
+// newMfaToken let the user who passed the password finish the login with the code
+func newMfaToken(userId int, redisCli *redis.Pool, cfg *config.Config) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	conn := redisCli.Get()
+	defer conn.Close()
+	if _, err := conn.Do("SETEX", mfaTokenKey(token), int(cfg.MFA.TokenExpires.Seconds()), userId); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// newRecoveryCodes return the codes to show the user once and the hashes to save,
+// e.g. "a1b2c-3d4e5"
+func newRecoveryCodes(n int) ([]string, []string, error) {
+	codes, hashes := make([]string, n), make([]string, n)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes[i], hashes[i] = code[:5]+"-"+code[5:], hashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// validateTotp check the code against the secret of the user, a code is accepted
+// once while it is valid so a code seen by someone else can not be replayed
+func validateTotp(user *authentity.User, code string, redisCli *redis.Pool, cfg *config.Config) bool {
+	secret, err := (&encryption.Credentials{Key: []byte(cfg.MFA.EncryptionKey)}).Decrypt(user.TotpSecret.String)
+	if err != nil || !totp.Validate(code, string(secret)) {
+		return false
+	}
+
+	conn := redisCli.Get()
+	defer conn.Close()
+	// the code is valid for a period before and after the current one
+	reply, _ := redis.String(conn.Do("SET", fmt.Sprintf("auth:totp_used:%d:%s", user.Id, code), "ok", "EX", 90, "NX"))
+
+	return reply == "OK"
+}
+
+// verifySecondFactor accept a totp code or one of the recovery codes of the user
+func (uc *AuthUsecase) verifySecondFactor(ctx context.Context, user *authentity.User, code string,
+	redisCli *redis.Pool, cfg *config.Config) bool {
+
+	if validateTotp(user, code, redisCli, cfg) {
+		return true
+	}
+
+	return uc.authRepo.UseRecoveryCode(ctx, user.Id, hashRecoveryCode(code)) == nil
+}
+
+// MfaEnroll create a new totp secret for the user, it is enabled once a code of it is verified
+func (uc *AuthUsecase) MfaEnroll(ctx context.Context, rw http.ResponseWriter, cfg *config.Config) {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+
+	if user.TotpEnabled {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Two-factor authentication is already enabled.",
+		})
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: cfg.MFA.Issuer, AccountName: user.Email})
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	secret, err := (&encryption.Credentials{Key: []byte(cfg.MFA.EncryptionKey)}).Encrypt([]byte(key.Secret()))
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	if err := uc.authRepo.SetTotpSecret(ctx, user.Id, secret); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	// the qr code of the otpauth uri for the authenticator app
+	var qr bytes.Buffer
+	img, err := key.Image(256, 256)
+	if err == nil {
+		err = png.Encode(&qr, img)
+	}
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to create the qr code, please try again.",
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, map[string]interface{}{
+		"secret":      key.Secret(),
+		"otpauth_uri": key.URL(),
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	}, nil)
+}
+
+// MfaVerify enable the enrolled totp with a code of it and return the recovery codes,
+// they are shown only this time
+func (uc *AuthUsecase) MfaVerify(ctx context.Context, rw http.ResponseWriter,
+	payload *authentity.JsonMfaCodeSchema, redisCli *redis.Pool, cfg *config.Config) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+
+	if user.TotpEnabled {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Two-factor authentication is already enabled.",
+		})
+		return
+	}
+
+	if !user.TotpSecret.Valid {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Please enroll two-factor authentication first.",
+		})
+		return
+	}
+
+	if !validateTotp(user, payload.Code, redisCli, cfg) {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			constant.App: constant.InvalidMfaCode,
+		})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes(cfg.MFA.RecoveryCodes)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	if err := uc.authRepo.EnableTotp(ctx, user.Id, hashes); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, map[string]interface{}{
+		"recovery_codes": codes,
+	}, map[string]interface{}{
+		constant.App: "Two-factor authentication has been enabled.",
+	})
+}
+
+// MfaLogin exchange the mfa token of the login and the code for the token pair
+func (uc *AuthUsecase) MfaLogin(ctx context.Context, rw http.ResponseWriter, payload *authentity.JsonMfaLoginSchema,
+	session *authentity.Session, locale string, redisCli *redis.Pool, cfg *config.Config) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	// the token is claimed before the code is checked, a concurrent request presenting
+	// it loses here instead of using up a recovery code
+	userId, ttl, err := claimMfaToken(conn, payload.MfaToken)
+	if err != nil {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			constant.App: "The mfa token is invalid or expired, please login again.",
+		})
+		return
+	}
+
+	user, err := uc.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			constant.App: constant.UserNotFound,
+		})
+		return
+	}
+
+	// the wrong codes are counted like the wrong passwords
+	if wait, locked := loginRetryAfter(redisCli, user.Email, session.Ip); wait > 0 {
+		restoreMfaToken(conn, payload.MfaToken, userId, ttl)
+		writeLoginThrottled(rw, wait, locked)
+		return
+	}
+
+	if !uc.verifySecondFactor(ctx, user, payload.Code, redisCli, cfg) {
+		restoreMfaToken(conn, payload.MfaToken, userId, ttl)
+		uc.loginFailed(ctx, user, user.Email, session.Ip, locale, redisCli, cfg)
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			constant.App: constant.InvalidMfaCode,
+		})
+		return
+	}
+	resetLogin(redisCli, emailSubject(user.Email))
+
+	// create token
+	results, err := uc.newSession(ctx, user, session, redisCli, cfg)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
 )
@@ -15,12 +16,23 @@ type authRepo interface {
 	GetUserConfirmByUserId(ctx context.Context, id int) (*authentity.UserConfirm, error)
 	GetPasswordResetById(ctx context.Context, id string) (*authentity.PasswordReset, error)
 	GetPasswordResetByEmail(ctx context.Context, email string) (*authentity.PasswordReset, error)
-	InsertUser(ctx context.Context, payload *authentity.JsonRegisterSchema) int
+	Register(ctx context.Context, payload *authentity.JsonRegisterSchema, locale string) (int, string, error)
+	ResendUserConfirm(ctx context.Context, confirmId, email, locale string) error
+	SendPasswordReset(ctx context.Context, payload *authentity.JsonEmailSchema, resetId, locale string) (string, error)
+	SendLoginLocked(ctx context.Context, email, locale string) error
 	UpdateUser(ctx context.Context, payload *authentity.User) error
-	InsertUserConfirm(ctx context.Context, user_id int) string
-	InsertPasswordReset(ctx context.Context, payload *authentity.JsonEmailSchema) string
 	DeletePasswordReset(ctx context.Context, id string) error
 	SetUserConfirmActivatedTrue(ctx context.Context, id string) error
-	GenerateUserConfirmResendExpired(ctx context.Context, id string) error
-	GeneratePasswordResetResendExpired(ctx context.Context, id string) error
+	GetRefreshTokenById(ctx context.Context, id string) (*authentity.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, usedId string, payload *authentity.RefreshToken, expires time.Duration) error
+	DeleteRefreshTokenExpired(ctx context.Context) (int64, error)
+	GetSessionById(ctx context.Context, id string) (*authentity.Session, error)
+	GetSessionsByUserId(ctx context.Context, userId int) ([]authentity.Session, error)
+	CreateSession(ctx context.Context, payload *authentity.Session, refreshToken *authentity.RefreshToken, expires time.Duration) (string, error)
+	TouchSession(ctx context.Context, id string) error
+	RevokeSession(ctx context.Context, id string) error
+	DeleteSessionExpired(ctx context.Context) (int64, error)
+	SetTotpSecret(ctx context.Context, userId int, secret string) error
+	EnableTotp(ctx context.Context, userId int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) error
 }
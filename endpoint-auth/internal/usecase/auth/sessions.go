@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
+	"github.com/creent-production/cdk-go/auth"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/go-chi/jwtauth"
+	"github.com/gomodule/redigo/redis"
+)
+
+// sessionKey is the redis set of every jti issued in the session, revoking the session
+// put them into the denylist checked by auth.ValidateJWT
+func sessionKey(sessionId string) string {
+	return fmt.Sprintf("auth:session:%s", sessionId)
+}
+
+// trackTokens add the tokens to the session, the set lives as long as the newest refresh token
+func trackTokens(redisCli *redis.Pool, sessionId string, cfg *config.Config, claims ...map[string]interface{}) {
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	for _, c := range claims {
+		conn.Do("SADD", sessionKey(sessionId), c["jti"])
+	}
+	conn.Do("EXPIRE", sessionKey(sessionId), int(cfg.JWT.RefreshExpires.Seconds()))
+}
+
+// newSession save the session of the login and return the signed token pair of it
+func (uc *AuthUsecase) newSession(ctx context.Context, user *authentity.User, session *authentity.Session,
+	redisCli *redis.Pool, cfg *config.Config) (map[string]interface{}, error) {
+
+	session.UserId = user.Id
+	accessToken := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	accessToken["ver"] = user.TokenVersion
+	refreshToken, record := newRefreshToken(user, "", cfg)
+
+	sessionId, err := uc.authRepo.CreateSession(ctx, session, record, cfg.JWT.RefreshExpires)
+	if err != nil {
+		return nil, err
+	}
+	accessToken["sid"], refreshToken["sid"] = sessionId, sessionId
+	trackTokens(redisCli, sessionId, cfg, accessToken, refreshToken)
+
+	return map[string]interface{}{
+		"access_token":  auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, accessToken),
+		"refresh_token": auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, refreshToken),
+	}, nil
+}
+
+// newRefreshToken create the claims of a refresh token in the session and the record to
+// save it, the session is set when it is created with the token
+func newRefreshToken(user *authentity.User, sessionId string, cfg *config.Config) (map[string]interface{}, *authentity.RefreshToken) {
+	claims := auth.GenerateRefreshToken(&auth.RefreshToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.RefreshExpires)})
+	claims["ver"] = user.TokenVersion
+	if len(sessionId) > 0 {
+		claims["sid"] = sessionId
+	}
+
+	return claims, &authentity.RefreshToken{Id: claims["jti"].(string), FamilyId: sessionId, UserId: user.Id}
+}
+
+// revokeSession put every token issued in the session into the denylist and revoke it
+func (uc *AuthUsecase) revokeSession(ctx context.Context, sessionId string, redisCli *redis.Pool, cfg *config.Config) error {
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	jtis, _ := redis.Strings(conn.Do("SMEMBERS", sessionKey(sessionId)))
+	for _, jti := range jtis {
+		conn.Do("SETEX", jti, cfg.JWT.RefreshExpires.Seconds(), "ok")
+	}
+	conn.Do("DEL", sessionKey(sessionId))
+
+	return uc.authRepo.RevokeSession(ctx, sessionId)
+}
+
+// revokeTokens publish the token version of the user so every service rejects the tokens
+// issued before, and revoke the sessions of the user
+func (uc *AuthUsecase) revokeTokens(ctx context.Context, userId int, redisCli *redis.Pool, cfg *config.Config) error {
+	user, err := uc.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// the tokens issued before are expired when the key is
+	conn := redisCli.Get()
+	defer conn.Close()
+	if _, err := conn.Do("SETEX", fmt.Sprintf(constant.TokenVersionKey, user.Id),
+		int(cfg.JWT.RefreshExpires.Seconds()), user.TokenVersion); err != nil {
+		return err
+	}
+
+	sessions, err := uc.authRepo.GetSessionsByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := uc.revokeSession(ctx, session.Id, redisCli, cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (uc *AuthUsecase) GetSessions(ctx context.Context, rw http.ResponseWriter) {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	sessions, err := uc.authRepo.GetSessionsByUserId(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to get the sessions, please try again.",
+		})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == claims["sid"]
+	}
+
+	response.WriteJSONResponse(rw, 200, sessions, nil)
+}
+
+func (uc *AuthUsecase) RevokeSession(ctx context.Context, rw http.ResponseWriter, sessionId string,
+	redisCli *redis.Pool, cfg *config.Config) {
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	session, err := uc.authRepo.GetSessionById(ctx, sessionId)
+	if err != nil || session.UserId != sub || session.Revoked {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Session not found.",
+		})
+		return
+	}
+
+	if err := uc.revokeSession(ctx, session.Id, redisCli, cfg); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "The session has been revoked.",
+	})
+}
+
+// RevokeAllSessions will logout the user everywhere, the current session included
+func (uc *AuthUsecase) RevokeAllSessions(ctx context.Context, rw http.ResponseWriter,
+	redisCli *redis.Pool, cfg *config.Config) {
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	sessions, err := uc.authRepo.GetSessionsByUserId(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	for _, session := range sessions {
+		if err := uc.revokeSession(ctx, session.Id, redisCli, cfg); err != nil {
+			response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+				constant.App: constant.FailedSaveData,
+			})
+			return
+		}
+	}
+
+	// the current token may be issued before the sessions
+	conn := redisCli.Get()
+	defer conn.Close()
+	conn.Do("SETEX", claims["jti"], cfg.JWT.AccessExpires.Seconds(), "ok")
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "All sessions have been revoked.",
+	})
+}
+
+// PurgeRefreshTokens will delete the expired refresh tokens and the sessions left without
+// any of them, it returns the number of deleted tokens
+func (uc *AuthUsecase) PurgeRefreshTokens(ctx context.Context) (int64, error) {
+	purged, err := uc.authRepo.DeleteRefreshTokenExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := uc.authRepo.DeleteSessionExpired(ctx); err != nil {
+		return purged, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/constant"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+	"github.com/gomodule/redigo/redis"
+)
+
+// loginKey is the redis key of the failures, the delay or the lock of the login of the
+// subject, e.g. "auth:login:lock:email:user@example.com"
+func loginKey(kind, subject string) string {
+	return fmt.Sprintf("auth:login:%s:%s", kind, subject)
+}
+
+// the failed logins are counted for the email and for the ip of the client, so one
+// client can not guess the password of many emails either
+func emailSubject(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// loginRetryAfter return the seconds to wait before the next login of the email from the ip,
+// zero when it is allowed now, and whether it is locked or only delayed
+func loginRetryAfter(redisCli *redis.Pool, email, ip string) (int, bool) {
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	var wait int64
+	for _, subject := range []string{emailSubject(email), ipSubject(ip)} {
+		if ttl, _ := redis.Int64(conn.Do("PTTL", loginKey("lock", subject))); ttl > wait {
+			wait = ttl
+		}
+	}
+	if wait > 0 {
+		return int((wait + 999) / 1000), true
+	}
+
+	wait, _ = redis.Int64(conn.Do("PTTL", loginKey("delay", emailSubject(email))))
+	if wait > 0 {
+		return int((wait + 999) / 1000), false
+	}
+
+	return 0, false
+}
+
+// failLogin count the failure of the email and the ip, it returns true when the email
+// has just been locked. The ip is never delayed because many users may share it
+func failLogin(redisCli *redis.Pool, email, ip string, cfg *config.Config) bool {
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	throttle := cfg.LoginThrottle
+
+	if failures := countFailure(conn, ipSubject(ip), throttle.WindowDuration); failures >= throttle.IpThreshold {
+		lockLogin(conn, ipSubject(ip), throttle.LockoutDuration)
+	}
+
+	failures := countFailure(conn, emailSubject(email), throttle.WindowDuration)
+	if failures >= throttle.EmailThreshold {
+		return lockLogin(conn, emailSubject(email), throttle.LockoutDuration)
+	}
+
+	if failures > throttle.FreeAttempts {
+		// the delay doubles on every failure after the free attempts
+		delay := throttle.BaseDelayDuration
+		for i := throttle.FreeAttempts + 1; i < failures && delay < throttle.MaxDelayDuration; i++ {
+			delay *= 2
+		}
+		if delay > throttle.MaxDelayDuration {
+			delay = throttle.MaxDelayDuration
+		}
+		conn.Do("SET", loginKey("delay", emailSubject(email)), "ok", "PX", delay.Milliseconds())
+	}
+
+	return false
+}
+
+// countFailure add the failure of the subject, the failures are forgotten after the window
+func countFailure(conn redis.Conn, subject string, window time.Duration) int {
+	failures, _ := redis.Int(conn.Do("INCR", loginKey("failures", subject)))
+	conn.Do("PEXPIRE", loginKey("failures", subject), window.Milliseconds())
+
+	return failures
+}
+
+// lockLogin lock the subject and start over the count of its failures,
+// it returns false when the subject is already locked
+func lockLogin(conn redis.Conn, subject string, lockout time.Duration) bool {
+	reply, _ := redis.String(conn.Do("SET", loginKey("lock", subject), "ok", "PX", lockout.Milliseconds(), "NX"))
+	conn.Do("DEL", loginKey("failures", subject), loginKey("delay", subject))
+
+	return reply == "OK"
+}
+
+// resetLogin forget the failures, the delay and the lock of the subjects
+func resetLogin(redisCli *redis.Pool, subjects ...string) {
+	conn := redisCli.Get()
+	defer conn.Close()
+
+	for _, subject := range subjects {
+		conn.Do("DEL", loginKey("failures", subject), loginKey("delay", subject), loginKey("lock", subject))
+	}
+}
+
+// writeLoginThrottled tell the client how long to wait before the next login
+func writeLoginThrottled(rw http.ResponseWriter, wait int, locked bool) {
+	msg := fmt.Sprintf("Too many failed login attempts, please try again in %d seconds.", wait)
+	if locked {
+		msg = fmt.Sprintf("Login is locked because of too many failed attempts, please try again in %d seconds.", wait)
+	}
+
+	rw.Header().Set("Retry-After", strconv.Itoa(wait))
+	response.WriteJSONResponse(rw, 429, nil, map[string]interface{}{
+		constant.App: msg,
+	})
+}
+
+// loginFailed count the failed login and tell the user when the email is locked,
+// user is nil when the email is not registered
+func (uc *AuthUsecase) loginFailed(ctx context.Context, user *authentity.User, email, ip, locale string,
+	redisCli *redis.Pool, cfg *config.Config) {
+
+	if locked := failLogin(redisCli, email, ip, cfg); locked && user != nil {
+		uc.authRepo.SendLoginLocked(ctx, user.Email, locale)
+	}
+}
+
+func (uc *AuthUsecase) LoginUnlock(ctx context.Context, rw http.ResponseWriter,
+	payload *authentity.JsonLoginUnlockSchema, redisCli *redis.Pool) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+
+	if user.Role != "admin" {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
+		})
+		return
+	}
+
+	subjects := []string{emailSubject(payload.Email)}
+	if len(payload.Ip) > 0 {
+		subjects = append(subjects, ipSubject(payload.Ip))
+	}
+	resetLogin(redisCli, subjects...)
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "The login has been unlocked.",
+	})
+}
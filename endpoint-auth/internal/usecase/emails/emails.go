@@ -0,0 +1,97 @@
+package emails
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/emails"
+	mailerpkg "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/mailer"
+)
+
+type EmailsUsecase struct {
+	emailsRepo  emailsRepo
+	mailer      mailer
+	app         *config.App
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewEmailsUsecase(emailRepo emailsRepo, mailer mailer, app *config.App,
+	maxAttempts int, backoff time.Duration) *EmailsUsecase {
+	return &EmailsUsecase{
+		emailsRepo:  emailRepo,
+		mailer:      mailer,
+		app:         app,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// DeliverEmails will send at most limit emails waiting in the outbox, it returns the
+// number of sent emails. A failed email is tried again after the backoff, doubled on
+// every attempt, and given up after maxAttempts
+func (uc *EmailsUsecase) DeliverEmails(ctx context.Context, limit int) (int, error) {
+	outbox, err := uc.emailsRepo.GetEmailDue(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, e := range outbox {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		if err := uc.send(&e); err != nil {
+			if e.Attempts+1 >= uc.maxAttempts {
+				err = uc.emailsRepo.MarkFailed(ctx, e.Id, err.Error())
+			} else {
+				err = uc.emailsRepo.MarkRetry(ctx, e.Id, err.Error(), uc.backoff<<e.Attempts)
+			}
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err := uc.emailsRepo.MarkSent(ctx, e.Id); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (uc *EmailsUsecase) send(e *emailsentity.Email) error {
+	t, ok := uc.app.Email(e.Locale, e.Event)
+	if !ok {
+		return fmt.Errorf("no email for the event %s", e.Event)
+	}
+
+	// the page of the frontend the email points to
+	var link string
+	switch e.Event {
+	case emailsentity.EventEmailConfirm:
+		link = uc.app.Link(uc.app.Frontend.ConfirmURL, e.ReferenceId)
+	case emailsentity.EventPasswordReset:
+		link = uc.app.Link(uc.app.Frontend.PasswordResetURL, e.ReferenceId)
+	case emailsentity.EventLoginLocked:
+		link = uc.app.Link(uc.app.Frontend.ForgotPasswordURL, "")
+	}
+
+	body, err := mailerpkg.Render(t.Template, struct {
+		Link    string
+		BaseURL string
+	}{
+		Link:    link,
+		BaseURL: uc.app.PublicBaseURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.mailer.Send(e.Recipient, t.Subject, body)
+}
@@ -0,0 +1,20 @@
+package emails
+
+import (
+	"context"
+	"time"
+
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/emails"
+)
+
+type emailsRepo interface {
+	GetEmailDue(ctx context.Context, limit int) ([]emailsentity.Email, error)
+	MarkSent(ctx context.Context, emailId int) error
+	MarkRetry(ctx context.Context, emailId int, lastError string, delay time.Duration) error
+	MarkFailed(ctx context.Context, emailId int, lastError string) error
+}
+
+// mailer send the rendered email to the smtp server
+type mailer interface {
+	Send(to, subject, body string) error
+}
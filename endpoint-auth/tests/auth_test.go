@@ -8,12 +8,19 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/creent-production/cdk-go/magicimage"
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/auth"
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/entity/emails"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/mailer"
+	emailsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/usecase/emails"
 	"github.com/creent-production/cdk-go/auth"
+	"github.com/creent-production/cdk-go/magicimage"
 	"github.com/go-chi/jwtauth"
+	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,6 +106,7 @@ func TestRegister(t *testing.T) {
 	tests := [...]struct {
 		name       string
 		expected   string
+		language   string
 		payload    map[string]string
 		statusCode int
 	}{
@@ -111,6 +119,7 @@ func TestRegister(t *testing.T) {
 		{
 			name:       "create user 2",
 			expected:   "Check your email to activated user.",
+			language:   "id-ID,id;q=0.9,en;q=0.8",
 			payload:    map[string]string{"email": email_2, "password": "asdasd", "confirm_password": "asdasd"},
 			statusCode: 201,
 		},
@@ -131,6 +140,12 @@ func TestRegister(t *testing.T) {
 
 			req, _ := http.NewRequest(http.MethodPost, prefix+"/register", bytes.NewBuffer(body))
 			req.Header.Set("Content-Type", "application/json")
+			if len(test.language) > 0 {
+				req.Header.Set("Accept-Language", test.language)
+			}
+			if len(test.language) > 0 {
+				req.Header.Set("Accept-Language", test.language)
+			}
 
 			response := executeRequest(req, s)
 
@@ -420,6 +435,119 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLoginThrottle(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	cfg, _ := config.New()
+
+	login := func(email, password, ip string) (*http.Response, map[string]interface{}) {
+		var data map[string]interface{}
+
+		body, err := json.Marshal(map[string]string{"email": email, "password": password})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Real-IP", ip)
+		// sent through the proxy trusted by the config
+		req.RemoteAddr = "127.0.0.1:40000"
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result(), data
+	}
+	unlock := func(token string, payload map[string]string) (int, map[string]interface{}) {
+		var data map[string]interface{}
+
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/login-unlock", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+token)
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode, data
+	}
+	tokenOf := func(userEmail string) string {
+		user, _ := repo.authRepo.GetUserByEmail(context.Background(), userEmail)
+		token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+		return auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+	}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	repo.authRepo.UpdateUser(context.Background(), &authentity.User{Id: admin.Id, Role: "admin"})
+	defer repo.authRepo.UpdateUser(context.Background(), &authentity.User{Id: admin.Id, Role: "guest"})
+
+	t.Run("email is delayed then locked", func(t *testing.T) {
+		throttle := cfg.LoginThrottle
+		for i := 1; i < throttle.EmailThreshold; i++ {
+			response, _ := login(email, "asdasd2", "10.0.0.1")
+			assert.Equal(t, 422, response.StatusCode)
+
+			if i > throttle.FreeAttempts {
+				response, data := login(email, "asdasd2", "10.0.0.1")
+				assert.Equal(t, 429, response.StatusCode)
+				assert.NotEmpty(t, response.Header.Get("Retry-After"))
+				assert.Contains(t, data["detail_message"].(map[string]interface{})["_app"].(string), "Too many failed login attempts")
+
+				wait, _ := strconv.Atoi(response.Header.Get("Retry-After"))
+				time.Sleep(time.Duration(wait) * time.Second)
+			}
+		}
+		response, _ := login(email, "asdasd2", "10.0.0.1")
+		assert.Equal(t, 422, response.StatusCode)
+
+		// the right password from another ip is refused too
+		response, data := login(email, "asdasd", "10.0.0.3")
+		assert.Equal(t, 429, response.StatusCode)
+		assert.NotEmpty(t, response.Header.Get("Retry-After"))
+		assert.Contains(t, data["detail_message"].(map[string]interface{})["_app"].(string), "Login is locked")
+
+		emails, _ := repo.emailsRepo.GetEmailsByRecipient(context.Background(), email)
+		assert.Equal(t, emailsentity.EventLoginLocked, emails[len(emails)-1].Event)
+	})
+
+	t.Run("unlock the email", func(t *testing.T) {
+		statusCode, data := unlock(tokenOf(email), map[string]string{"email": email})
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "Only users with admin privileges can do this action.", data["detail_message"].(map[string]interface{})["_header"].(string))
+
+		statusCode, data = unlock(tokenOf(email_2), map[string]string{"email": ""})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["email"].(string))
+
+		statusCode, _ = unlock(tokenOf(email_2), map[string]string{"email": email, "ip": "10.0.0.1"})
+		assert.Equal(t, 200, statusCode)
+
+		response, _ := login(email, "asdasd", "10.0.0.3")
+		assert.Equal(t, 200, response.StatusCode)
+	})
+
+	t.Run("ip is locked", func(t *testing.T) {
+		for i := 0; i < cfg.LoginThrottle.IpThreshold; i++ {
+			response, _ := login(fmt.Sprintf("spray%d@test.com", i), "asdasd", "10.0.0.2")
+			assert.Equal(t, 422, response.StatusCode)
+		}
+
+		response, _ := login(email, "asdasd", "10.0.0.2")
+		assert.Equal(t, 429, response.StatusCode)
+
+		statusCode, _ := unlock(tokenOf(email_2), map[string]string{"email": email, "ip": "10.0.0.2"})
+		assert.Equal(t, 200, statusCode)
+
+		response, _ = login(email, "asdasd", "10.0.0.2")
+		assert.Equal(t, 200, response.StatusCode)
+	})
+}
+
 func TestValidationFreshToken(t *testing.T) {
 	_, s := setupEnvironment()
 
@@ -625,12 +753,127 @@ func TestRefreshToken(t *testing.T) {
 				assert.Equal(t, "User not found.", data["detail_message"].(map[string]interface{})["_header"].(string))
 			case "success":
 				assert.NotNil(t, data["results"].(map[string]interface{})["access_token"].(string))
+				assert.NotEqual(t, refreshToken, data["results"].(map[string]interface{})["refresh_token"].(string))
 			}
 			assert.Equal(t, test.statusCode, response.Result().StatusCode)
 		})
 	}
 }
 
+func TestRefreshTokenRotation(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	login := func() string {
+		var data map[string]interface{}
+
+		body, err := json.Marshal(map[string]string{"email": email, "password": "asdasd"})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return data["results"].(map[string]interface{})["refresh_token"].(string)
+	}
+	refresh := func(refreshToken string) (int, map[string]interface{}) {
+		var data map[string]interface{}
+
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/refresh-token", nil)
+		req.Header.Add("Authorization", "Bearer "+refreshToken)
+
+		response := executeRequest(req, s)
+
+		body, _ := io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode, data
+	}
+
+	t.Run("token not saved", func(t *testing.T) {
+		user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+
+		cfg, _ := config.New()
+		token := auth.GenerateRefreshToken(&auth.RefreshToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.RefreshExpires)})
+
+		statusCode, data := refresh(auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token))
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "Token is revoked.", data["detail_message"].(map[string]interface{})["_header"].(string))
+	})
+
+	t.Run("replay by the thief after the owner", func(t *testing.T) {
+		first := login()
+
+		statusCode, data := refresh(first)
+		assert.Equal(t, 200, statusCode)
+		second := data["results"].(map[string]interface{})["refresh_token"].(string)
+
+		// the owner keeps rotating
+		statusCode, data = refresh(second)
+		assert.Equal(t, 200, statusCode)
+		third := data["results"].(map[string]interface{})["refresh_token"].(string)
+
+		// the stolen first token is presented again
+		statusCode, data = refresh(first)
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "Token has been used, please login again.", data["detail_message"].(map[string]interface{})["_header"].(string))
+
+		// the whole family is revoked
+		statusCode, data = refresh(third)
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "Token is revoked.", data["detail_message"].(map[string]interface{})["_header"].(string))
+	})
+
+	t.Run("replay by the owner after the thief", func(t *testing.T) {
+		first := login()
+
+		// the thief rotates the stolen token first
+		statusCode, data := refresh(first)
+		assert.Equal(t, 200, statusCode)
+		stolen := data["results"].(map[string]interface{})["refresh_token"].(string)
+
+		// the owner presents the token it still has
+		statusCode, _ = refresh(first)
+		assert.Equal(t, 401, statusCode)
+
+		// the token of the thief is revoked
+		statusCode, _ = refresh(stolen)
+		assert.Equal(t, 401, statusCode)
+	})
+
+	t.Run("other logins are not revoked", func(t *testing.T) {
+		first, other := login(), login()
+
+		refresh(first)
+		refresh(first)
+
+		statusCode, data := refresh(other)
+		assert.Equal(t, 200, statusCode)
+		assert.NotNil(t, data["results"].(map[string]interface{})["refresh_token"])
+	})
+
+	t.Run("revoke the rotated tokens too", func(t *testing.T) {
+		first := login()
+
+		_, data := refresh(first)
+		second := data["results"].(map[string]interface{})["refresh_token"].(string)
+
+		// revoked with the older token, only the family in the db knows the second one
+		req, _ := http.NewRequest(http.MethodDelete, prefix+"/refresh-revoke", nil)
+		req.Header.Add("Authorization", "Bearer "+first)
+		executeRequest(req, s)
+
+		statusCode, data := refresh(second)
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "Token is revoked.", data["detail_message"].(map[string]interface{})["_header"].(string))
+	})
+}
+
 func TestAccessRevoke(t *testing.T) {
 	_, s := setupEnvironment()
 
@@ -1149,6 +1392,69 @@ func TestUpdatePassword(t *testing.T) {
 	}
 }
 
+func TestUpdatePasswordRevokeTokens(t *testing.T) {
+	_, s := setupEnvironment()
+
+	login := func() (string, string) {
+		var data map[string]interface{}
+
+		body, err := json.Marshal(map[string]string{"email": email, "password": "asdasd"})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		results := data["results"].(map[string]interface{})
+		return results["access_token"].(string), results["refresh_token"].(string)
+	}
+	request := func(method, url, token string, body []byte) (int, map[string]interface{}) {
+		var data map[string]interface{}
+
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+token)
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode, data
+	}
+
+	otherAccess, otherRefresh := login()
+	currentAccess, _ := login()
+
+	body, _ := json.Marshal(map[string]string{"old_password": "asdasd", "password": "asdasd", "confirm_password": "asdasd"})
+	statusCode, data := request(http.MethodPut, prefix+"/update-password", currentAccess, body)
+	assert.Equal(t, 200, statusCode)
+	newAccess := data["results"].(map[string]interface{})["access_token"].(string)
+	assert.NotNil(t, data["results"].(map[string]interface{})["refresh_token"])
+
+	t.Run("tokens issued before are revoked", func(t *testing.T) {
+		for _, token := range []string{otherAccess, currentAccess} {
+			statusCode, data := request(http.MethodGet, prefix, token, nil)
+			assert.Equal(t, 401, statusCode)
+			assert.Equal(t, "token is revoked", data["detail_message"].(map[string]interface{})["_header"].(string))
+		}
+
+		statusCode, _ := request(http.MethodPost, prefix+"/refresh-token", otherRefresh, nil)
+		assert.Equal(t, 401, statusCode)
+	})
+
+	t.Run("current device continues", func(t *testing.T) {
+		statusCode, _ := request(http.MethodGet, prefix, newAccess, nil)
+		assert.Equal(t, 200, statusCode)
+	})
+}
+
 func TestValidationUpdateAvatar(t *testing.T) {
 	_, s := setupEnvironment()
 
@@ -1499,6 +1805,344 @@ func TestMyData(t *testing.T) {
 	assert.Equal(t, 200, response.Result().StatusCode)
 }
 
+func TestSessions(t *testing.T) {
+	_, s := setupEnvironment()
+
+	login := func(device, remoteAddr string) (string, string) {
+		var data map[string]interface{}
+
+		body, err := json.Marshal(map[string]string{"email": email, "password": "asdasd"})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, prefix+"/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", device)
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		req.RemoteAddr = remoteAddr
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		results := data["results"].(map[string]interface{})
+		return results["access_token"].(string), results["refresh_token"].(string)
+	}
+	request := func(method, url, token string) (int, map[string]interface{}) {
+		var data map[string]interface{}
+
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+
+		response := executeRequest(req, s)
+
+		body, _ := io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode, data
+	}
+	findSession := func(data map[string]interface{}, device string) map[string]interface{} {
+		for _, session := range data["results"].([]interface{}) {
+			if session.(map[string]interface{})["device"] == device {
+				return session.(map[string]interface{})
+			}
+		}
+		return nil
+	}
+
+	accessA, refreshA := login("session test device a", "127.0.0.1:40000")
+	accessB, refreshB := login("session test device b", "127.0.0.1:40000")
+	// the header is not from a trusted proxy, it is ignored
+	login("session test device c", "192.0.2.10:40000")
+
+	var sessionB string
+
+	t.Run("list", func(t *testing.T) {
+		statusCode, data := request(http.MethodGet, prefix+"/sessions", accessA)
+		assert.Equal(t, 200, statusCode)
+
+		a := findSession(data, "session test device a")
+		if assert.NotNil(t, a) {
+			assert.Equal(t, "10.0.0.1", a["ip"])
+			assert.Equal(t, true, a["current"])
+			assert.NotNil(t, a["last_used_at"])
+			assert.NotNil(t, a["created_at"])
+		}
+		b := findSession(data, "session test device b")
+		if assert.NotNil(t, b) {
+			assert.Equal(t, false, b["current"])
+			sessionB = b["id"].(string)
+		}
+		c := findSession(data, "session test device c")
+		if assert.NotNil(t, c) {
+			assert.Equal(t, "192.0.2.10", c["ip"])
+		}
+	})
+
+	t.Run("revoke one", func(t *testing.T) {
+		statusCode, data := request(http.MethodDelete, prefix+"/sessions/"+sessionB, accessA)
+		assert.Equal(t, 200, statusCode)
+		assert.Equal(t, "The session has been revoked.", data["detail_message"].(map[string]interface{})["_app"].(string))
+
+		// both tokens of the device are denied
+		statusCode, data = request(http.MethodGet, prefix+"/sessions", accessB)
+		assert.Equal(t, 401, statusCode)
+		assert.Equal(t, "token is revoked", data["detail_message"].(map[string]interface{})["_header"].(string))
+
+		statusCode, _ = request(http.MethodPost, prefix+"/refresh-token", refreshB)
+		assert.Equal(t, 401, statusCode)
+
+		// the other device is still logged in and does not see the revoked one
+		statusCode, data = request(http.MethodGet, prefix+"/sessions", accessA)
+		assert.Equal(t, 200, statusCode)
+		assert.Nil(t, findSession(data, "session test device b"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		for _, id := range []string{sessionB, "asd"} {
+			statusCode, data := request(http.MethodDelete, prefix+"/sessions/"+id, accessA)
+			assert.Equal(t, 404, statusCode)
+			assert.Equal(t, "Session not found.", data["detail_message"].(map[string]interface{})["_app"].(string))
+		}
+	})
+
+	t.Run("revoke all", func(t *testing.T) {
+		// rotated before, the new pair of the session is revoked too
+		_, data := request(http.MethodPost, prefix+"/refresh-token", refreshA)
+		rotatedAccess := data["results"].(map[string]interface{})["access_token"].(string)
+
+		statusCode, data := request(http.MethodDelete, prefix+"/sessions", accessA)
+		assert.Equal(t, 200, statusCode)
+		assert.Equal(t, "All sessions have been revoked.", data["detail_message"].(map[string]interface{})["_app"].(string))
+
+		for _, token := range []string{accessA, rotatedAccess} {
+			statusCode, _ = request(http.MethodGet, prefix+"/sessions", token)
+			assert.Equal(t, 401, statusCode)
+		}
+	})
+}
+
+func TestDeliverEmails(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	cfg, _ := config.New()
+
+	smtp := newSMTPStandIn()
+	defer smtp.Close()
+
+	// no backoff, so the failed email is due again right away
+	uc := emailsusecase.NewEmailsUsecase(&repo.emailsRepo,
+		mailer.New("127.0.0.1", smtp.Port(), "", "", cfg.App.Sender.String()), &cfg.App, 3, 0)
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	userConfirm, _ := repo.authRepo.GetUserConfirmByUserId(context.Background(), user.Id)
+
+	t.Run("emails are in the outbox", func(t *testing.T) {
+		emails, _ := repo.emailsRepo.GetEmailsByRecipient(context.Background(), email_2)
+		// registered and resent once
+		assert.Len(t, emails, 2)
+		// registered in indonesian, resent without the header
+		assert.Equal(t, "id", emails[0].Locale)
+		assert.Equal(t, "", emails[1].Locale)
+		for _, e := range emails {
+			assert.Equal(t, emailsentity.EventEmailConfirm, e.Event)
+			assert.Equal(t, userConfirm.Id, e.ReferenceId)
+			assert.Equal(t, emailsentity.StatusPending, e.Status)
+		}
+
+		emails, _ = repo.emailsRepo.GetEmailsByRecipient(context.Background(), email)
+		assert.Equal(t, emailsentity.EventPasswordReset, emails[len(emails)-1].Event)
+	})
+
+	t.Run("smtp failure is retried", func(t *testing.T) {
+		smtp.SetReject(true)
+
+		_, err := uc.DeliverEmails(context.Background(), 1000)
+		assert.NoError(t, err)
+		assert.Len(t, smtp.Messages(), 0)
+
+		emails, _ := repo.emailsRepo.GetEmailsByRecipient(context.Background(), email_2)
+		for _, e := range emails {
+			assert.Equal(t, emailsentity.StatusPending, e.Status)
+			assert.Equal(t, 1, e.Attempts)
+			assert.True(t, e.LastError.Valid)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		smtp.SetReject(false)
+
+		sent, err := uc.DeliverEmails(context.Background(), 1000)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, sent, 3)
+
+		emails, _ := repo.emailsRepo.GetEmailsByRecipient(context.Background(), email_2)
+		for _, e := range emails {
+			assert.Equal(t, emailsentity.StatusSent, e.Status)
+			assert.Equal(t, 2, e.Attempts)
+			assert.True(t, e.SentAt.Valid)
+		}
+
+		var reset, confirm, locked *smtpMessage
+		messages := smtp.Messages()
+		for i := range messages {
+			assert.Contains(t, messages[i].From, cfg.App.Sender.Address)
+			if messages[i].Subject() == "Reset Password" && strings.Contains(messages[i].To[0], email) {
+				reset = &messages[i]
+			}
+			if messages[i].Subject() == "Aktivasi Akun" && strings.Contains(messages[i].To[0], email_2) {
+				confirm = &messages[i]
+			}
+			if messages[i].Subject() == "Login Locked" && strings.Contains(messages[i].To[0], email) {
+				locked = &messages[i]
+			}
+		}
+		emails, _ = repo.emailsRepo.GetEmailsByRecipient(context.Background(), email)
+		if assert.NotNil(t, reset) {
+			assert.Contains(t, reset.Body(), cfg.App.Link(cfg.App.Frontend.PasswordResetURL, emails[len(emails)-1].ReferenceId))
+		}
+		if assert.NotNil(t, confirm) {
+			assert.Contains(t, confirm.Body(), cfg.App.Link(cfg.App.Frontend.ConfirmURL, userConfirm.Id))
+		}
+		if assert.NotNil(t, locked) {
+			assert.Contains(t, locked.Body(), cfg.App.Link(cfg.App.Frontend.ForgotPasswordURL, ""))
+		}
+	})
+
+	t.Run("failed email is given up", func(t *testing.T) {
+		smtp.SetReject(true)
+
+		// a new email, only tried once
+		repo.authRepo.ResendUserConfirm(context.Background(), userConfirm.Id, email_2, "")
+		uc := emailsusecase.NewEmailsUsecase(&repo.emailsRepo,
+			mailer.New("127.0.0.1", smtp.Port(), "", "", cfg.App.Sender.String()), &cfg.App, 1, 0)
+
+		_, err := uc.DeliverEmails(context.Background(), 1000)
+		assert.NoError(t, err)
+
+		emails, _ := repo.emailsRepo.GetEmailsByRecipient(context.Background(), email_2)
+		last := emails[len(emails)-1]
+		assert.Equal(t, emailsentity.StatusFailed, last.Status)
+		assert.Equal(t, 1, last.Attempts)
+	})
+}
+
+func TestMfa(t *testing.T) {
+	_, s := setupEnvironment()
+
+	cfg, _ := config.New()
+
+	request := func(method, url, token string, payload map[string]string) (int, map[string]interface{}) {
+		var data map[string]interface{}
+
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode, data
+	}
+	detail := func(data map[string]interface{}) string {
+		return data["detail_message"].(map[string]interface{})["_app"].(string)
+	}
+
+	_, data := request(http.MethodPost, prefix+"/login", "", map[string]string{"email": email, "password": "asdasd"})
+	accessToken := data["results"].(map[string]interface{})["access_token"].(string)
+
+	var secret string
+	var recoveryCodes []interface{}
+
+	t.Run("enroll", func(t *testing.T) {
+		statusCode, data := request(http.MethodPost, prefix+"/2fa/verify", accessToken, map[string]string{"code": "123456"})
+		assert.Equal(t, 400, statusCode)
+		assert.Equal(t, "Please enroll two-factor authentication first.", detail(data))
+
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/enroll", accessToken, nil)
+		assert.Equal(t, 200, statusCode)
+		results := data["results"].(map[string]interface{})
+		secret = results["secret"].(string)
+		assert.True(t, strings.HasPrefix(results["otpauth_uri"].(string), "otpauth://totp/"))
+		assert.True(t, strings.HasPrefix(results["qr_code"].(string), "data:image/png;base64,"))
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		statusCode, data := request(http.MethodPost, prefix+"/2fa/verify", accessToken, map[string]string{"code": ""})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["code"].(string))
+
+		code, _ := totp.GenerateCode(secret, time.Now())
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/verify", accessToken, map[string]string{"code": code})
+		assert.Equal(t, 200, statusCode)
+		assert.Equal(t, "Two-factor authentication has been enabled.", detail(data))
+		recoveryCodes = data["results"].(map[string]interface{})["recovery_codes"].([]interface{})
+		assert.Len(t, recoveryCodes, cfg.MFA.RecoveryCodes)
+
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/enroll", accessToken, nil)
+		assert.Equal(t, 400, statusCode)
+		assert.Equal(t, "Two-factor authentication is already enabled.", detail(data))
+	})
+
+	mfaToken := func() string {
+		_, data := request(http.MethodPost, prefix+"/login", "", map[string]string{"email": email, "password": "asdasd"})
+		results := data["results"].(map[string]interface{})
+		assert.Nil(t, results["access_token"])
+		return results["mfa_token"].(string)
+	}
+
+	t.Run("login", func(t *testing.T) {
+		token := mfaToken()
+
+		statusCode, data := request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": "notfound", "code": "123456"})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "The mfa token is invalid or expired, please login again.", detail(data))
+
+		// the code of the verify can not be replayed
+		code, _ := totp.GenerateCode(secret, time.Now())
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": token, "code": code})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "Invalid two-factor authentication code.", detail(data))
+
+		code, _ = totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": token, "code": code})
+		assert.Equal(t, 200, statusCode)
+		assert.NotNil(t, data["results"].(map[string]interface{})["access_token"])
+		assert.NotNil(t, data["results"].(map[string]interface{})["refresh_token"])
+
+		// the token is used once
+		statusCode, _ = request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": token, "code": recoveryCodes[0].(string)})
+		assert.Equal(t, 422, statusCode)
+	})
+
+	t.Run("login with recovery code", func(t *testing.T) {
+		statusCode, data := request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": mfaToken(), "code": recoveryCodes[0].(string)})
+		assert.Equal(t, 200, statusCode)
+		accessToken = data["results"].(map[string]interface{})["access_token"].(string)
+
+		statusCode, data = request(http.MethodPost, prefix+"/2fa/login", "", map[string]string{"mfa_token": mfaToken(), "code": recoveryCodes[0].(string)})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "Invalid two-factor authentication code.", detail(data))
+	})
+
+	t.Run("fresh token", func(t *testing.T) {
+		statusCode, data := request(http.MethodPost, prefix+"/fresh-token", accessToken, map[string]string{"password": "asdasd"})
+		assert.Equal(t, 422, statusCode)
+		assert.Equal(t, "Invalid two-factor authentication code.", detail(data))
+
+		statusCode, data = request(http.MethodPost, prefix+"/fresh-token", accessToken, map[string]string{"password": "asdasd", "code": recoveryCodes[1].(string)})
+		assert.Equal(t, 200, statusCode)
+		assert.NotNil(t, data["results"].(map[string]interface{})["access_token"])
+	})
+}
+
 func TestClearDb(t *testing.T) {
 	repo, _ := setupEnvironment()
 
@@ -1507,6 +2151,9 @@ func TestClearDb(t *testing.T) {
 
 	repo.authRepo.DeleteUserConfirm(context.Background(), userConfirm.Id)
 	repo.authRepo.DeleteUser(context.Background(), user.Id)
+	repo.authRepo.DeleteRefreshTokenByUserId(context.Background(), user.Id)
+	repo.authRepo.DeleteSessionByUserId(context.Background(), user.Id)
+	repo.emailsRepo.DeleteByRecipient(context.Background(), user.Email)
 	if user.Avatar != "default.jpg" {
 		magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/avatars/%s", user.Avatar))
 	}
@@ -1516,6 +2163,9 @@ func TestClearDb(t *testing.T) {
 
 	repo.authRepo.DeleteUserConfirm(context.Background(), userConfirm.Id)
 	repo.authRepo.DeleteUser(context.Background(), user.Id)
+	repo.authRepo.DeleteRefreshTokenByUserId(context.Background(), user.Id)
+	repo.authRepo.DeleteSessionByUserId(context.Background(), user.Id)
+	repo.emailsRepo.DeleteByRecipient(context.Background(), user.Email)
 	if user.Avatar != "default.jpg" {
 		magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/avatars/%s", user.Avatar))
 	}
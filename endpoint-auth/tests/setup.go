@@ -12,10 +12,12 @@ import (
 	"github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/config"
 	handler_http "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/endpoint/http/handler"
 	authrepo "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/repo/auth"
+	emailsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-auth/internal/repo/emails"
 )
 
 type setupRepo struct {
-	authRepo authrepo.RepoAuth
+	authRepo   authrepo.RepoAuth
+	emailsRepo emailsrepo.RepoEmails
 }
 
 func setupEnvironment() (*setupRepo, *handler_http.Server) {
@@ -41,9 +43,11 @@ func setupEnvironment() (*setupRepo, *handler_http.Server) {
 	}
 	// you can insert your behaviors here
 	authRepo, _ := authrepo.New(db)
+	emailsRepo, _ := emailsrepo.New(db)
 
 	setuprepo := setupRepo{
-		authRepo: *authRepo,
+		authRepo:   *authRepo,
+		emailsRepo: *emailsRepo,
 	}
 
 	return &setuprepo, r
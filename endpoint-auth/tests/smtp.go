@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// smtpMessage is an account email received by smtpStandIn
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Subject return the decoded subject header of the message, it is the subject
+// of the locale in the email config e.g. Aktivasi Akun
+func (m smtpMessage) Subject() string {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+
+	return subject
+}
+
+// Body return the decoded html of the message
+func (m smtpMessage) Body() string {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+
+	return string(body)
+}
+
+// smtpStandIn is a local smtp server that keeps the emails of the auth outbox in
+// memory for TestDeliverEmails, when reject is set every email is refused with a
+// temporary failure so the retry can be checked
+type smtpStandIn struct {
+	listener net.Listener
+	reject   int32
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newSMTPStandIn() *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &smtpStandIn{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStandIn) Port() int { return s.listener.Addr().(*net.TCPAddr).Port }
+
+func (s *smtpStandIn) Close() { s.listener.Close() }
+
+func (s *smtpStandIn) SetReject(reject bool) {
+	if reject {
+		atomic.StoreInt32(&s.reject, 1)
+		return
+	}
+	atomic.StoreInt32(&s.reject, 0)
+}
+
+func (s *smtpStandIn) Messages() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	c := textproto.NewConn(conn)
+	defer c.Close()
+
+	var msg smtpMessage
+	c.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			if atomic.LoadInt32(&s.reject) == 1 {
+				c.PrintfLine("451 4.3.0 Try again later")
+				continue
+			}
+			msg = smtpMessage{From: line}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, line)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			c.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Command not implemented")
+		}
+	}
+}
@@ -5,6 +5,9 @@ const (
 	UserNotFound    = "User not found."
 	AlreadyTaken    = "The %s has already been taken."
 	PrivilegesOnly  = "Only users with %s privileges can do this action."
+	// TokenVersionKey is the redis key of the current token version of the user,
+	// written by endpoint-auth when the password changes
+	TokenVersionKey = "auth:token_version:%v"
 	// Key Response
 	Header = "_header"
 	Body   = "_body"
@@ -7,7 +7,6 @@ import (
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/constant"
 	categoriesentity "github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/entity/categories"
-	"github.com/creent-production/cdk-go/auth"
 	"github.com/creent-production/cdk-go/parser"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/creent-production/cdk-go/validation"
@@ -29,7 +28,7 @@ func AddCategories(r *chi.Mux, uc categoriesUsecaseIface, redisCli *redis.Pool)
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -6,6 +6,9 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
+// DefaultWeight in grams, used when the weight of a new product is not filled
+const DefaultWeight = 1000
+
 type FormCreateUpdateSchema struct {
 	Id          int    `schema:"-" db:"id"`
 	Name        string `schema:"name" validate:"required,min=3,max=100" db:"name"`
@@ -14,6 +17,7 @@ type FormCreateUpdateSchema struct {
 	Image       string `schema:"-" db:"image"`
 	Price       int    `schema:"price" validate:"required,min=1" db:"price"`
 	Stock       int    `schema:"stock" validate:"required,min=1" db:"stock"`
+	Weight      int    `schema:"weight" validate:"omitempty,min=1" db:"weight"`
 	CategoryId  int    `schema:"category_id" validate:"required,min=1" db:"category_id"`
 }
 
@@ -34,6 +38,7 @@ type Product struct {
 	Image       string    `json:"image" db:"image"`
 	Price       int       `json:"price" db:"price"`
 	Stock       int       `json:"stock" db:"stock"`
+	Weight      int       `json:"weight" db:"weight"`
 	CategoryId  int       `json:"category_id" db:"category_id"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
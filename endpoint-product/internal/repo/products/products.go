@@ -17,10 +17,10 @@ type RepoProducts struct {
 }
 
 var queries = map[string]string{
-	"getProductByDynamic": `SELECT id, name, slug, description, image, price, stock, category_id, created_at, updated_at FROM product.products`,
+	"getProductByDynamic": `SELECT id, name, slug, description, image, price, stock, weight, category_id, created_at, updated_at FROM product.products`,
 }
 var execs = map[string]string{
-	"insertProduct": `INSERT INTO product.products (name, slug, description, image, price, stock, category_id) VALUES (:name, :slug, :description, :image, :price, :stock, :category_id) RETURNING id`,
+	"insertProduct": `INSERT INTO product.products (name, slug, description, image, price, stock, weight, category_id) VALUES (:name, :slug, :description, :image, :price, :stock, :weight, :category_id) RETURNING id`,
 	"deleteProduct": `DELETE FROM product.products WHERE id = :id`,
 }
 
@@ -107,6 +107,9 @@ func (r *RepoProducts) Update(ctx context.Context, payload *productsentity.FormC
 	if payload.Stock > 0 {
 		query += `, stock=:stock`
 	}
+	if payload.Weight > 0 {
+		query += `, weight=:weight`
+	}
 	if payload.CategoryId > 0 {
 		query += `, category_id=:category_id`
 	}
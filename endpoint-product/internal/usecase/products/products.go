@@ -83,6 +83,9 @@ func (uc *ProductsUsecase) Create(ctx context.Context,
 
 	magicImage.SaveImages(500, 500, "/app/static/products", true)
 	payload.Image = magicImage.FileNames[0]
+	if payload.Weight < 1 {
+		payload.Weight = productsentity.DefaultWeight
+	}
 
 	// insert into db
 	uc.productsRepo.Insert(ctx, payload)
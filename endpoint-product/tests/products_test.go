@@ -3,12 +3,14 @@ package tests
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"testing"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/constant"
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/entity/auth"
 	"github.com/IndominusByte/warung-pintar-be/endpoint-product/internal/entity/categories"
 	"github.com/creent-production/cdk-go/auth"
@@ -554,6 +556,61 @@ func TestDeleteProduct(t *testing.T) {
 	}
 }
 
+func TestTokenVersionProduct(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+	redisCli, _ := config.RedisConnect(cfg)
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+
+	// the password of the admin is changed on endpoint-auth
+	conn := redisCli.Get()
+	defer conn.Close()
+	key := fmt.Sprintf(constant.TokenVersionKey, user.Id)
+	conn.Do("SETEX", key, 60, 1)
+	defer conn.Do("DEL", key)
+
+	token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	token["ver"] = 1
+	tokenNewVersion := auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+
+	tests := [...]struct {
+		name       string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "issued before the change",
+			token:      tokenAdmin,
+			statusCode: 401,
+		},
+		{
+			name:       "issued after the change",
+			token:      tokenNewVersion,
+			statusCode: 422,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixProduct, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			if test.statusCode == 401 {
+				assert.Equal(t, "token is revoked", data["detail_message"].(map[string]interface{})["_header"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
 func TestDownProduct(t *testing.T) {
 	repo, _ := setupEnvironment()
 
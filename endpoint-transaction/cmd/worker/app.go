@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/courier"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/mailer"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/payment"
+	authrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/auth"
+	cartsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/carts"
+	emailsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/emails"
+	ordersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/orders"
+	paymentsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/payments"
+	shippingrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/shipping"
+	vouchersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/vouchers"
+	emailsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/emails"
+	ordersusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/orders"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/worker"
+)
+
+func startWorker(cfg *config.Config) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	interval, err := time.ParseDuration(cfg.Worker.Interval)
+	if err != nil {
+		return err
+	}
+	leaderTTL, err := time.ParseDuration(cfg.Worker.LeaderTTL)
+	if err != nil {
+		return err
+	}
+	pendingPaymentDeadline, err := time.ParseDuration(cfg.Worker.ExpireOrders.PendingPayment)
+	if err != nil {
+		return err
+	}
+	ongoingDeadline, err := time.ParseDuration(cfg.Worker.ExpireOrders.Ongoing)
+	if err != nil {
+		return err
+	}
+	reuploadRequestedDeadline, err := time.ParseDuration(cfg.Worker.ExpireOrders.ReuploadRequested)
+	if err != nil {
+		return err
+	}
+	completeDeadline, err := time.ParseDuration(cfg.Worker.CompleteOrders)
+	if err != nil {
+		return err
+	}
+	emailBackoff, err := time.ParseDuration(cfg.Worker.DeliverEmails.Backoff)
+	if err != nil {
+		return err
+	}
+
+	// connect the db
+	db, err := config.DBConnect(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("DB connected")
+
+	// connect redis
+	redisCli, err := config.RedisConnect(cfg)
+	if err != nil {
+		return err
+	}
+	log.Println("Redis connected")
+
+	// you can insert your behaviors here
+	authRepo, err := authrepo.New(db)
+	if err != nil {
+		return err
+	}
+	cartsRepo, err := cartsrepo.New(db)
+	if err != nil {
+		return err
+	}
+	vouchersRepo, err := vouchersrepo.New(db)
+	if err != nil {
+		return err
+	}
+	shippingRepo, err := shippingrepo.New(db)
+	if err != nil {
+		return err
+	}
+	paymentsRepo, err := paymentsrepo.New(db)
+	if err != nil {
+		return err
+	}
+	paymentProvider, err := payment.New(cfg)
+	if err != nil {
+		return err
+	}
+	ordersRepo, err := ordersrepo.New(db)
+	if err != nil {
+		return err
+	}
+	ordersUsecase := ordersusecase.NewOrdersUsecase(ordersRepo, authRepo, cartsRepo, vouchersRepo, shippingRepo,
+		paymentsRepo, paymentProvider, courier.NewStub())
+	emailsRepo, err := emailsrepo.New(db)
+	if err != nil {
+		return err
+	}
+	m := mailer.New(cfg.Mail.Server, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.Sender)
+	emailsUsecase := emailsusecase.NewEmailsUsecase(emailsRepo, ordersRepo, authRepo, m,
+		cfg.Worker.DeliverEmails.MaxAttempts, emailBackoff)
+
+	w := worker.New(worker.NewLeader(redisCli, cfg.Worker.LeaderKey, leaderTTL), interval,
+		expireOrdersJob(ordersUsecase, ordersentity.StatusPendingPayment, pendingPaymentDeadline, cfg.Worker.BatchSize),
+		expireOrdersJob(ordersUsecase, ordersentity.StatusOngoing, ongoingDeadline, cfg.Worker.BatchSize),
+		expireOrdersJob(ordersUsecase, ordersentity.StatusReuploadRequested, reuploadRequestedDeadline, cfg.Worker.BatchSize),
+		completeOrdersJob(ordersUsecase, completeDeadline, cfg.Worker.BatchSize),
+		// run last so the emails of the orders moved above go out in the same tick
+		deliverEmailsJob(emailsUsecase, cfg.Worker.BatchSize),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Worker started")
+	w.Run(ctx)
+	log.Println("Worker stopped")
+
+	return nil
+}
+
+func expireOrdersJob(uc *ordersusecase.OrdersUsecase, status string, deadline time.Duration, limit int) worker.Job {
+	return worker.Job{
+		Name: fmt.Sprintf("expire %s orders", status),
+		Run: func(ctx context.Context) error {
+			expired, err := uc.ExpireOrders(ctx, status, deadline, limit)
+			if expired > 0 {
+				log.Printf("%d %s orders expired", expired, status)
+			}
+			return err
+		},
+	}
+}
+
+func completeOrdersJob(uc *ordersusecase.OrdersUsecase, deadline time.Duration, limit int) worker.Job {
+	return worker.Job{
+		Name: "complete on the way orders",
+		Run: func(ctx context.Context) error {
+			completed, err := uc.CompleteOrders(ctx, deadline, limit)
+			if completed > 0 {
+				log.Printf("%d on the way orders completed", completed)
+			}
+			return err
+		},
+	}
+}
+
+func deliverEmailsJob(uc *emailsusecase.EmailsUsecase, limit int) worker.Job {
+	return worker.Job{
+		Name: "deliver emails",
+		Run: func(ctx context.Context) error {
+			sent, err := uc.DeliverEmails(ctx, limit)
+			if sent > 0 {
+				log.Printf("%d emails delivered", sent)
+			}
+			return err
+		},
+	}
+}
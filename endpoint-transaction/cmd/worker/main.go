@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+)
+
+func main() {
+	// init config
+	cfg, err := config.New()
+	if err != nil {
+		log.Fatalf("failed to init the config: %v", err)
+	}
+
+	err = startWorker(cfg)
+	if err != nil {
+		log.Fatalf("failed to start worker: %v", err)
+	}
+}
@@ -9,11 +9,13 @@ require (
 	github.com/go-chi/jwtauth v1.2.0
 	github.com/gomodule/redigo v1.8.8
 	github.com/jmoiron/sqlx v1.3.4
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/lib/pq v1.10.4
 	github.com/stretchr/testify v1.7.0
 	github.com/swaggo/http-swagger v1.2.5
 	github.com/swaggo/swag v1.8.1
 	golang.org/x/crypto v0.0.0-20220331220935-ae2d96664a29
+	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
 	gopkg.in/guregu/null.v4 v4.0.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -55,5 +57,6 @@ require (
 	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e // indirect
 	golang.org/x/text v0.3.7 // indirect
 	golang.org/x/tools v0.1.7 // indirect
+	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
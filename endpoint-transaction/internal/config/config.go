@@ -13,6 +13,10 @@ type Config struct {
 	Database Database `yaml:"database"`
 	Redis    Redis    `yaml:"redis"`
 	JWT      JWT      `yaml:"jwt"`
+	Payment  Payment  `yaml:"payment"`
+	Checkout Checkout `yaml:"checkout"`
+	Mail     Mail     `yaml:"mail"`
+	Worker   Worker   `yaml:"worker"`
 }
 
 func New() (*Config, error) {
@@ -59,6 +63,27 @@ func (cfg *Config) parseDuration() error {
 
 	cfg.JWT.RefreshExpires = refreshExpired
 
+	paymentTimeout, err := time.ParseDuration(cfg.Payment.Timeout)
+	if err != nil {
+		return err
+	}
+
+	cfg.Payment.Timeouts = paymentTimeout
+
+	paymentExpired, err := time.ParseDuration(cfg.Payment.Expired)
+	if err != nil {
+		return err
+	}
+
+	cfg.Payment.Expires = paymentExpired
+
+	checkoutExpired, err := time.ParseDuration(cfg.Checkout.Expired)
+	if err != nil {
+		return err
+	}
+
+	cfg.Checkout.Expires = checkoutExpired
+
 	return nil
 }
 
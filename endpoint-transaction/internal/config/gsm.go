@@ -9,10 +9,13 @@ import (
 )
 
 type gsmData struct {
-	EncryptionKey  string `json:"encryption_key"`
-	SecretKey      string `json:"secret_key"`
-	PgTalkUser     string `json:"pg_talk_user"`
-	PgTalkPassword string `json:"pg_talk_password"`
+	EncryptionKey        string `json:"encryption_key"`
+	SecretKey            string `json:"secret_key"`
+	PgTalkUser           string `json:"pg_talk_user"`
+	PgTalkPassword       string `json:"pg_talk_password"`
+	PaymentServerKey     string `json:"payment_server_key"`
+	PaymentWebhookSecret string `json:"payment_webhook_secret"`
+	MailPassword         string `json:"mail_password"`
 }
 
 func (cfg *Config) loadFromGsm() error {
@@ -47,7 +50,25 @@ func (cfg *Config) loadFromGsm() error {
 		return pgtalkpassworderr
 	}
 
+	paymentserverkey, paymentserverkeyerr := cdn.Decrypt(data.PaymentServerKey)
+	if paymentserverkeyerr != nil {
+		return paymentserverkeyerr
+	}
+
+	paymentwebhooksecret, paymentwebhooksecreterr := cdn.Decrypt(data.PaymentWebhookSecret)
+	if paymentwebhooksecreterr != nil {
+		return paymentwebhooksecreterr
+	}
+
+	mailpassword, mailpassworderr := cdn.Decrypt(data.MailPassword)
+	if mailpassworderr != nil {
+		return mailpassworderr
+	}
+
 	cfg.JWT.SecretKey = string(secretkey)
+	cfg.Payment.ServerKey = string(paymentserverkey)
+	cfg.Payment.WebhookSecret = string(paymentwebhooksecret)
+	cfg.Mail.Password = string(mailpassword)
 	cfg.Database.MasterDsn = fmt.Sprintf(cfg.Database.MasterDsnNoCred, pgtalkuser, pgtalkpassword)
 	cfg.Database.FollowerDsn = fmt.Sprintf(cfg.Database.FollowerDsnNoCred, pgtalkuser, pgtalkpassword)
 
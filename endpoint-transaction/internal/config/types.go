@@ -43,3 +43,53 @@ type Redis struct {
 	Timeout       string `yaml:"timeout"`
 	Address       string `yaml:"address"`
 }
+
+type Payment struct {
+	Provider      string `yaml:"provider"`
+	BaseURL       string `yaml:"base_url"`
+	Timeout       string `yaml:"timeout"`
+	Expired       string `yaml:"expired"`
+	Timeouts      time.Duration
+	Expires       time.Duration
+	ServerKey     string
+	WebhookSecret string
+}
+
+// Checkout is how long the items moved to the payment wait for the order
+type Checkout struct {
+	Expired string `yaml:"expired"`
+	Expires time.Duration
+}
+
+type Mail struct {
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Sender   string `yaml:"sender"`
+	Password string
+}
+
+type Worker struct {
+	Interval     string       `yaml:"interval"`
+	LeaderKey    string       `yaml:"leader_key"`
+	LeaderTTL    string       `yaml:"leader_ttl"`
+	BatchSize    int          `yaml:"batch_size"`
+	ExpireOrders ExpireOrders `yaml:"expire_orders"`
+	// CompleteOrders is how long an order may stay on the way before it is completed
+	CompleteOrders string        `yaml:"complete_orders"`
+	DeliverEmails  DeliverEmails `yaml:"deliver_emails"`
+}
+
+// DeliverEmails is how the outbox is retried when the smtp server fails, the wait
+// before the next attempt starts at backoff and doubles on every failure
+type DeliverEmails struct {
+	MaxAttempts int    `yaml:"max_attempts"`
+	Backoff     string `yaml:"backoff"`
+}
+
+// ExpireOrders is how long an order may stay in the status before it expires
+type ExpireOrders struct {
+	PendingPayment    string `yaml:"pending_payment"`
+	Ongoing           string `yaml:"ongoing"`
+	ReuploadRequested string `yaml:"reupload_requested"`
+}
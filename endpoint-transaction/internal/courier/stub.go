@@ -0,0 +1,51 @@
+package courier
+
+import (
+	"context"
+	"time"
+
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+)
+
+// stubSteps is the journey of every package, relative to the time it shipped
+var stubSteps = []struct {
+	after       time.Duration
+	description string
+	location    string
+}{
+	{0, "Package picked up by the courier.", "Origin"},
+	{6 * time.Hour, "Package departed from the origin sorting center.", "Origin sorting center"},
+	{24 * time.Hour, "Package arrived at the destination sorting center.", "Destination sorting center"},
+	{36 * time.Hour, "Package is out for delivery.", "Destination"},
+	{48 * time.Hour, "Package delivered.", "Destination"},
+}
+
+// Stub make up the tracking events from the time the package shipped,
+// it is used until the api of the couriers is integrated
+type Stub struct {
+	now func() time.Time
+}
+
+func NewStub() *Stub {
+	return &Stub{now: time.Now}
+}
+
+func (s *Stub) Track(ctx context.Context, shipment *shippingentity.Shipment) (*shippingentity.Tracking, error) {
+	tracking := &shippingentity.Tracking{Shipment: *shipment, Events: []shippingentity.TrackingEvent{}}
+
+	now := s.now()
+	for i, step := range stubSteps {
+		occurredAt := shipment.ShippedAt.Add(step.after)
+		if occurredAt.After(now) {
+			break
+		}
+		tracking.Events = append(tracking.Events, shippingentity.TrackingEvent{
+			Description: step.description,
+			Location:    step.location,
+			OccurredAt:  occurredAt,
+		})
+		tracking.Delivered = i == len(stubSteps)-1
+	}
+
+	return tracking, nil
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
 	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
-	"github.com/creent-production/cdk-go/auth"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/creent-production/cdk-go/validation"
 	"github.com/go-chi/chi/v5"
@@ -18,8 +17,9 @@ type cartsUsecaseIface interface {
 	CreateUpdate(ctx context.Context, rw http.ResponseWriter, payload *cartsentity.JsonCreateUpdateSchema)
 	GetAll(ctx context.Context, rw http.ResponseWriter, payload *cartsentity.QueryParamAllCartSchema)
 	Delete(ctx context.Context, rw http.ResponseWriter, payload *cartsentity.JsonMultipleSchema)
-	MoveToPayment(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, payload *cartsentity.JsonMultipleSchema)
-	ItemInPayment(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool)
+	MoveToPayment(ctx context.Context, rw http.ResponseWriter, payload *cartsentity.JsonMultipleSchema)
+	ItemInPayment(ctx context.Context, rw http.ResponseWriter)
+	Checkout(ctx context.Context, rw http.ResponseWriter)
 }
 
 func AddCarts(r *chi.Mux, uc cartsUsecaseIface, redisCli *redis.Pool) {
@@ -28,7 +28,7 @@ func AddCarts(r *chi.Mux, uc cartsUsecaseIface, redisCli *redis.Pool) {
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -84,10 +84,13 @@ func AddCarts(r *chi.Mux, uc cartsUsecaseIface, redisCli *redis.Pool) {
 					return
 				}
 
-				uc.MoveToPayment(r.Context(), rw, redisCli, &p)
+				uc.MoveToPayment(r.Context(), rw, &p)
 			})
 			r.Get("/item-in-payment", func(rw http.ResponseWriter, r *http.Request) {
-				uc.ItemInPayment(r.Context(), rw, redisCli)
+				uc.ItemInPayment(r.Context(), rw)
+			})
+			r.Get("/checkout", func(rw http.ResponseWriter, r *http.Request) {
+				uc.Checkout(r.Context(), rw)
 			})
 		})
 		// public route
@@ -5,13 +5,22 @@ import (
 	"strings"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/courier"
 	endpoint_http "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/endpoint/http"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/payment"
 	authrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/auth"
 	cartsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/carts"
 	ordersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/orders"
+	paymentsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/payments"
 	productsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/products"
+	returnsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/returns"
+	shippingrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/shipping"
+	vouchersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/vouchers"
 	cartsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/carts"
 	ordersusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/orders"
+	returnsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/returns"
+	shippingusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/shipping"
+	vouchersusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/vouchers"
 	"github.com/creent-production/cdk-go/auth"
 	"github.com/creent-production/cdk-go/filestatic"
 	"github.com/go-chi/chi/v5"
@@ -50,7 +59,8 @@ func (s *Server) MountHandlers() error {
 	s.Router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key", "X-Signature"},
+		ExposedHeaders:   []string{"Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -77,15 +87,48 @@ func (s *Server) MountHandlers() error {
 	if err != nil {
 		return err
 	}
-	cartsUsecase := cartsusecase.NewCartsUsecase(cartsRepo, authRepo, productsRepo)
+	cartsUsecase := cartsusecase.NewCartsUsecase(cartsRepo, authRepo, productsRepo, s.cfg.Checkout.Expires)
 	endpoint_http.AddCarts(s.Router, cartsUsecase, s.redisCli)
 
+	vouchersRepo, err := vouchersrepo.New(s.db)
+	if err != nil {
+		return err
+	}
+	vouchersUsecase := vouchersusecase.NewVouchersUsecase(vouchersRepo, authRepo)
+	endpoint_http.AddVouchers(s.Router, vouchersUsecase, s.redisCli)
+
+	// built-in table provider, replace it with another provider to get the rates elsewhere
+	shippingRepo, err := shippingrepo.New(s.db)
+	if err != nil {
+		return err
+	}
+	shippingUsecase := shippingusecase.NewShippingUsecase(shippingRepo, authRepo)
+	endpoint_http.AddShipping(s.Router, shippingUsecase, s.redisCli)
+
+	paymentsRepo, err := paymentsrepo.New(s.db)
+	if err != nil {
+		return err
+	}
+	paymentProvider, err := payment.New(s.cfg)
+	if err != nil {
+		return err
+	}
+
 	ordersRepo, err := ordersrepo.New(s.db)
 	if err != nil {
 		return err
 	}
-	ordersUsecase := ordersusecase.NewOrdersUsecase(ordersRepo, authRepo, cartsRepo)
+	ordersUsecase := ordersusecase.NewOrdersUsecase(ordersRepo, authRepo, cartsRepo, vouchersRepo, shippingRepo,
+		paymentsRepo, paymentProvider, courier.NewStub())
 	endpoint_http.AddOrders(s.Router, ordersUsecase, s.redisCli)
+	endpoint_http.AddPayments(s.Router, ordersUsecase, s.cfg.Payment.WebhookSecret)
+
+	returnsRepo, err := returnsrepo.New(s.db)
+	if err != nil {
+		return err
+	}
+	returnsUsecase := returnsusecase.NewReturnsUsecase(returnsRepo, ordersRepo, authRepo)
+	endpoint_http.AddReturns(s.Router, returnsUsecase, s.redisCli)
 
 	return nil
 }
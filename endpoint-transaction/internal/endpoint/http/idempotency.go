@@ -0,0 +1,198 @@
+package endpoint_http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/go-chi/jwtauth"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	idempotencyHeader  = "Idempotency-Key"
+	idempotencyExpired = 86400
+	// idempotencyProcessingExpired is longer than the write timeout of the server, so the
+	// lock of a request killed halfway is freed soon and the client can retry
+	idempotencyProcessingExpired = 60
+)
+
+// idempotencyResult is the stored entry of a key, status code zero means still processing
+type idempotencyResult struct {
+	StatusCode  int             `json:"status_code"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	Fingerprint string          `json:"fingerprint"`
+}
+
+// idempotencyRecorder write the response to the client and keep a copy of it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// requestFingerprint hash the request body, a multipart form is hashed by its fields and
+// files since the boundary differs between retries. The body stays readable by the handler.
+func requestFingerprint(r *http.Request) (string, error) {
+	h := sha256.New()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return "", err
+		}
+
+		keys := make([]string, 0, len(r.MultipartForm.Value))
+		for key := range r.MultipartForm.Value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s=%q;", key, r.MultipartForm.Value[key])
+		}
+
+		keys = keys[:0]
+		for key := range r.MultipartForm.File {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, fh := range r.MultipartForm.File[key] {
+				fmt.Fprintf(h, "%s=%q:%d;", key, fh.Filename, fh.Size)
+
+				file, err := fh.Open()
+				if err != nil {
+					return "", err
+				}
+				_, err = io.Copy(h, file)
+				file.Close()
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idempotent store the response of a request that has Idempotency-Key header for 24 hours,
+// a retry with the same key and body get the stored response, the same key with another
+// body is rejected and so is a duplicate that comes while the first one still processing.
+// Must be placed after the jwt check.
+func idempotent(redisCli *redis.Pool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyHeader)
+			if len(key) < 1 {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			if len(key) > 255 {
+				response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+					constant.Header: "Idempotency-Key cannot be longer than 255 characters.",
+				})
+				return
+			}
+
+			fingerprint, err := requestFingerprint(r)
+			if err != nil {
+				response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+					constant.Body: constant.FailedParseBody,
+				})
+				return
+			}
+
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			redisKey := fmt.Sprintf("idempotency:%s:%s:%s", claims["sub"], r.URL.Path, key)
+
+			// lock the key, only the first request can process it
+			processing, _ := json.Marshal(idempotencyResult{Fingerprint: fingerprint})
+
+			conn := redisCli.Get()
+			ok, _ := redis.String(conn.Do("SET", redisKey, processing, "EX", idempotencyProcessingExpired, "NX"))
+			stored, _ := redis.Bytes(conn.Do("GET", redisKey))
+			conn.Close()
+
+			if ok != "OK" {
+				var result idempotencyResult
+				if json.Unmarshal(stored, &result) != nil || result.StatusCode == 0 {
+					response.WriteJSONResponse(rw, 409, nil, map[string]interface{}{
+						constant.Header: "A request with the same Idempotency-Key is still being processed.",
+					})
+					return
+				}
+
+				if result.Fingerprint != fingerprint {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Header: "Idempotency-Key has already been used with a different request body.",
+					})
+					return
+				}
+
+				rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+				rw.Header().Set("X-Content-Type-Options", "nosniff")
+				rw.Header().Set("Idempotent-Replayed", "true")
+				rw.WriteHeader(result.StatusCode)
+				rw.Write(result.Body)
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: rw, statusCode: http.StatusOK}
+			saved := false
+			defer func() {
+				// server error or panic can be retried with the same key
+				if !saved {
+					conn := redisCli.Get()
+					defer conn.Close()
+
+					conn.Do("DEL", redisKey)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode >= 500 {
+				return
+			}
+
+			result, err := json.Marshal(idempotencyResult{StatusCode: rec.statusCode, Body: rec.body.Bytes(), Fingerprint: fingerprint})
+			if err != nil {
+				return
+			}
+
+			conn = redisCli.Get()
+			defer conn.Close()
+
+			_, err = conn.Do("SET", redisKey, result, "EX", idempotencyExpired)
+			saved = err == nil
+		})
+	}
+}
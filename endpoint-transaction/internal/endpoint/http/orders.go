@@ -2,12 +2,14 @@ package endpoint_http
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"mime/multipart"
 	"net/http"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
 	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
-	"github.com/creent-production/cdk-go/auth"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
 	"github.com/creent-production/cdk-go/parser"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/creent-production/cdk-go/validation"
@@ -16,10 +18,20 @@ import (
 )
 
 type ordersUsecaseIface interface {
-	Create(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool, file *multipart.Form, payload *ordersentity.FormCreateSchema)
+	Create(ctx context.Context, rw http.ResponseWriter, file *multipart.Form, payload *ordersentity.FormCreateSchema)
+	ShippingQuote(ctx context.Context, rw http.ResponseWriter, payload *shippingentity.JsonQuoteSchema)
 	SetReject(ctx context.Context, rw http.ResponseWriter, orderId int)
+	VerifyPayment(ctx context.Context, rw http.ResponseWriter, orderId int)
+	RequestReupload(ctx context.Context, rw http.ResponseWriter, orderId int, payload *ordersentity.JsonRequestReuploadSchema)
+	ReuploadProof(ctx context.Context, rw http.ResponseWriter, orderId int, file *multipart.Form)
 	SetSuccess(ctx context.Context, rw http.ResponseWriter, orderId int)
-	SetOnTheWay(ctx context.Context, rw http.ResponseWriter, orderId int, file *multipart.Form)
+	SetOnTheWay(ctx context.Context, rw http.ResponseWriter, orderId int,
+		file *multipart.Form, payload *ordersentity.FormOnTheWaySchema)
+	Cancel(ctx context.Context, rw http.ResponseWriter, orderId int, payload *ordersentity.JsonCancelSchema)
+	GetHistory(ctx context.Context, rw http.ResponseWriter, orderId int)
+	GetDetail(ctx context.Context, rw http.ResponseWriter, orderId int)
+	GetTracking(ctx context.Context, rw http.ResponseWriter, orderId int)
+	GetInvoice(ctx context.Context, rw http.ResponseWriter, orderId int)
 	GetMine(ctx context.Context, rw http.ResponseWriter, payload *ordersentity.QueryParamAllOrderSchema)
 	GetAllOrder(ctx context.Context, rw http.ResponseWriter, payload *ordersentity.QueryParamAllOrderSchema)
 }
@@ -30,7 +42,7 @@ func AddOrders(r *chi.Mux, uc ordersUsecaseIface, redisCli *redis.Pool) {
 		r.Group(func(r chi.Router) {
 			r.Use(func(next http.Handler) http.Handler {
 				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-					if err := auth.ValidateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
 						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 							constant.Header: err.Error(),
 						})
@@ -40,7 +52,7 @@ func AddOrders(r *chi.Mux, uc ordersUsecaseIface, redisCli *redis.Pool) {
 					next.ServeHTTP(rw, r)
 				})
 			})
-			r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+			r.With(idempotent(redisCli)).Post("/", func(rw http.ResponseWriter, r *http.Request) {
 				if err := r.ParseMultipartForm(32 << 20); err != nil {
 					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
 						"_body": constant.FailedParseBody,
@@ -57,13 +69,57 @@ func AddOrders(r *chi.Mux, uc ordersUsecaseIface, redisCli *redis.Pool) {
 					return
 				}
 
-				uc.Create(r.Context(), rw, redisCli, r.MultipartForm, &p)
+				uc.Create(r.Context(), rw, r.MultipartForm, &p)
+			})
+			r.Post("/shipping-quote", func(rw http.ResponseWriter, r *http.Request) {
+				var p shippingentity.JsonQuoteSchema
+
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.ShippingQuote(r.Context(), rw, &p)
 			})
 			r.Put("/set-reject/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
 				orderId, _ := parser.ParsePathToInt("/orders/set-reject/(.*)", r.URL.Path)
 
 				uc.SetReject(r.Context(), rw, orderId)
 			})
+			r.Put("/verify-payment/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/verify-payment/(.*)", r.URL.Path)
+
+				uc.VerifyPayment(r.Context(), rw, orderId)
+			})
+			r.Put("/request-reupload/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/request-reupload/(.*)", r.URL.Path)
+
+				var p ordersentity.JsonRequestReuploadSchema
+
+				// reason is optional, so an empty body is allowed
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil && err != io.EOF {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.RequestReupload(r.Context(), rw, orderId, &p)
+			})
+			r.Put("/reupload-proof/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/reupload-proof/(.*)", r.URL.Path)
+
+				if err := r.ParseMultipartForm(32 << 20); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						"_body": constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.ReuploadProof(r.Context(), rw, orderId, r.MultipartForm)
+			})
 			r.Put("/set-on-the-way/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
 				orderId, _ := parser.ParsePathToInt("/orders/set-on-the-way/(.*)", r.URL.Path)
 
@@ -74,13 +130,57 @@ func AddOrders(r *chi.Mux, uc ordersUsecaseIface, redisCli *redis.Pool) {
 					return
 				}
 
-				uc.SetOnTheWay(r.Context(), rw, orderId, r.MultipartForm)
+				var p ordersentity.FormOnTheWaySchema
+
+				if err := validation.ParseRequest(&p, r.Form); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						"_body": constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.SetOnTheWay(r.Context(), rw, orderId, r.MultipartForm, &p)
 			})
 			r.Put("/set-success/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
 				orderId, _ := parser.ParsePathToInt("/orders/set-success/(.*)", r.URL.Path)
 
 				uc.SetSuccess(r.Context(), rw, orderId)
 			})
+			r.Put("/cancel/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/cancel/(.*)", r.URL.Path)
+
+				var p ordersentity.JsonCancelSchema
+
+				// reason is optional, so an empty body is allowed
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil && err != io.EOF {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.Cancel(r.Context(), rw, orderId, &p)
+			})
+			r.Get("/{order_id:[1-9][0-9]*}/history", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/(.*)/history", r.URL.Path)
+
+				uc.GetHistory(r.Context(), rw, orderId)
+			})
+			r.Get("/{order_id:[1-9][0-9]*}/tracking", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/(.*)/tracking", r.URL.Path)
+
+				uc.GetTracking(r.Context(), rw, orderId)
+			})
+			r.Get("/{order_id:[1-9][0-9]*}/invoice.pdf", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/(.*)/invoice.pdf", r.URL.Path)
+
+				uc.GetInvoice(r.Context(), rw, orderId)
+			})
+			r.Get("/{order_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				orderId, _ := parser.ParsePathToInt("/orders/(.*)", r.URL.Path)
+
+				uc.GetDetail(r.Context(), rw, orderId)
+			})
 			r.Get("/mine", func(rw http.ResponseWriter, r *http.Request) {
 				var p ordersentity.QueryParamAllOrderSchema
 
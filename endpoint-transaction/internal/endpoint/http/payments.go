@@ -0,0 +1,52 @@
+package endpoint_http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/payment"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/go-chi/chi/v5"
+)
+
+type paymentsUsecaseIface interface {
+	PaymentWebhook(ctx context.Context, rw http.ResponseWriter, payload *paymentsentity.JsonWebhookSchema)
+}
+
+func AddPayments(r *chi.Mux, uc paymentsUsecaseIface, webhookSecret string) {
+	r.Route("/payments", func(r chi.Router) {
+		// called by the payment gateway, it is authenticated with the signature of the body
+		r.Post("/webhook", func(rw http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+					constant.Body: constant.FailedParseBody,
+				})
+				return
+			}
+
+			if !payment.VerifySignature(webhookSecret, body, r.Header.Get(payment.SignatureHeader)) {
+				response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+					constant.Header: "Invalid signature.",
+				})
+				return
+			}
+
+			var p paymentsentity.JsonWebhookSchema
+
+			if err := json.NewDecoder(bytes.NewReader(body)).Decode(&p); err != nil {
+				response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+					constant.Body: constant.FailedParseBody,
+				})
+				return
+			}
+
+			uc.PaymentWebhook(r.Context(), rw, &p)
+		})
+	})
+}
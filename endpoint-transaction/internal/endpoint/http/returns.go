@@ -0,0 +1,114 @@
+package endpoint_http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	returnsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/returns"
+	"github.com/creent-production/cdk-go/parser"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+)
+
+type returnsUsecaseIface interface {
+	Create(ctx context.Context, rw http.ResponseWriter, file *multipart.Form, payload *returnsentity.FormCreateSchema)
+	Approve(ctx context.Context, rw http.ResponseWriter, returnId int)
+	Reject(ctx context.Context, rw http.ResponseWriter, returnId int, payload *returnsentity.JsonRejectSchema)
+	GetDetail(ctx context.Context, rw http.ResponseWriter, returnId int)
+	GetMine(ctx context.Context, rw http.ResponseWriter, payload *returnsentity.QueryParamAllReturnSchema)
+	GetAll(ctx context.Context, rw http.ResponseWriter, payload *returnsentity.QueryParamAllReturnSchema)
+}
+
+func AddReturns(r *chi.Mux, uc returnsUsecaseIface, redisCli *redis.Pool) {
+	r.Route("/orders/returns", func(r chi.Router) {
+		// protected route
+		r.Group(func(r chi.Router) {
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+							constant.Header: err.Error(),
+						})
+						return
+					}
+					// Token is authenticated, pass it through
+					next.ServeHTTP(rw, r)
+				})
+			})
+			r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(32 << 20); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						"_body": constant.FailedParseBody,
+					})
+					return
+				}
+
+				var p returnsentity.FormCreateSchema
+
+				if err := validation.ParseRequest(&p, r.Form); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						"_body": constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.Create(r.Context(), rw, r.MultipartForm, &p)
+			})
+			r.Put("/approve/{return_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				returnId, _ := parser.ParsePathToInt("/orders/returns/approve/(.*)", r.URL.Path)
+
+				uc.Approve(r.Context(), rw, returnId)
+			})
+			r.Put("/reject/{return_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				returnId, _ := parser.ParsePathToInt("/orders/returns/reject/(.*)", r.URL.Path)
+
+				var p returnsentity.JsonRejectSchema
+
+				// reason is optional, so an empty body is allowed
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil && err != io.EOF {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.Reject(r.Context(), rw, returnId, &p)
+			})
+			r.Get("/{return_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				returnId, _ := parser.ParsePathToInt("/orders/returns/(.*)", r.URL.Path)
+
+				uc.GetDetail(r.Context(), rw, returnId)
+			})
+			r.Get("/mine", func(rw http.ResponseWriter, r *http.Request) {
+				var p returnsentity.QueryParamAllReturnSchema
+
+				if err := validation.ParseRequest(&p, r.URL.Query()); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.GetMine(r.Context(), rw, &p)
+			})
+			r.Get("/", func(rw http.ResponseWriter, r *http.Request) {
+				var p returnsentity.QueryParamAllReturnSchema
+
+				if err := validation.ParseRequest(&p, r.URL.Query()); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.GetAll(r.Context(), rw, &p)
+			})
+		})
+	})
+}
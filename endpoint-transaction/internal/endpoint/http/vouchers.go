@@ -0,0 +1,91 @@
+package endpoint_http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
+	"github.com/creent-production/cdk-go/parser"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+)
+
+type vouchersUsecaseIface interface {
+	Create(ctx context.Context, rw http.ResponseWriter, payload *vouchersentity.JsonCreateUpdateSchema)
+	GetAll(ctx context.Context, rw http.ResponseWriter, payload *vouchersentity.QueryParamAllVoucherSchema)
+	GetById(ctx context.Context, rw http.ResponseWriter, voucherId int)
+	Update(ctx context.Context, rw http.ResponseWriter, payload *vouchersentity.JsonCreateUpdateSchema, voucherId int)
+	Delete(ctx context.Context, rw http.ResponseWriter, voucherId int)
+}
+
+func AddVouchers(r *chi.Mux, uc vouchersUsecaseIface, redisCli *redis.Pool) {
+	r.Route("/vouchers", func(r chi.Router) {
+		// protected route
+		r.Group(func(r chi.Router) {
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					if err := validateJWT(r.Context(), redisCli, "jwtRequired"); err != nil {
+						response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+							constant.Header: err.Error(),
+						})
+						return
+					}
+					// Token is authenticated, pass it through
+					next.ServeHTTP(rw, r)
+				})
+			})
+			r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+				var p vouchersentity.JsonCreateUpdateSchema
+
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.Create(r.Context(), rw, &p)
+			})
+			r.Get("/", func(rw http.ResponseWriter, r *http.Request) {
+				var p vouchersentity.QueryParamAllVoucherSchema
+
+				if err := validation.ParseRequest(&p, r.URL.Query()); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.GetAll(r.Context(), rw, &p)
+			})
+			r.Get("/{voucher_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				voucherId, _ := parser.ParsePathToInt("/vouchers/(.*)", r.URL.Path)
+
+				uc.GetById(r.Context(), rw, voucherId)
+			})
+			r.Put("/{voucher_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				voucherId, _ := parser.ParsePathToInt("/vouchers/(.*)", r.URL.Path)
+
+				var p vouchersentity.JsonCreateUpdateSchema
+
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+						constant.Body: constant.FailedParseBody,
+					})
+					return
+				}
+
+				uc.Update(r.Context(), rw, &p, voucherId)
+			})
+			r.Delete("/{voucher_id:[1-9][0-9]*}", func(rw http.ResponseWriter, r *http.Request) {
+				voucherId, _ := parser.ParsePathToInt("/vouchers/(.*)", r.URL.Path)
+
+				uc.Delete(r.Context(), rw, voucherId)
+			})
+		})
+	})
+}
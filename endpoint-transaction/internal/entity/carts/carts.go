@@ -1,6 +1,10 @@
 package carts
 
-import "gopkg.in/guregu/null.v4"
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
 
 type JsonCreateUpdateSchema struct {
 	Operation string `json:"operation" validate:"required,oneof=create update"`
@@ -28,14 +32,28 @@ type Cart struct {
 }
 
 type CartProduct struct {
-	CartId        int         `json:"cart_id" db:"cart_id"`
-	CartNotes     null.String `json:"cart_notes" db:"cart_notes"`
-	CartQty       int         `json:"cart_qty" db:"cart_qty"`
-	CartUserId    int         `json:"cart_user_id" db:"cart_user_id"`
-	CartProductId int         `json:"cart_product_id" db:"cart_product_id"`
-	ProductName   string      `json:"product_name" db:"product_name"`
-	ProductSlug   string      `json:"product_slug" db:"product_slug"`
-	ProductImage  string      `json:"product_image" db:"product_image"`
-	ProductPrice  int         `json:"product_price" db:"product_price"`
-	ProductStock  int         `json:"product_stock" db:"product_stock"`
+	CartId              int         `json:"cart_id" db:"cart_id"`
+	CartNotes           null.String `json:"cart_notes" db:"cart_notes"`
+	CartQty             int         `json:"cart_qty" db:"cart_qty"`
+	CartUserId          int         `json:"cart_user_id" db:"cart_user_id"`
+	CartProductId       int         `json:"cart_product_id" db:"cart_product_id"`
+	ProductName         string      `json:"product_name" db:"product_name"`
+	ProductSlug         string      `json:"product_slug" db:"product_slug"`
+	ProductImage        string      `json:"product_image" db:"product_image"`
+	ProductPrice        int         `json:"product_price" db:"product_price"`
+	ProductCurrentPrice int         `json:"product_current_price" db:"product_current_price"`
+	ProductStock        int         `json:"product_stock" db:"product_stock"`
+	ProductWeight       int         `json:"product_weight" db:"product_weight"`
+	ProductCategoryId   int         `json:"product_category_id" db:"product_category_id"`
+}
+
+type Checkout struct {
+	Id          int           `json:"id" db:"id"`
+	UserId      int           `json:"user_id" db:"user_id"`
+	ExpiredAt   time.Time     `json:"expired_at" db:"expired_at"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at" db:"updated_at"`
+	TotalQty    int           `json:"total_qty" db:"-"`
+	TotalAmount int           `json:"total_amount" db:"-"`
+	Items       []CartProduct `json:"items" db:"-"`
 }
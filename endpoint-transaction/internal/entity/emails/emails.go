@@ -0,0 +1,32 @@
+package emails
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	EventOrderCreated       = "order_created"
+	EventOrderStatusChanged = "order_status_changed"
+
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Email is an event waiting in the outbox, it is saved together with the change of
+// the order and rendered into the email only when it is delivered
+type Email struct {
+	Id            int         `json:"id" db:"id"`
+	Event         string      `json:"event" db:"event"`
+	OrderStatus   string      `json:"order_status" db:"order_status"`
+	Status        string      `json:"status" db:"status"`
+	Attempts      int         `json:"attempts" db:"attempts"`
+	LastError     null.String `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time   `json:"next_attempt_at" db:"next_attempt_at"`
+	SentAt        null.Time   `json:"sent_at" db:"sent_at"`
+	OrderId       int         `json:"order_id" db:"order_id"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
+}
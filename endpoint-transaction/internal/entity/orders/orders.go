@@ -1,37 +1,85 @@
 package orders
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
 	"gopkg.in/guregu/null.v4"
 )
 
+const (
+	StatusPendingPayment    = "pending_payment"
+	StatusOngoing           = "ongoing"
+	StatusReuploadRequested = "reupload_requested"
+	StatusPaymentVerified   = "payment_verified"
+	StatusReject            = "reject"
+	StatusOnTheWay          = "on the way"
+	StatusSuccess           = "success"
+	StatusCancelled         = "cancelled"
+	StatusExpired           = "expired"
+)
+
 type FormCreateSchema struct {
-	Fullname       string `schema:"fullname" validate:"required,min=3,max=100" db:"fullname"`
-	Phone          string `schema:"phone" validate:"required,phone=id" db:"phone"`
-	Address        string `schema:"address" validate:"required,min=5" db:"address"`
-	ProofOfPayment string `schema:"-" db:"proof_of_payment"`
-	TotalAmount    int    `schema:"-" db:"total_amount"`
-	UserId         int    `schema:"-" db:"user_id"`
+	Fullname       string   `schema:"fullname" validate:"required,min=3,max=100" db:"fullname"`
+	Phone          string   `schema:"phone" validate:"required,phone=id" db:"phone"`
+	Address        string   `schema:"address" validate:"required,min=5" db:"address"`
+	Province       string   `schema:"province" validate:"omitempty,min=3,max=100" db:"province"`
+	City           string   `schema:"city" validate:"omitempty,min=3,max=100" db:"city"`
+	VoucherCode    string   `schema:"voucher_code" validate:"omitempty,min=3,max=50"`
+	PaymentMethod  string   `schema:"payment_method" validate:"omitempty,oneof=manual virtual_account qris"`
+	BankCode       string   `schema:"bank_code" validate:"omitempty,oneof=bca bni bri mandiri permata"`
+	Status         string   `schema:"-" db:"status"`
+	ProofOfPayment string   `schema:"-" db:"proof_of_payment"`
+	TotalAmount    int      `schema:"-" db:"total_amount"`
+	DiscountAmount int      `schema:"-" db:"discount_amount"`
+	ShippingCost   int      `schema:"-" db:"shipping_cost"`
+	VoucherId      null.Int `schema:"-" db:"voucher_id"`
+	UserId         int      `schema:"-" db:"user_id"`
 }
 
 type QueryParamAllOrderSchema struct {
 	UserId  int    `schema:"-" db:"user_id"`
 	Page    int    `schema:"page" validate:"required,gte=1"`
 	PerPage int    `schema:"per_page" validate:"required,gte=1" db:"per_page"`
-	Status  string `schema:"status" validate:"omitempty,oneof='ongoing' 'reject' 'on the way' 'success'" db:"status"`
+	Status  string `schema:"status" validate:"omitempty,oneof='pending_payment' 'ongoing' 'reupload_requested' 'payment_verified' 'reject' 'on the way' 'success' 'cancelled' 'expired'" db:"status"`
 	Offset  int    `schema:"-" db:"offset"`
 }
 
+type JsonCancelSchema struct {
+	Reason string `json:"reason" validate:"omitempty,min=3,max=255"`
+}
+
+type FormOnTheWaySchema struct {
+	CourierCode    string `schema:"courier_code" validate:"required,oneof=jne jnt sicepat pos tiki anteraja"`
+	TrackingNumber string `schema:"tracking_number" validate:"required,min=5,max=100"`
+}
+
+type JsonRequestReuploadSchema struct {
+	Reason string `json:"reason" validate:"omitempty,min=3,max=255"`
+}
+
 type Order struct {
 	Id             int                `json:"id" db:"id"`
 	Fullname       string             `json:"fullname" db:"fullname"`
 	Phone          string             `json:"phone" db:"phone"`
 	Address        string             `json:"address" db:"address"`
+	Province       null.String        `json:"province" db:"province"`
+	City           null.String        `json:"city" db:"city"`
 	ProofOfPayment string             `json:"proof_of_payment" db:"proof_of_payment"`
 	Status         string             `json:"status" db:"status"`
 	NoReceipt      null.String        `json:"no_receipt" db:"no_receipt"`
+	CancelReason   null.String        `json:"cancel_reason" db:"cancel_reason"`
 	TotalAmount    int                `json:"total_amount" db:"total_amount"`
+	DiscountAmount int                `json:"discount_amount" db:"discount_amount"`
+	ShippingCost   int                `json:"shipping_cost" db:"shipping_cost"`
+	VoucherId      null.Int           `json:"voucher_id" db:"voucher_id"`
+	VerifiedBy     null.Int           `json:"verified_by" db:"verified_by"`
+	VerifiedAt     null.Time          `json:"verified_at" db:"verified_at"`
+	AutoCompleted  bool               `json:"auto_completed" db:"auto_completed"`
+	InvoiceNumber  null.String        `json:"invoice_number" db:"invoice_number"`
 	UserId         int                `json:"user_id" db:"user_id"`
 	OrderItems     []OrderItemProduct `json:"order_items"`
 	CreatedAt      time.Time          `json:"created_at" db:"created_at"`
@@ -55,6 +103,7 @@ type OrderItemProduct struct {
 	OrderItemsQty       int         `json:"order_items_qty" db:"order_items_qty"`
 	OrderItemsPrice     int         `json:"order_items_price" db:"order_items_price"`
 	OrderItemsProductId int         `json:"order_items_product_id" db:"order_items_product_id"`
+	OrderItemsOrderId   int         `json:"order_items_order_id" db:"order_items_order_id"`
 	OrderItemsCreatedAt time.Time   `json:"order_items_created_at" db:"order_items_created_at"`
 	OrderItemsUpdatedAt time.Time   `json:"order_items_updated_at" db:"order_items_updated_at"`
 	ProductName         string      `json:"product_name" db:"product_name"`
@@ -62,6 +111,25 @@ type OrderItemProduct struct {
 	ProductImage        string      `json:"product_image" db:"product_image"`
 }
 
+type OrderStatusHistory struct {
+	Id         int         `json:"id" db:"id"`
+	FromStatus null.String `json:"from_status" db:"from_status"`
+	ToStatus   string      `json:"to_status" db:"to_status"`
+	ActorId    null.Int    `json:"actor_id" db:"actor_id"`
+	Note       null.String `json:"note" db:"note"`
+	OrderId    int         `json:"order_id" db:"order_id"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+type OrderDetail struct {
+	Order
+	ProofOfPaymentUrl null.String              `json:"proof_of_payment_url"`
+	NoReceiptUrl      null.String              `json:"no_receipt_url"`
+	Payment           *paymentsentity.Payment  `json:"payment"`
+	Shipment          *shippingentity.Shipment `json:"shipment"`
+	StatusHistories   []OrderStatusHistory     `json:"status_histories"`
+}
+
 type OrderPaginate struct {
 	Data      []Order    `json:"data"`
 	Total     int        `json:"total"`
@@ -70,3 +138,53 @@ type OrderPaginate struct {
 	Page      int        `json:"page"`
 	IterPages []null.Int `json:"iter_pages"`
 }
+
+// InvoicePeriod is the month the invoice number is counted in
+func InvoicePeriod(t time.Time) string {
+	return t.Format("2006/01")
+}
+
+// InvoiceDate is the day the payment was verified, it decides the month of the invoice
+// number. Orders verified before the day was recorded use the day they were created
+func (o *Order) InvoiceDate() time.Time {
+	if o.VerifiedAt.Valid {
+		return o.VerifiedAt.Time
+	}
+	return o.CreatedAt
+}
+
+// InvoiceNumber format the number of the month e.g. INV/2026/10/000123
+func InvoiceNumber(period string, number int) string {
+	return fmt.Sprintf("INV/%s/%06d", period, number)
+}
+
+var (
+	ErrStatusChanged      = errors.New("order status has been changed")
+	ErrVoucherUnavailable = errors.New("voucher is no longer available")
+)
+
+type OutOfStockError struct {
+	ProductName  string
+	ProductStock int
+}
+
+func (e *OutOfStockError) Error() string {
+	return fmt.Sprintf("Available stock: %d, please reduce quantity product '%s'", e.ProductStock, e.ProductName)
+}
+
+type PriceChange struct {
+	ProductId   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	OldPrice    int    `json:"old_price"`
+	NewPrice    int    `json:"new_price"`
+}
+
+// PriceChangedError returned when the price of products in the checkout session
+// no longer the same as the live price
+type PriceChangedError struct {
+	Products []PriceChange
+}
+
+func (e *PriceChangedError) Error() string {
+	return "The price of some products has changed, please move the items to the payment again."
+}
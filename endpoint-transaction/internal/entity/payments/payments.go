@@ -0,0 +1,61 @@
+package payments
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	MethodManual         = "manual"
+	MethodVirtualAccount = "virtual_account"
+	MethodQris           = "qris"
+
+	StatusPending = "pending"
+	StatusPaid    = "paid"
+	StatusExpired = "expired"
+	StatusFailed  = "failed"
+	// StatusRefundRequired is a payment that arrived after the order left pending payment
+	StatusRefundRequired = "refund_required"
+)
+
+// Charge is what the provider needs to create a payment
+type Charge struct {
+	ExternalId string
+	Method     string
+	BankCode   string
+	Amount     int
+}
+
+type ChargeResult struct {
+	VaNumber  null.String
+	QrString  null.String
+	ExpiredAt time.Time
+}
+
+// JsonWebhookSchema is the notification sent by the payment gateway
+type JsonWebhookSchema struct {
+	ExternalId string `json:"external_id" validate:"required,max=100"`
+	Status     string `json:"status" validate:"required,oneof=paid expired failed"`
+	Amount     int    `json:"amount" validate:"required,gte=1"`
+}
+
+type Payment struct {
+	Id         int         `json:"id" db:"id"`
+	Provider   string      `json:"provider" db:"provider"`
+	Method     string      `json:"method" db:"method"`
+	BankCode   null.String `json:"bank_code" db:"bank_code"`
+	ExternalId string      `json:"external_id" db:"external_id"`
+	Amount     int         `json:"amount" db:"amount"`
+	Status     string      `json:"status" db:"status"`
+	VaNumber   null.String `json:"va_number" db:"va_number"`
+	QrString   null.String `json:"qr_string" db:"qr_string"`
+	ExpiredAt  time.Time   `json:"expired_at" db:"expired_at"`
+	PaidAt     null.Time   `json:"paid_at" db:"paid_at"`
+	OrderId    int         `json:"order_id" db:"order_id"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+var ErrPaymentProcessed = errors.New("payment has been processed")
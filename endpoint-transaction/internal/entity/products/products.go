@@ -21,6 +21,7 @@ type Product struct {
 	Image       string    `json:"image" db:"image"`
 	Price       int       `json:"price" db:"price"`
 	Stock       int       `json:"stock" db:"stock"`
+	Weight      int       `json:"weight" db:"weight"`
 	CategoryId  int       `json:"category_id" db:"category_id"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
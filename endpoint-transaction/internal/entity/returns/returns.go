@@ -0,0 +1,108 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+
+	// RefundStatusPending is a refund waiting for the money to be sent back to the buyer
+	RefundStatusPending = "pending"
+)
+
+type FormCreateSchema struct {
+	OrderId int                    `schema:"order_id" validate:"required,gte=1" db:"order_id"`
+	Reason  string                 `schema:"reason" validate:"required,min=5,max=255" db:"reason"`
+	Items   []FormCreateItemSchema `schema:"items" validate:"required,min=1,unique=OrderItemId,dive"`
+	Photo   string                 `schema:"-" db:"photo"`
+	UserId  int                    `schema:"-" db:"user_id"`
+}
+
+type FormCreateItemSchema struct {
+	OrderItemId int `schema:"order_item_id" validate:"required,gte=1"`
+	Qty         int `schema:"qty" validate:"required,gte=1"`
+}
+
+type JsonRejectSchema struct {
+	Reason string `json:"reason" validate:"omitempty,min=3,max=255"`
+}
+
+type QueryParamAllReturnSchema struct {
+	UserId  int    `schema:"-" db:"user_id"`
+	Page    int    `schema:"page" validate:"required,gte=1"`
+	PerPage int    `schema:"per_page" validate:"required,gte=1" db:"per_page"`
+	Status  string `schema:"status" validate:"omitempty,oneof=pending approved rejected" db:"status"`
+	Offset  int    `schema:"-" db:"offset"`
+}
+
+type Return struct {
+	Id           int         `json:"id" db:"id"`
+	Reason       string      `json:"reason" db:"reason"`
+	Photo        string      `json:"photo" db:"photo"`
+	Status       string      `json:"status" db:"status"`
+	RejectReason null.String `json:"reject_reason" db:"reject_reason"`
+	OrderId      int         `json:"order_id" db:"order_id"`
+	UserId       int         `json:"user_id" db:"user_id"`
+	ProcessedBy  null.Int    `json:"processed_by" db:"processed_by"`
+	ProcessedAt  null.Time   `json:"processed_at" db:"processed_at"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// ReturnItem keep the price and the product of the order item,
+// so the refund and the restock don't depend on the order anymore
+type ReturnItem struct {
+	Id          int       `json:"id" db:"id"`
+	Qty         int       `json:"qty" db:"qty"`
+	Price       int       `json:"price" db:"price"`
+	OrderItemId int       `json:"order_item_id" db:"order_item_id"`
+	ProductId   int       `json:"product_id" db:"product_id"`
+	ReturnId    int       `json:"return_id" db:"return_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type Refund struct {
+	Id        int       `json:"id" db:"id"`
+	Amount    int       `json:"amount" db:"amount"`
+	Status    string    `json:"status" db:"status"`
+	ReturnId  int       `json:"return_id" db:"return_id"`
+	OrderId   int       `json:"order_id" db:"order_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ReturnDetail struct {
+	Return
+	PhotoUrl string       `json:"photo_url"`
+	Items    []ReturnItem `json:"items"`
+	Refund   *Refund      `json:"refund"`
+}
+
+type ReturnPaginate struct {
+	Data      []Return   `json:"data"`
+	Total     int        `json:"total"`
+	NextNum   null.Int   `json:"next_num"`
+	PrevNum   null.Int   `json:"prev_num"`
+	Page      int        `json:"page"`
+	IterPages []null.Int `json:"iter_pages"`
+}
+
+var ErrReturnProcessed = errors.New("return has been processed")
+
+// ExceedQtyError returned when the qty is more than what is left to return
+// from the order item, the pending and approved returns are counted
+type ExceedQtyError struct {
+	OrderItemId int
+	Available   int
+}
+
+func (e *ExceedQtyError) Error() string {
+	return fmt.Sprintf("Available qty to return: %d, please reduce qty of order item %d.", e.Available, e.OrderItemId)
+}
@@ -0,0 +1,94 @@
+package shipping
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type JsonQuoteSchema struct {
+	Province string `json:"province" validate:"required,min=3,max=100"`
+	City     string `json:"city" validate:"required,min=3,max=100"`
+}
+
+// JsonCreateUpdateRateSchema is a weight tier managed by the admin,
+// without city the tier applies to every city in the province
+type JsonCreateUpdateRateSchema struct {
+	Id        int    `json:"-" db:"id"`
+	Province  string `json:"province" validate:"required,min=3,max=100" db:"province"`
+	City      string `json:"city" validate:"omitempty,min=3,max=100" db:"city"`
+	MaxWeight int    `json:"max_weight" validate:"required,gte=1" db:"max_weight"`
+	Cost      int    `json:"cost" validate:"gte=0" db:"cost"`
+}
+
+type QueryParamAllRateSchema struct {
+	Page    int    `schema:"page" validate:"required,gte=1"`
+	PerPage int    `schema:"per_page" validate:"required,gte=1" db:"per_page"`
+	Q       string `schema:"q" db:"q"`
+	Offset  int    `schema:"-" db:"offset"`
+}
+
+// Rate is a weight tier of the table provider, rate without city
+// applies to every city in the province
+type Rate struct {
+	Id        int         `json:"id" db:"id"`
+	Province  string      `json:"province" db:"province"`
+	City      null.String `json:"city" db:"city"`
+	MaxWeight int         `json:"max_weight" db:"max_weight"`
+	Cost      int         `json:"cost" db:"cost"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+type RatePaginate struct {
+	Data      []Rate     `json:"data"`
+	Total     int        `json:"total"`
+	NextNum   null.Int   `json:"next_num"`
+	PrevNum   null.Int   `json:"prev_num"`
+	Page      int        `json:"page"`
+	IterPages []null.Int `json:"iter_pages"`
+}
+
+type Quote struct {
+	Provider string `json:"provider"`
+	Province string `json:"province"`
+	City     string `json:"city"`
+	Weight   int    `json:"weight"`
+	Cost     int    `json:"cost"`
+}
+
+var ErrNotCovered = errors.New("destination is not covered by the provider")
+
+const (
+	CourierJne      = "jne"
+	CourierJnt      = "jnt"
+	CourierSicepat  = "sicepat"
+	CourierPos      = "pos"
+	CourierTiki     = "tiki"
+	CourierAnteraja = "anteraja"
+)
+
+// Shipment is the package of an order handed over to the courier
+type Shipment struct {
+	Id             int         `json:"id" db:"id"`
+	CourierCode    string      `json:"courier_code" db:"courier_code"`
+	TrackingNumber string      `json:"tracking_number" db:"tracking_number"`
+	ShippedAt      time.Time   `json:"shipped_at" db:"shipped_at"`
+	ReceiptPhoto   null.String `json:"receipt_photo" db:"receipt_photo"`
+	OrderId        int         `json:"order_id" db:"order_id"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+type TrackingEvent struct {
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+type Tracking struct {
+	Shipment
+	Delivered bool            `json:"delivered"`
+	Events    []TrackingEvent `json:"events"`
+}
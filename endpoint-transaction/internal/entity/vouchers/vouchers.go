@@ -0,0 +1,101 @@
+package vouchers
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	KindPercentage = "percentage"
+	KindFixed      = "fixed"
+)
+
+type JsonCreateUpdateSchema struct {
+	Id                int           `json:"-" db:"id"`
+	Code              string        `json:"code" validate:"required,min=3,max=50" db:"code"`
+	Kind              string        `json:"kind" validate:"required,oneof=percentage fixed" db:"kind"`
+	Amount            int           `json:"amount" validate:"required,gte=1" db:"amount"`
+	MaxDiscount       int           `json:"max_discount" validate:"omitempty,gte=1" db:"max_discount"`
+	MinSpend          int           `json:"min_spend" validate:"omitempty,gte=1" db:"min_spend"`
+	UsageLimit        int           `json:"usage_limit" validate:"omitempty,gte=1" db:"usage_limit"`
+	UsageLimitPerUser int           `json:"usage_limit_per_user" validate:"omitempty,gte=1" db:"usage_limit_per_user"`
+	CategoryIds       pq.Int64Array `json:"category_ids" validate:"omitempty,unique,dive,required,min=1" db:"category_ids"`
+	ProductIds        pq.Int64Array `json:"product_ids" validate:"omitempty,unique,dive,required,min=1" db:"product_ids"`
+	StartedAt         time.Time     `json:"started_at" validate:"required" db:"started_at"`
+	ExpiredAt         time.Time     `json:"expired_at" validate:"required" db:"expired_at"`
+}
+
+type QueryParamAllVoucherSchema struct {
+	Page    int    `schema:"page" validate:"required,gte=1"`
+	PerPage int    `schema:"per_page" validate:"required,gte=1" db:"per_page"`
+	Q       string `schema:"q" db:"q"`
+	Offset  int    `schema:"-" db:"offset"`
+}
+
+type Voucher struct {
+	Id                int           `json:"id" db:"id"`
+	Code              string        `json:"code" db:"code"`
+	Kind              string        `json:"kind" db:"kind"`
+	Amount            int           `json:"amount" db:"amount"`
+	MaxDiscount       int           `json:"max_discount" db:"max_discount"`
+	MinSpend          int           `json:"min_spend" db:"min_spend"`
+	UsageLimit        int           `json:"usage_limit" db:"usage_limit"`
+	UsageLimitPerUser int           `json:"usage_limit_per_user" db:"usage_limit_per_user"`
+	CategoryIds       pq.Int64Array `json:"category_ids" db:"category_ids"`
+	ProductIds        pq.Int64Array `json:"product_ids" db:"product_ids"`
+	StartedAt         time.Time     `json:"started_at" db:"started_at"`
+	ExpiredAt         time.Time     `json:"expired_at" db:"expired_at"`
+	CreatedAt         time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+type VoucherUsageCount struct {
+	Total int `db:"total"`
+	User  int `db:"user_total"`
+}
+
+type VoucherPaginate struct {
+	Data      []Voucher  `json:"data"`
+	Total     int        `json:"total"`
+	NextNum   null.Int   `json:"next_num"`
+	PrevNum   null.Int   `json:"prev_num"`
+	Page      int        `json:"page"`
+	IterPages []null.Int `json:"iter_pages"`
+}
+
+// InScope tells whether a product can get the discount,
+// voucher without category and product scope applies to every product
+func (v *Voucher) InScope(productId, categoryId int) bool {
+	if len(v.CategoryIds) < 1 && len(v.ProductIds) < 1 {
+		return true
+	}
+	for _, id := range v.ProductIds {
+		if int(id) == productId {
+			return true
+		}
+	}
+	for _, id := range v.CategoryIds {
+		if int(id) == categoryId {
+			return true
+		}
+	}
+	return false
+}
+
+// Discount calculate the discount of the eligible subtotal,
+// it never goes above the subtotal itself
+func (v *Voucher) Discount(subtotal int) int {
+	discount := v.Amount
+	if v.Kind == KindPercentage {
+		discount = subtotal * v.Amount / 100
+		if v.MaxDiscount > 0 && discount > v.MaxDiscount {
+			discount = v.MaxDiscount
+		}
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount
+}
@@ -0,0 +1,145 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// column widths of the item table in mm, the page is A4 with 15 mm margins
+var columns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"No", 10, "C"},
+	{"Product", 80, "L"},
+	{"Qty", 20, "C"},
+	{"Price", 35, "R"},
+	{"Subtotal", 35, "R"},
+}
+
+// Render will write the invoice of the order as pdf into w,
+// the order must already have the invoice number
+func Render(w io.Writer, order *ordersentity.Order, items []ordersentity.OrderItemProduct) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetTitle(order.InvoiceNumber.String, true)
+	pdf.AddPage()
+
+	// the core fonts only know cp1252, translate the text of the buyer
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// header
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(90, 10, "INVOICE", "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(90, 5, order.InvoiceNumber.String, "", 2, "R", false, 0, "")
+	pdf.CellFormat(90, 5, order.CreatedAt.Format("02 January 2006"), "", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	// buyer
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Bill To", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, tr(order.Fullname), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr(order.Phone), "", 1, "L", false, 0, "")
+	pdf.MultiCell(0, 5, tr(order.Address), "", "L", false)
+	var region []string
+	for _, v := range []string{order.City.String, order.Province.String} {
+		if len(v) > 0 {
+			region = append(region, v)
+		}
+	}
+	if len(region) > 0 {
+		pdf.CellFormat(0, 5, tr(strings.Join(region, ", ")), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 5, fmt.Sprintf("Order #%d - %s", order.Id, order.Status), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	// items
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for _, c := range columns {
+		pdf.CellFormat(c.width, 7, c.title, "1", 0, c.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	subtotal := 0
+	for i, item := range items {
+		amount := item.OrderItemsQty * item.OrderItemsPrice
+		subtotal += amount
+
+		values := []string{
+			fmt.Sprintf("%d", i+1),
+			fit(pdf, tr(item.ProductName), columns[1].width-2),
+			fmt.Sprintf("%d", item.OrderItemsQty),
+			Rupiah(item.OrderItemsPrice),
+			Rupiah(amount),
+		}
+		for j, c := range columns {
+			pdf.CellFormat(c.width, 7, values[j], "1", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(2)
+
+	// totals
+	totals := []struct {
+		label  string
+		amount string
+	}{
+		{"Subtotal", Rupiah(subtotal)},
+		{"Shipping Cost", Rupiah(order.ShippingCost)},
+		{"Discount", "- " + Rupiah(order.DiscountAmount)},
+		{"Total", Rupiah(order.TotalAmount)},
+	}
+	for i, t := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 11)
+		}
+		pdf.CellFormat(145, 7, t.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 7, t.amount, "", 1, "R", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// fit cut the text with an ellipsis so it stays in a cell of width
+func fit(pdf *gofpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+// Rupiah format the amount with the thousand separator e.g. Rp 1.250.000
+func Rupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := fmt.Sprintf("%d", amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+
+	return sign + "Rp " + b.String()
+}
+
+// Filename is the name of the downloaded file, the slashes of the number are not allowed
+func Filename(invoiceNumber string) string {
+	return strings.ReplaceAll(invoiceNumber, "/", "-") + ".pdf"
+}
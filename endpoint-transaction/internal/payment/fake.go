@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Fake create the charge without calling anything, used on development and tests,
+// the payment is settled by sending the webhook signed with the webhook secret
+type Fake struct {
+	expires time.Duration
+}
+
+func NewFake(expires time.Duration) *Fake {
+	return &Fake{expires: expires}
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Charge(ctx context.Context, payload *paymentsentity.Charge) (*paymentsentity.ChargeResult, error) {
+	result := &paymentsentity.ChargeResult{ExpiredAt: time.Now().Add(f.expires)}
+	checksum := crc32.ChecksumIEEE([]byte(payload.ExternalId))
+
+	switch payload.Method {
+	case paymentsentity.MethodVirtualAccount:
+		result.VaNumber = null.StringFrom(fmt.Sprintf("8808%010d", checksum))
+	case paymentsentity.MethodQris:
+		result.QrString = null.StringFrom(fmt.Sprintf("FAKEQRIS.%s.%d", payload.ExternalId, payload.Amount))
+	default:
+		return nil, fmt.Errorf("payment method %q is not supported", payload.Method)
+	}
+
+	return result, nil
+}
+
+// Void has nothing to do, the fake charge only lives in the response
+func (f *Fake) Void(ctx context.Context, payload *paymentsentity.Charge) error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Gateway create virtual account and QRIS charges through the payment gateway api,
+// it authenticates with the server key as the basic auth username
+type Gateway struct {
+	baseURL   string
+	serverKey string
+	expires   time.Duration
+	client    *http.Client
+}
+
+type gatewayRequest struct {
+	ExternalId string    `json:"external_id"`
+	Amount     int       `json:"amount"`
+	BankCode   string    `json:"bank_code,omitempty"`
+	ExpiredAt  time.Time `json:"expiration_date"`
+}
+
+type gatewayResponse struct {
+	AccountNumber string    `json:"account_number"`
+	QrString      string    `json:"qr_string"`
+	ExpiredAt     time.Time `json:"expiration_date"`
+	Message       string    `json:"message"`
+}
+
+func NewGateway(baseURL, serverKey string, timeout, expires time.Duration) *Gateway {
+	return &Gateway{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		serverKey: serverKey,
+		expires:   expires,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (g *Gateway) Name() string { return "gateway" }
+
+// chargePath is the endpoint of the charge for the payment method
+func chargePath(method string) (string, error) {
+	switch method {
+	case paymentsentity.MethodVirtualAccount:
+		return "/callback_virtual_accounts", nil
+	case paymentsentity.MethodQris:
+		return "/qr_codes", nil
+	}
+	return "", fmt.Errorf("payment method %q is not supported", method)
+}
+
+// do send the request to the gateway, the response is only decoded when it succeeds
+func (g *Gateway) do(ctx context.Context, url string, payload interface{}, data *gatewayResponse) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(g.serverKey, "")
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// an error page of a proxy is not json, keep the status code as the reason
+	if res.StatusCode >= 300 {
+		var failed gatewayResponse
+		json.NewDecoder(res.Body).Decode(&failed)
+		return fmt.Errorf("payment gateway responded %d: %s", res.StatusCode, failed.Message)
+	}
+
+	return json.NewDecoder(res.Body).Decode(data)
+}
+
+func (g *Gateway) Charge(ctx context.Context, payload *paymentsentity.Charge) (*paymentsentity.ChargeResult, error) {
+	path, err := chargePath(payload.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	var data gatewayResponse
+	err = g.do(ctx, g.baseURL+path, gatewayRequest{
+		ExternalId: payload.ExternalId,
+		Amount:     payload.Amount,
+		BankCode:   strings.ToUpper(payload.BankCode),
+		ExpiredAt:  time.Now().Add(g.expires).UTC(),
+	}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &paymentsentity.ChargeResult{
+		VaNumber:  null.NewString(data.AccountNumber, len(data.AccountNumber) > 0),
+		QrString:  null.NewString(data.QrString, len(data.QrString) > 0),
+		ExpiredAt: data.ExpiredAt,
+	}, nil
+}
+
+// Void expire the charge right away so the buyer can no longer pay it
+func (g *Gateway) Void(ctx context.Context, payload *paymentsentity.Charge) error {
+	path, err := chargePath(payload.Method)
+	if err != nil {
+		return err
+	}
+
+	var data gatewayResponse
+	return g.do(ctx, fmt.Sprintf("%s%s/%s/expire", g.baseURL, path, payload.ExternalId), struct{}{}, &data)
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+)
+
+// SignatureHeader carry the hex HMAC-SHA256 of the webhook body
+const SignatureHeader = "X-Signature"
+
+// PaymentProvider create the charge of an order on the payment gateway,
+// the result is sent back to the buyer as the payment instruction. A charge
+// whose order cannot be saved is voided
+type PaymentProvider interface {
+	Name() string
+	Charge(ctx context.Context, payload *paymentsentity.Charge) (*paymentsentity.ChargeResult, error)
+	Void(ctx context.Context, payload *paymentsentity.Charge) error
+}
+
+// New will pick the provider from the config
+func New(cfg *config.Config) (PaymentProvider, error) {
+	switch cfg.Payment.Provider {
+	case "gateway":
+		return NewGateway(cfg.Payment.BaseURL, cfg.Payment.ServerKey, cfg.Payment.Timeouts, cfg.Payment.Expires), nil
+	case "fake":
+		return NewFake(cfg.Payment.Expires), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", cfg.Payment.Provider)
+}
+
+// Sign return the signature of the body with the webhook secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compare the signature in constant time
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -2,13 +2,11 @@ package carts
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"time"
 
 	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
-	"github.com/creent-production/cdk-go/parser"
-	"github.com/gomodule/redigo/redis"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type RepoCarts struct {
@@ -18,11 +16,16 @@ type RepoCarts struct {
 }
 
 var queries = map[string]string{
-	"getCartByDynamic": `SELECT id, notes, qty, user_id, product_id FROM transaction.carts`,
+	"getCartByDynamic":     `SELECT id, notes, qty, user_id, product_id FROM transaction.carts`,
+	"getCheckoutByDynamic": `SELECT id, user_id, expired_at, created_at, updated_at FROM transaction.checkouts`,
 }
 var execs = map[string]string{
-	"updateCart": `UPDATE transaction.carts SET qty=:qty, user_id=:user_id, product_id=:product_id, notes=:notes WHERE id = :id`,
-	"deleteCart": `DELETE FROM transaction.carts WHERE user_id = :user_id`,
+	"updateCart":         `UPDATE transaction.carts SET qty=:qty, user_id=:user_id, product_id=:product_id, notes=:notes WHERE id = :id`,
+	"deleteCart":         `DELETE FROM transaction.carts WHERE user_id = :user_id`,
+	"insertCheckout":     `INSERT INTO transaction.checkouts (user_id, expired_at) VALUES (:user_id, (CURRENT_TIMESTAMP + :expires * INTERVAL '1 second')) RETURNING id`,
+	"insertCheckoutItem": `INSERT INTO transaction.checkout_items (notes, qty, price, cart_id, product_id, checkout_id) SELECT transaction.carts.notes, transaction.carts.qty, product.products.price, transaction.carts.id, transaction.carts.product_id, :checkout_id FROM transaction.carts INNER JOIN product.products ON product.products.id = transaction.carts.product_id WHERE transaction.carts.user_id = :user_id AND transaction.carts.id = ANY(:ids)`,
+	"deleteCheckout":     `DELETE FROM transaction.checkouts WHERE user_id = :user_id`,
+	"deleteCheckoutItem": `DELETE FROM transaction.checkout_items WHERE checkout_id IN (SELECT id FROM transaction.checkouts WHERE user_id = :user_id)`,
 }
 
 func New(db *sqlx.DB) (*RepoCarts, error) {
@@ -81,7 +84,10 @@ SELECT
 	product.products.slug as product_slug,
 	product.products.image as product_image,
 	product.products.price as product_price,
-	product.products.stock as product_stock
+	product.products.price as product_current_price,
+	product.products.stock as product_stock,
+	product.products.weight as product_weight,
+	product.products.category_id as product_category_id
 FROM
     transaction.carts
 INNER JOIN product.products ON product.products.id = transaction.carts.product_id
@@ -138,11 +144,8 @@ func (r *RepoCarts) Update(ctx context.Context, payload *cartsentity.Cart) error
 }
 
 func (r *RepoCarts) Delete(ctx context.Context, payload *cartsentity.JsonMultipleSchema) error {
-	query := r.execs["deleteCart"]
-	query += fmt.Sprintf(` AND id IN (%s)`, strings.Join(parser.ParseSliceIntToSliceStr(payload.ListId), ","))
-
-	stmt, _ := r.db.PrepareNamedContext(ctx, query)
-	_, err := stmt.ExecContext(ctx, payload)
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteCart"]+` AND id = ANY(:ids)`)
+	_, err := stmt.ExecContext(ctx, map[string]interface{}{"user_id": payload.UserId, "ids": toInt64Array(payload.ListId)})
 	if err != nil {
 		return err
 	}
@@ -160,61 +163,92 @@ func (r *RepoCarts) DeleteByUserId(ctx context.Context, userId int) error {
 
 }
 
-func (r *RepoCarts) MoveItemToPayment(ctx context.Context, redisCli *redis.Pool, payload *cartsentity.JsonMultipleSchema) {
-	var (
-		results []cartsentity.Cart
-		data    []int
-	)
-
-	query := r.queries["getCartByDynamic"]
-	query += ` WHERE user_id = :user_id`
-	query += fmt.Sprintf(` AND id IN (%s)`, strings.Join(parser.ParseSliceIntToSliceStr(payload.ListId), ","))
+// MoveItemToPayment will replace the checkout session of the user with the selected carts
+// that expires after expires, every line keeps the qty, notes and price of the product
+// at checkout time
+func (r *RepoCarts) MoveItemToPayment(ctx context.Context, payload *cartsentity.JsonMultipleSchema, expires time.Duration) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	for _, exec := range []string{"deleteCheckoutItem", "deleteCheckout"} {
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs[exec])
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, payload); err != nil {
+			return err
+		}
+	}
 
-	stmt.SelectContext(ctx, &results, payload)
-	for _, v := range results {
-		data = append(data, v.Id)
+	var checkoutId int
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertCheckout"])
+	if err != nil {
+		return err
+	}
+	err = stmt.QueryRowxContext(ctx, map[string]interface{}{
+		"user_id": payload.UserId,
+		"expires": int(expires.Seconds()),
+	}).Scan(&checkoutId)
+	if err != nil {
+		return err
 	}
 
-	conn := redisCli.Get()
-	defer conn.Close()
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertCheckoutItem"])
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, map[string]interface{}{
+		"checkout_id": checkoutId,
+		"user_id":     payload.UserId,
+		"ids":         toInt64Array(payload.ListId),
+	})
+	if err != nil {
+		return err
+	}
 
-	conn.Do("SETEX", fmt.Sprintf("checkout:%d", payload.UserId), 86400, strings.Join(parser.ParseSliceIntToSliceStr(data), ","))
+	return tx.Commit()
 }
 
-func (r *RepoCarts) ItemInPayment(ctx context.Context, redisCli *redis.Pool, userId int) ([]cartsentity.CartProduct, error) {
-	conn := redisCli.Get()
-	defer conn.Close()
+func (r *RepoCarts) GetCheckoutByUserId(ctx context.Context, userId int) (*cartsentity.Checkout, error) {
+	var t cartsentity.Checkout
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getCheckoutByDynamic"]+" WHERE user_id = :user_id AND expired_at > CURRENT_TIMESTAMP")
 
-	item, _ := redis.String(conn.Do("GET", fmt.Sprintf("checkout:%d", userId)))
-	if len(item) < 1 {
-		item = "0"
-	}
+	return &t, stmt.GetContext(ctx, &t, cartsentity.Checkout{UserId: userId})
+}
 
+// ItemInPayment will get the lines of the active checkout session that still in the cart,
+// product_price is the price at checkout time and product_current_price is the live one
+func (r *RepoCarts) ItemInPayment(ctx context.Context, userId int) ([]cartsentity.CartProduct, error) {
 	var results []cartsentity.CartProduct
 
 	query := `
 SELECT
-    transaction.carts.id as cart_id,
-    transaction.carts.notes as cart_notes,
-    transaction.carts.qty as cart_qty,
-	transaction.carts.user_id as cart_user_id,
-	transaction.carts.product_id as cart_product_id,
+    transaction.checkout_items.cart_id as cart_id,
+    transaction.checkout_items.notes as cart_notes,
+    transaction.checkout_items.qty as cart_qty,
+	transaction.checkouts.user_id as cart_user_id,
+	transaction.checkout_items.product_id as cart_product_id,
 	product.products.name as product_name,
 	product.products.slug as product_slug,
 	product.products.image as product_image,
-	product.products.price as product_price,
-	product.products.stock as product_stock
+	transaction.checkout_items.price as product_price,
+	product.products.price as product_current_price,
+	product.products.stock as product_stock,
+	product.products.weight as product_weight,
+	product.products.category_id as product_category_id
 FROM
-    transaction.carts
-INNER JOIN product.products ON product.products.id = transaction.carts.product_id
-WHERE transaction.carts.user_id = :user_id AND product.products.stock > 0
+    transaction.checkout_items
+INNER JOIN transaction.checkouts ON transaction.checkouts.id = transaction.checkout_items.checkout_id
+INNER JOIN transaction.carts ON transaction.carts.id = transaction.checkout_items.cart_id
+INNER JOIN product.products ON product.products.id = transaction.checkout_items.product_id
+WHERE transaction.checkouts.user_id = :user_id AND transaction.checkouts.expired_at > CURRENT_TIMESTAMP
+AND product.products.stock > 0
+ORDER BY transaction.checkout_items.cart_id DESC
 	`
 
-	query += fmt.Sprintf(` AND transaction.carts.id IN (%s)`, item)
-	query += ` ORDER BY transaction.carts.id DESC`
-
 	stmt, _ := r.db.PrepareNamedContext(ctx, query)
 	err := stmt.SelectContext(ctx, &results, cartsentity.Cart{UserId: userId})
 	if err != nil {
@@ -223,3 +257,11 @@ WHERE transaction.carts.user_id = :user_id AND product.products.stock > 0
 
 	return results, nil
 }
+
+func toInt64Array(values []int) pq.Int64Array {
+	results := make(pq.Int64Array, len(values))
+	for i, v := range values {
+		results[i] = int64(v)
+	}
+	return results
+}
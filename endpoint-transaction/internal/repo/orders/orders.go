@@ -2,12 +2,21 @@ package orders
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/emails"
 	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	productsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/products"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
 	"github.com/creent-production/cdk-go/pagination"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4"
 )
 
 type RepoOrders struct {
@@ -17,11 +26,45 @@ type RepoOrders struct {
 }
 
 var queries = map[string]string{
-	"getOrderByDynamic": `SELECT id, fullname, phone, address, proof_of_payment, status, no_receipt, total_amount, user_id, created_at, updated_at FROM transaction.orders`,
+	"getOrderByDynamic":         `SELECT id, fullname, phone, address, province, city, proof_of_payment, status, no_receipt, cancel_reason, total_amount, discount_amount, shipping_cost, voucher_id, verified_by, verified_at, auto_completed, invoice_number, user_id, created_at, updated_at FROM transaction.orders`,
+	"getStatusHistoryByDynamic": `SELECT id, from_status, to_status, actor_id, note, order_id, created_at FROM transaction.order_status_histories`,
+	"getOrderItemProduct": `
+SELECT
+	transaction.order_items.id as order_items_id,
+	transaction.order_items.notes as order_items_notes,
+	transaction.order_items.qty as order_items_qty,
+	transaction.order_items.price as order_items_price,
+	transaction.order_items.product_id as order_items_product_id,
+	transaction.order_items.order_id as order_items_order_id,
+	transaction.order_items.created_at as order_items_created_at,
+	transaction.order_items.updated_at as order_items_updated_at,
+	product.products.name as product_name,
+	product.products.slug as product_slug,
+	product.products.image as product_image
+FROM transaction.order_items
+INNER JOIN product.products ON product.products.id = transaction.order_items.product_id`,
+	"getInvoiceNumber":     `SELECT invoice_number, verified_at, created_at FROM transaction.orders WHERE id = :id`,
+	"getShipmentByDynamic": `SELECT id, courier_code, tracking_number, shipped_at, receipt_photo, order_id, created_at, updated_at FROM transaction.shipments`,
+	"lockProductStock":     `SELECT id, name, price, stock FROM product.products WHERE id = ANY(:ids) ORDER BY id FOR UPDATE`,
+	"lockVoucher":          `SELECT usage_limit, usage_limit_per_user, (SELECT count(*) FROM transaction.voucher_usages WHERE voucher_id = :id) AS total, (SELECT count(*) FROM transaction.voucher_usages WHERE voucher_id = :id AND user_id = :user_id) AS user_total FROM transaction.vouchers WHERE id = :id FOR UPDATE`,
 }
 var execs = map[string]string{
-	"insertOrder":     `INSERT INTO transaction.orders (fullname, phone, address, proof_of_payment, total_amount, user_id) VALUES (:fullname, :phone, :address, :proof_of_payment, :total_amount, :user_id) RETURNING id`,
-	"insertOrderItem": `INSERT INTO transaction.order_items (notes, qty, price, product_id, order_id) VALUES (:notes, :qty, :price, :product_id, :order_id) RETURNING id`,
+	"insertOrder":         `INSERT INTO transaction.orders (fullname, phone, address, province, city, proof_of_payment, status, total_amount, discount_amount, shipping_cost, voucher_id, user_id) VALUES (:fullname, :phone, :address, NULLIF(:province, ''), NULLIF(:city, ''), :proof_of_payment, :status, :total_amount, :discount_amount, :shipping_cost, :voucher_id, :user_id) RETURNING id`,
+	"insertOrderItem":     `INSERT INTO transaction.order_items (notes, qty, price, product_id, order_id) VALUES (:notes, :qty, :price, :product_id, :order_id) RETURNING id`,
+	"decrementStock":      `UPDATE product.products SET stock = stock - :qty WHERE id = :product_id`,
+	"restoreStock":        `UPDATE product.products SET stock = product.products.stock + items.qty FROM (SELECT product_id, SUM(qty) AS qty FROM transaction.order_items WHERE order_id = :id GROUP BY product_id) AS items WHERE product.products.id = items.product_id`,
+	"insertStatusHistory": `INSERT INTO transaction.order_status_histories (from_status, to_status, actor_id, note, order_id) VALUES (:from_status, :to_status, :actor_id, :note, :order_id)`,
+	"deleteCheckoutCart":  `DELETE FROM transaction.carts WHERE user_id = :user_id AND id = ANY(:ids)`,
+	"deleteCheckoutItem":  `DELETE FROM transaction.checkout_items WHERE checkout_id IN (SELECT id FROM transaction.checkouts WHERE user_id = :user_id)`,
+	"deleteCheckout":      `DELETE FROM transaction.checkouts WHERE user_id = :user_id`,
+	"insertVoucherUsage":  `INSERT INTO transaction.voucher_usages (discount_amount, voucher_id, user_id, order_id) VALUES (:discount_amount, :voucher_id, :user_id, :order_id)`,
+	"deleteVoucherUsage":  `DELETE FROM transaction.voucher_usages WHERE order_id = :id`,
+	"insertPayment":       `INSERT INTO transaction.payments (provider, method, bank_code, external_id, amount, va_number, qr_string, expired_at, order_id) VALUES (:provider, :method, :bank_code, :external_id, :amount, :va_number, :qr_string, :expired_at, :order_id) RETURNING id`,
+	"insertShipment":      `INSERT INTO transaction.shipments (courier_code, tracking_number, receipt_photo, order_id) VALUES (:courier_code, :tracking_number, :receipt_photo, :order_id)`,
+	"nextInvoiceNumber":   `INSERT INTO transaction.invoice_sequences (period, last_number) VALUES (:period, 1) ON CONFLICT (period) DO UPDATE SET last_number = transaction.invoice_sequences.last_number + 1, updated_at = CURRENT_TIMESTAMP RETURNING last_number`,
+	"assignInvoiceNumber": `UPDATE transaction.orders SET invoice_number=:invoice_number WHERE id = :id AND invoice_number IS NULL`,
+	"insertEmail":         `INSERT INTO transaction.email_outbox (event, order_status, order_id) VALUES (:event, :order_status, :order_id)`,
+	"settlePayment":       `UPDATE transaction.payments SET updated_at=CURRENT_TIMESTAMP, status=:status, paid_at=:paid_at WHERE id = :id AND status = 'pending'`,
 }
 
 func New(db *sqlx.DB) (*RepoOrders, error) {
@@ -72,6 +115,27 @@ func (r *RepoOrders) GetOrderByUserIdLimit(ctx context.Context, userId int) (*or
 	return &t, stmt.GetContext(ctx, &t, ordersentity.Order{UserId: userId})
 }
 
+// GetOrderStaleByStatus will fetch the orders that stay in status longer than the
+// deadline, the oldest first
+func (r *RepoOrders) GetOrderStaleByStatus(ctx context.Context, status string, deadline time.Duration, limit int) ([]ordersentity.Order, error) {
+	var results []ordersentity.Order
+
+	query := r.queries["getOrderByDynamic"] + ` WHERE status = :status
+AND updated_at < CURRENT_TIMESTAMP - :deadline * INTERVAL '1 second' ORDER BY updated_at ASC LIMIT :limit`
+
+	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	err := stmt.SelectContext(ctx, &results, map[string]interface{}{
+		"status":   status,
+		"deadline": int(deadline.Seconds()),
+		"limit":    limit,
+	})
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
 func (r *RepoOrders) Insert(ctx context.Context, payload *ordersentity.FormCreateSchema) int {
 	var id int
 	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertOrder"])
@@ -88,6 +152,447 @@ func (r *RepoOrders) InsertItem(ctx context.Context, payload *ordersentity.Order
 	return id
 }
 
+// Create will save the order with the items in a single transaction, the product rows
+// are locked until commit so concurrent orders cannot oversell the stock.
+// payment is nil when the order paid with manual transfer
+func (r *RepoOrders) Create(ctx context.Context, payload *ordersentity.FormCreateSchema,
+	items []ordersentity.OrderItem, cartIds []int, payment *paymentsentity.Payment) (int, error) {
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// sum qty per product and lock the rows
+	qtys := make(map[int]int)
+	var productIds pq.Int64Array
+	for _, item := range items {
+		if _, ok := qtys[item.ProductId]; !ok {
+			productIds = append(productIds, int64(item.ProductId))
+		}
+		qtys[item.ProductId] += item.Qty
+	}
+
+	var products []productsentity.Product
+	stmt, err := tx.PrepareNamedContext(ctx, r.queries["lockProductStock"])
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.SelectContext(ctx, &products, map[string]interface{}{"ids": productIds}); err != nil {
+		return 0, err
+	}
+
+	// the price must still the same as the snapshot in the checkout session
+	lockedProducts := make(map[int]productsentity.Product)
+	for _, product := range products {
+		lockedProducts[product.Id] = product
+	}
+	var changes []ordersentity.PriceChange
+	for _, item := range items {
+		if product := lockedProducts[item.ProductId]; item.Price != product.Price {
+			changes = append(changes, ordersentity.PriceChange{
+				ProductId:   item.ProductId,
+				ProductName: product.Name,
+				OldPrice:    item.Price,
+				NewPrice:    product.Price,
+			})
+		}
+	}
+	if len(changes) > 0 {
+		return 0, &ordersentity.PriceChangedError{Products: changes}
+	}
+
+	for _, product := range products {
+		if qtys[product.Id] > product.Stock {
+			return 0, &ordersentity.OutOfStockError{ProductName: product.Name, ProductStock: product.Stock}
+		}
+
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs["decrementStock"])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, ordersentity.OrderItem{Qty: qtys[product.Id], ProductId: product.Id}); err != nil {
+			return 0, err
+		}
+	}
+
+	// lock the voucher so the usage limit cannot be passed by concurrent orders
+	if payload.VoucherId.Valid {
+		var usage struct {
+			UsageLimit        int `db:"usage_limit"`
+			UsageLimitPerUser int `db:"usage_limit_per_user"`
+			Total             int `db:"total"`
+			UserTotal         int `db:"user_total"`
+		}
+		stmt, err := tx.PrepareNamedContext(ctx, r.queries["lockVoucher"])
+		if err != nil {
+			return 0, err
+		}
+		err = stmt.GetContext(ctx, &usage, map[string]interface{}{"id": payload.VoucherId, "user_id": payload.UserId})
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ordersentity.ErrVoucherUnavailable
+		}
+		if err != nil {
+			return 0, err
+		}
+		if (usage.UsageLimit > 0 && usage.Total >= usage.UsageLimit) ||
+			(usage.UsageLimitPerUser > 0 && usage.UserTotal >= usage.UsageLimitPerUser) {
+			return 0, ordersentity.ErrVoucherUnavailable
+		}
+	}
+
+	// insert order and the items
+	var orderId int
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertOrder"])
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.QueryRowxContext(ctx, payload).Scan(&orderId); err != nil {
+		return 0, err
+	}
+
+	if payload.VoucherId.Valid {
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertVoucherUsage"])
+		if err != nil {
+			return 0, err
+		}
+		_, err = stmt.ExecContext(ctx, map[string]interface{}{
+			"discount_amount": payload.DiscountAmount,
+			"voucher_id":      payload.VoucherId,
+			"user_id":         payload.UserId,
+			"order_id":        orderId,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertOrderItem"])
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		item.OrderId = orderId
+		if _, err := stmt.ExecContext(ctx, item); err != nil {
+			return 0, err
+		}
+	}
+
+	if payment != nil {
+		payment.OrderId = orderId
+		stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertPayment"])
+		if err != nil {
+			return 0, err
+		}
+		if err := stmt.QueryRowxContext(ctx, payment).Scan(&payment.Id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := r.insertStatusHistory(ctx, tx, &ordersentity.OrderStatusHistory{
+		ToStatus: payload.Status,
+		ActorId:  null.IntFrom(int64(payload.UserId)),
+		OrderId:  orderId,
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := r.insertEmail(ctx, tx, &emailsentity.Email{
+		Event:       emailsentity.EventOrderCreated,
+		OrderStatus: payload.Status,
+		OrderId:     orderId,
+	}); err != nil {
+		return 0, err
+	}
+
+	// remove carts that already ordered
+	var listId pq.Int64Array
+	for _, id := range cartIds {
+		listId = append(listId, int64(id))
+	}
+	// the carts and checkout session are done once the order is placed
+	for _, exec := range []string{"deleteCheckoutCart", "deleteCheckoutItem", "deleteCheckout"} {
+		stmt, err = tx.PrepareNamedContext(ctx, r.execs[exec])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, map[string]interface{}{"user_id": payload.UserId, "ids": listId}); err != nil {
+			return 0, err
+		}
+	}
+
+	return orderId, tx.Commit()
+}
+
+// UpdateStatus will move the order from history.FromStatus to payload.Status and record
+// the change into status histories, it fails with ErrStatusChanged when the order
+// already left the from status. When restoreStock is true the qty of the order
+// items is given back to the product stock and the voucher usage of the order is
+// released in the same transaction
+func (r *RepoOrders) UpdateStatus(ctx context.Context, payload *ordersentity.Order,
+	history *ordersentity.OrderStatusHistory, restoreStock bool) error {
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.updateStatus(ctx, tx, payload, history, restoreStock); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SettlePayment will save the final status of the payment and move the order in the
+// same transaction, it fails with ErrPaymentProcessed when the payment is no longer
+// pending so a replayed notification cannot move the order twice
+func (r *RepoOrders) SettlePayment(ctx context.Context, payment *paymentsentity.Payment,
+	payload *ordersentity.Order, history *ordersentity.OrderStatusHistory, restoreStock bool) error {
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["settlePayment"])
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, payment)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return paymentsentity.ErrPaymentProcessed
+	}
+
+	if err := r.updateStatus(ctx, tx, payload, history, restoreStock); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Ship will move the order into on the way and save the shipment in the same transaction
+func (r *RepoOrders) Ship(ctx context.Context, payload *ordersentity.Order,
+	history *ordersentity.OrderStatusHistory, shipment *shippingentity.Shipment) error {
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.updateStatus(ctx, tx, payload, history, false); err != nil {
+		return err
+	}
+
+	shipment.OrderId = payload.Id
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertShipment"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, shipment); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RepoOrders) GetShipmentByOrderId(ctx context.Context, orderId int) (*shippingentity.Shipment, error) {
+	var t shippingentity.Shipment
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getShipmentByDynamic"]+" WHERE order_id = :order_id")
+
+	return &t, stmt.GetContext(ctx, &t, shippingentity.Shipment{OrderId: orderId})
+}
+
+// AssignInvoiceNumber will give a number to the paid order verified before the invoice
+// existed, counted in the month of the invoice date like the orders verified today.
+// The number of the order is returned when it has been assigned in the meantime
+func (r *RepoOrders) AssignInvoiceNumber(ctx context.Context, order *ordersentity.Order) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	invoiceNumber, err := r.assignInvoiceNumber(ctx, tx, order.Id, order.InvoiceDate())
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+
+		t, err := r.GetOrderById(ctx, order.Id)
+		if err != nil {
+			return "", err
+		}
+		return t.InvoiceNumber.String, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return invoiceNumber, tx.Commit()
+}
+
+// assignInvoiceNumber take the next number of the month and give it to the order, it
+// fails with sql.ErrNoRows when the order already has one. The row of the month stays
+// locked until the transaction is over, so it is only taken once the order is paid
+// rather than on every checkout
+func (r *RepoOrders) assignInvoiceNumber(ctx context.Context, tx *sqlx.Tx, orderId int, at time.Time) (string, error) {
+	period := ordersentity.InvoicePeriod(at)
+
+	var number int
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["nextInvoiceNumber"])
+	if err != nil {
+		return "", err
+	}
+	if err := stmt.QueryRowxContext(ctx, map[string]interface{}{"period": period}).Scan(&number); err != nil {
+		return "", err
+	}
+	invoiceNumber := ordersentity.InvoiceNumber(period, number)
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["assignInvoiceNumber"])
+	if err != nil {
+		return "", err
+	}
+	result, err := stmt.ExecContext(ctx, map[string]interface{}{"id": orderId, "invoice_number": invoiceNumber})
+	if err != nil {
+		return "", err
+	}
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return "", sql.ErrNoRows
+	}
+
+	return invoiceNumber, nil
+}
+
+func (r *RepoOrders) updateStatus(ctx context.Context, tx *sqlx.Tx, payload *ordersentity.Order,
+	history *ordersentity.OrderStatusHistory, restoreStock bool) error {
+
+	query := `UPDATE transaction.orders SET updated_at=CURRENT_TIMESTAMP, status=:status`
+	if len(payload.NoReceipt.String) > 0 {
+		query += `, no_receipt=:no_receipt`
+	}
+	if len(payload.CancelReason.String) > 0 {
+		query += `, cancel_reason=:cancel_reason`
+	}
+	if len(payload.ProofOfPayment) > 0 {
+		query += `, proof_of_payment=:proof_of_payment`
+	}
+	if payload.Status == ordersentity.StatusPaymentVerified {
+		query += `, verified_by=:verified_by, verified_at=CURRENT_TIMESTAMP`
+	}
+	if payload.Status == ordersentity.StatusSuccess {
+		query += `, auto_completed=:auto_completed`
+	}
+	query += ` WHERE id = :id AND status = :from_status`
+
+	stmt, err := tx.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, map[string]interface{}{
+		"id":               payload.Id,
+		"status":           payload.Status,
+		"no_receipt":       payload.NoReceipt,
+		"cancel_reason":    payload.CancelReason,
+		"proof_of_payment": payload.ProofOfPayment,
+		"verified_by":      payload.VerifiedBy,
+		"auto_completed":   payload.AutoCompleted,
+		"from_status":      history.FromStatus,
+	})
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return ordersentity.ErrStatusChanged
+	}
+
+	// the order is paid, it gets the invoice number of the verified month in the same transaction
+	if payload.Status == ordersentity.StatusPaymentVerified {
+		var t ordersentity.Order
+		stmt, err = tx.PrepareNamedContext(ctx, r.queries["getInvoiceNumber"])
+		if err != nil {
+			return err
+		}
+		if err := stmt.GetContext(ctx, &t, payload); err != nil {
+			return err
+		}
+		if !t.InvoiceNumber.Valid {
+			if _, err := r.assignInvoiceNumber(ctx, tx, payload.Id, t.InvoiceDate()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if restoreStock {
+		stmt, err = tx.PrepareNamedContext(ctx, r.execs["restoreStock"])
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, payload); err != nil {
+			return err
+		}
+
+		stmt, err = tx.PrepareNamedContext(ctx, r.execs["deleteVoucherUsage"])
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, payload); err != nil {
+			return err
+		}
+	}
+
+	history.OrderId = payload.Id
+	history.ToStatus = payload.Status
+
+	if err := r.insertStatusHistory(ctx, tx, history); err != nil {
+		return err
+	}
+
+	// the buyer is told about the change once the transaction is committed
+	return r.insertEmail(ctx, tx, &emailsentity.Email{
+		Event:       emailsentity.EventOrderStatusChanged,
+		OrderStatus: payload.Status,
+		OrderId:     payload.Id,
+	})
+}
+
+func (r *RepoOrders) insertStatusHistory(ctx context.Context, tx *sqlx.Tx, payload *ordersentity.OrderStatusHistory) error {
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertStatusHistory"])
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, payload)
+
+	return err
+}
+
+// insertEmail put the email into the outbox, the worker deliver it later
+func (r *RepoOrders) insertEmail(ctx context.Context, tx *sqlx.Tx, payload *emailsentity.Email) error {
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["insertEmail"])
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, payload)
+
+	return err
+}
+
+func (r *RepoOrders) GetOrderStatusHistories(ctx context.Context, orderId int) ([]ordersentity.OrderStatusHistory, error) {
+	var results []ordersentity.OrderStatusHistory
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getStatusHistoryByDynamic"]+" WHERE order_id = :order_id ORDER BY id ASC")
+	err := stmt.SelectContext(ctx, &results, ordersentity.OrderStatusHistory{OrderId: orderId})
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
 func (r *RepoOrders) UpdateOrder(ctx context.Context, payload *ordersentity.Order) error {
 	query := `UPDATE transaction.orders SET updated_at=CURRENT_TIMESTAMP`
 	if len(payload.Status) > 0 {
@@ -150,24 +655,7 @@ func (r *RepoOrders) GetAllOrderPaginate(ctx context.Context,
 func (r *RepoOrders) GetAllOrderItems(ctx context.Context, orderId int) ([]ordersentity.OrderItemProduct, error) {
 	var results []ordersentity.OrderItemProduct
 
-	query := `
-	SELECT
-	transaction.order_items.id as order_items_id,
-	transaction.order_items.notes as order_items_notes,
-	transaction.order_items.qty as order_items_qty,
-	transaction.order_items.price as order_items_price,
-	transaction.order_items.product_id as order_items_product_id,
-	transaction.order_items.created_at as order_items_created_at,
-	transaction.order_items.updated_at as order_items_updated_at,
-	product.products.name as product_name,
-	product.products.slug as product_slug,
-	product.products.image as product_image
-FROM transaction.order_items
-INNER JOIN product.products ON product.products.id = transaction.order_items.product_id
-WHERE transaction.order_items.order_id = :order_id ORDER BY transaction.order_items.id DESC
-	`
-
-	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getOrderItemProduct"]+` WHERE transaction.order_items.order_id = :order_id ORDER BY transaction.order_items.id DESC`)
 	err := stmt.SelectContext(ctx, &results, ordersentity.OrderItem{OrderId: orderId})
 	if err != nil {
 		return results, err
@@ -175,3 +663,31 @@ WHERE transaction.order_items.order_id = :order_id ORDER BY transaction.order_it
 
 	return results, nil
 }
+
+// GetAllOrderItemsByOrderIds will fetch the items of many orders in a single query
+// and group them by order id, so a page of orders doesn't need a query per order
+func (r *RepoOrders) GetAllOrderItemsByOrderIds(ctx context.Context, orderIds []int) (map[int][]ordersentity.OrderItemProduct, error) {
+	var items []ordersentity.OrderItemProduct
+	results := make(map[int][]ordersentity.OrderItemProduct)
+
+	if len(orderIds) < 1 {
+		return results, nil
+	}
+
+	var ids pq.Int64Array
+	for _, id := range orderIds {
+		ids = append(ids, int64(id))
+	}
+
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getOrderItemProduct"]+` WHERE transaction.order_items.order_id = ANY(:ids) ORDER BY transaction.order_items.id DESC`)
+	err := stmt.SelectContext(ctx, &items, map[string]interface{}{"ids": ids})
+	if err != nil {
+		return results, err
+	}
+
+	for _, item := range items {
+		results[item.OrderItemsOrderId] = append(results[item.OrderItemsOrderId], item)
+	}
+
+	return results, nil
+}
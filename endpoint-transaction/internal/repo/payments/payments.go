@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"context"
+
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"github.com/jmoiron/sqlx"
+)
+
+type RepoPayments struct {
+	db      *sqlx.DB
+	queries map[string]string
+	execs   map[string]string
+}
+
+var queries = map[string]string{
+	"getPaymentByDynamic": `SELECT id, provider, method, bank_code, external_id, amount, status, va_number, qr_string, expired_at, paid_at, order_id, created_at, updated_at FROM transaction.payments`,
+}
+var execs = map[string]string{
+	"requireRefund": `UPDATE transaction.payments SET updated_at=CURRENT_TIMESTAMP, status=:status, paid_at=:paid_at WHERE id = :id AND status IN ('pending', 'expired')`,
+}
+
+func New(db *sqlx.DB) (*RepoPayments, error) {
+	rp := &RepoPayments{
+		db:      db,
+		queries: queries,
+		execs:   execs,
+	}
+
+	err := rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Validate will validate sql query to db
+func (r *RepoPayments) Validate() error {
+	for _, q := range r.queries {
+		_, err := r.db.PrepareNamedContext(context.Background(), q)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.execs {
+		_, err := r.db.PrepareNamedContext(context.Background(), e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RepoPayments) GetPaymentByExternalId(ctx context.Context, externalId string) (*paymentsentity.Payment, error) {
+	var t paymentsentity.Payment
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getPaymentByDynamic"]+" WHERE external_id = :external_id")
+
+	return &t, stmt.GetContext(ctx, &t, paymentsentity.Payment{ExternalId: externalId})
+}
+
+func (r *RepoPayments) GetPaymentByOrderId(ctx context.Context, orderId int) (*paymentsentity.Payment, error) {
+	var t paymentsentity.Payment
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getPaymentByDynamic"]+" WHERE order_id = :order_id")
+
+	return &t, stmt.GetContext(ctx, &t, paymentsentity.Payment{OrderId: orderId})
+}
+
+// RequireRefund will save the refund required payment without touching the order,
+// it fails with ErrPaymentProcessed when the payment is no longer pending or expired
+func (r *RepoPayments) RequireRefund(ctx context.Context, payload *paymentsentity.Payment) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["requireRefund"])
+	result, err := stmt.ExecContext(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return paymentsentity.ErrPaymentProcessed
+	}
+	return nil
+}
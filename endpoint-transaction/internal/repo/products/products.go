@@ -15,10 +15,11 @@ type RepoProducts struct {
 }
 
 var queries = map[string]string{
-	"getProductByDynamic": `SELECT id, name, slug, description, image, price, stock, category_id, created_at, updated_at FROM product.products`,
+	"getProductByDynamic": `SELECT id, name, slug, description, image, price, stock, weight, category_id, created_at, updated_at FROM product.products`,
 }
 var execs = map[string]string{
 	"insertProduct": `INSERT INTO product.products (name, slug, description, image, price, stock, category_id) VALUES (:name, :slug, :description, :image, :price, :stock, :category_id) RETURNING id`,
+	"updatePrice":   `UPDATE product.products SET price = :price, updated_at = CURRENT_TIMESTAMP WHERE id = :id`,
 	"deleteProduct": `DELETE FROM product.products WHERE id = :id`,
 }
 
@@ -78,6 +79,15 @@ func (r *RepoProducts) Insert(ctx context.Context, payload *productsentity.FormC
 	return id
 }
 
+func (r *RepoProducts) UpdatePrice(ctx context.Context, productId, price int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["updatePrice"])
+	_, err := stmt.ExecContext(ctx, productsentity.Product{Id: productId, Price: price})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r *RepoProducts) Delete(ctx context.Context, productId int) error {
 	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteProduct"])
 	_, err := stmt.ExecContext(ctx, productsentity.Product{Id: productId})
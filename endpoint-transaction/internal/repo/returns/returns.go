@@ -0,0 +1,266 @@
+package returns
+
+import (
+	"context"
+	"fmt"
+
+	returnsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/returns"
+	"github.com/creent-production/cdk-go/pagination"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+type RepoReturns struct {
+	db      *sqlx.DB
+	queries map[string]string
+	execs   map[string]string
+}
+
+var queries = map[string]string{
+	"getReturnByDynamic":     `SELECT id, reason, photo, status, reject_reason, order_id, user_id, processed_by, processed_at, created_at, updated_at FROM transaction.returns`,
+	"getReturnItemByDynamic": `SELECT id, qty, price, order_item_id, product_id, return_id, created_at FROM transaction.return_items`,
+	"getRefundByDynamic":     `SELECT id, amount, status, return_id, order_id, created_at, updated_at FROM transaction.refunds`,
+	"lockOrder":              `SELECT id FROM transaction.orders WHERE id = :order_id FOR UPDATE`,
+	"countReturnedQty":       `SELECT transaction.return_items.order_item_id, SUM(transaction.return_items.qty) AS qty FROM transaction.return_items INNER JOIN transaction.returns ON transaction.returns.id = transaction.return_items.return_id WHERE transaction.returns.order_id = :order_id AND transaction.returns.status IN ('pending', 'approved') GROUP BY transaction.return_items.order_item_id`,
+}
+var execs = map[string]string{
+	"insertReturn":     `INSERT INTO transaction.returns (reason, photo, order_id, user_id) VALUES (:reason, :photo, :order_id, :user_id) RETURNING id`,
+	"insertReturnItem": `INSERT INTO transaction.return_items (qty, price, order_item_id, product_id, return_id) VALUES (:qty, :price, :order_item_id, :product_id, :return_id)`,
+	"processReturn":    `UPDATE transaction.returns SET updated_at=CURRENT_TIMESTAMP, status=:status, reject_reason=:reject_reason, processed_by=:processed_by, processed_at=CURRENT_TIMESTAMP WHERE id = :id AND status = 'pending'`,
+	"restockReturn":    `UPDATE product.products SET stock = product.products.stock + items.qty FROM (SELECT product_id, SUM(qty) AS qty FROM transaction.return_items WHERE return_id = :id GROUP BY product_id) AS items WHERE product.products.id = items.product_id`,
+	"insertRefund":     `INSERT INTO transaction.refunds (amount, status, return_id, order_id) VALUES (:amount, :status, :return_id, :order_id) RETURNING id`,
+}
+
+func New(db *sqlx.DB) (*RepoReturns, error) {
+	rp := &RepoReturns{
+		db:      db,
+		queries: queries,
+		execs:   execs,
+	}
+
+	err := rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Validate will validate sql query to db
+func (r *RepoReturns) Validate() error {
+	for _, q := range r.queries {
+		_, err := r.db.PrepareNamedContext(context.Background(), q)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.execs {
+		_, err := r.db.PrepareNamedContext(context.Background(), e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create will save the return with the items, the qty is checked again while the
+// order is locked so concurrent returns cannot pass the qty of the order items
+func (r *RepoReturns) Create(ctx context.Context, payload *returnsentity.FormCreateSchema,
+	items []returnsentity.ReturnItem, orderedQty map[int]int) (int, error) {
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var orderId int
+	stmt, err := tx.PrepareNamedContext(ctx, r.queries["lockOrder"])
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.GetContext(ctx, &orderId, payload); err != nil {
+		return 0, err
+	}
+
+	var returned []struct {
+		OrderItemId int `db:"order_item_id"`
+		Qty         int `db:"qty"`
+	}
+	stmt, err = tx.PrepareNamedContext(ctx, r.queries["countReturnedQty"])
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.SelectContext(ctx, &returned, payload); err != nil {
+		return 0, err
+	}
+
+	returnedQty := make(map[int]int)
+	for _, item := range returned {
+		returnedQty[item.OrderItemId] = item.Qty
+	}
+	for _, item := range items {
+		if available := orderedQty[item.OrderItemId] - returnedQty[item.OrderItemId]; item.Qty > available {
+			return 0, &returnsentity.ExceedQtyError{OrderItemId: item.OrderItemId, Available: available}
+		}
+	}
+
+	// insert return and the items
+	var returnId int
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertReturn"])
+	if err != nil {
+		return 0, err
+	}
+	if err := stmt.QueryRowxContext(ctx, payload).Scan(&returnId); err != nil {
+		return 0, err
+	}
+
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertReturnItem"])
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		item.ReturnId = returnId
+		if _, err := stmt.ExecContext(ctx, item); err != nil {
+			return 0, err
+		}
+	}
+
+	return returnId, tx.Commit()
+}
+
+// Approve will approve the pending return, give back the stock of the items
+// and save the refund in the same transaction
+func (r *RepoReturns) Approve(ctx context.Context, returnId, processedBy int, refund *returnsentity.Refund) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.process(ctx, tx, returnId, processedBy, returnsentity.StatusApproved, null.String{}); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["restockReturn"])
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, returnsentity.Return{Id: returnId}); err != nil {
+		return err
+	}
+
+	refund.ReturnId = returnId
+	stmt, err = tx.PrepareNamedContext(ctx, r.execs["insertRefund"])
+	if err != nil {
+		return err
+	}
+	if err := stmt.QueryRowxContext(ctx, refund).Scan(&refund.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *RepoReturns) Reject(ctx context.Context, returnId, processedBy int, reason null.String) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.process(ctx, tx, returnId, processedBy, returnsentity.StatusRejected, reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// process only moves the return that is still pending,
+// ErrReturnProcessed returned when it has been processed in the meantime
+func (r *RepoReturns) process(ctx context.Context, tx *sqlx.Tx, returnId, processedBy int,
+	status string, rejectReason null.String) error {
+
+	stmt, err := tx.PrepareNamedContext(ctx, r.execs["processReturn"])
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, returnsentity.Return{
+		Id:           returnId,
+		Status:       status,
+		RejectReason: rejectReason,
+		ProcessedBy:  null.IntFrom(int64(processedBy)),
+	})
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return returnsentity.ErrReturnProcessed
+	}
+
+	return nil
+}
+
+func (r *RepoReturns) GetReturnById(ctx context.Context, returnId int) (*returnsentity.Return, error) {
+	var t returnsentity.Return
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getReturnByDynamic"]+" WHERE id = :id")
+
+	return &t, stmt.GetContext(ctx, &t, returnsentity.Return{Id: returnId})
+}
+
+func (r *RepoReturns) GetReturnItems(ctx context.Context, returnId int) ([]returnsentity.ReturnItem, error) {
+	var results []returnsentity.ReturnItem
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getReturnItemByDynamic"]+" WHERE return_id = :return_id ORDER BY id ASC")
+
+	return results, stmt.SelectContext(ctx, &results, returnsentity.ReturnItem{ReturnId: returnId})
+}
+
+func (r *RepoReturns) GetRefundByReturnId(ctx context.Context, returnId int) (*returnsentity.Refund, error) {
+	var t returnsentity.Refund
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getRefundByDynamic"]+" WHERE return_id = :return_id")
+
+	return &t, stmt.GetContext(ctx, &t, returnsentity.Refund{ReturnId: returnId})
+}
+
+func (r *RepoReturns) GetAllReturnPaginate(ctx context.Context,
+	payload *returnsentity.QueryParamAllReturnSchema, isAdmin bool) (*returnsentity.ReturnPaginate, error) {
+
+	var results returnsentity.ReturnPaginate
+
+	query := r.queries["getReturnByDynamic"] + " WHERE 1=1"
+	if !isAdmin {
+		query += ` AND user_id = :user_id`
+	}
+	if len(payload.Status) > 0 {
+		query += ` AND status = :status`
+	}
+	query += ` ORDER BY id DESC`
+
+	// pagination
+	var count struct{ Total int }
+	stmt_count, _ := r.db.PrepareNamedContext(ctx, fmt.Sprintf("SELECT count(*) AS total FROM (%s) AS anon_1", query))
+	err := stmt_count.GetContext(ctx, &count, payload)
+	if err != nil {
+		return &results, err
+	}
+	payload.Offset = (payload.Page - 1) * payload.PerPage
+
+	// results
+	query += ` LIMIT :per_page OFFSET :offset`
+	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	err = stmt.SelectContext(ctx, &results.Data, payload)
+	if err != nil {
+		return &results, err
+	}
+
+	paginate := pagination.Paginate{Page: payload.Page, PerPage: payload.PerPage, Total: count.Total}
+	results.Total = paginate.Total
+	results.NextNum = paginate.NextNum()
+	results.PrevNum = paginate.PrevNum()
+	results.Page = paginate.Page
+	results.IterPages = paginate.IterPages()
+
+	return &results, nil
+}
@@ -0,0 +1,179 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+	"github.com/creent-production/cdk-go/pagination"
+	"github.com/jmoiron/sqlx"
+)
+
+// ProviderName of the built-in rate provider
+const ProviderName = "table"
+
+type RepoShipping struct {
+	db      *sqlx.DB
+	queries map[string]string
+	execs   map[string]string
+}
+
+var queries = map[string]string{
+	"getRateByDynamic": `SELECT id, province, city, max_weight, cost, created_at, updated_at FROM transaction.shipping_rates`,
+}
+var execs = map[string]string{
+	"insertRate": `INSERT INTO transaction.shipping_rates (province, city, max_weight, cost) VALUES (:province, NULLIF(:city, ''), :max_weight, :cost) RETURNING id`,
+	"updateRate": `UPDATE transaction.shipping_rates SET province=:province, city=NULLIF(:city, ''), max_weight=:max_weight, cost=:cost, updated_at=CURRENT_TIMESTAMP WHERE id = :id`,
+	"deleteRate": `DELETE FROM transaction.shipping_rates WHERE id = :id`,
+}
+
+func New(db *sqlx.DB) (*RepoShipping, error) {
+	rp := &RepoShipping{
+		db:      db,
+		queries: queries,
+		execs:   execs,
+	}
+
+	err := rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Validate will validate sql query to db
+func (r *RepoShipping) Validate() error {
+	for _, q := range r.queries {
+		_, err := r.db.PrepareNamedContext(context.Background(), q)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.execs {
+		_, err := r.db.PrepareNamedContext(context.Background(), e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Quote is the table provider, it takes the lightest tier that can carry the weight
+// and the tier of the city wins over the one of the whole province. Without a province
+// it takes the most expensive tier that can carry the weight in any zone, so a client
+// that doesn't send the destination is never undercharged
+func (r *RepoShipping) Quote(ctx context.Context, province, city string, weight int) (*shippingentity.Quote, error) {
+	var rate shippingentity.Rate
+
+	query := r.queries["getRateByDynamic"] + ` WHERE lower(province) = lower(:province)
+AND (city IS NULL OR lower(city) = lower(:city)) AND max_weight >= :max_weight
+ORDER BY city IS NULL, max_weight LIMIT 1`
+	if len(province) < 1 {
+		query = r.queries["getRateByDynamic"] + ` WHERE max_weight >= :max_weight ORDER BY cost DESC LIMIT 1`
+	}
+
+	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	err := stmt.GetContext(ctx, &rate, map[string]interface{}{"province": province, "city": city, "max_weight": weight})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, shippingentity.ErrNotCovered
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &shippingentity.Quote{
+		Provider: ProviderName,
+		Province: province,
+		City:     city,
+		Weight:   weight,
+		Cost:     rate.Cost,
+	}, nil
+}
+
+func (r *RepoShipping) GetRateById(ctx context.Context, id int) (*shippingentity.Rate, error) {
+	var t shippingentity.Rate
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getRateByDynamic"]+" WHERE id = :id")
+
+	return &t, stmt.GetContext(ctx, &t, shippingentity.Rate{Id: id})
+}
+
+// GetRateByTier finds the tier with the same destination and weight,
+// a tier without city only matches another tier without city
+func (r *RepoShipping) GetRateByTier(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) (*shippingentity.Rate, error) {
+	var t shippingentity.Rate
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getRateByDynamic"]+` WHERE lower(province) = lower(:province)
+AND lower(COALESCE(city, '')) = lower(:city) AND max_weight = :max_weight`)
+
+	return &t, stmt.GetContext(ctx, &t, payload)
+}
+
+func (r *RepoShipping) GetAllRatePaginate(ctx context.Context,
+	payload *shippingentity.QueryParamAllRateSchema) (*shippingentity.RatePaginate, error) {
+
+	var results shippingentity.RatePaginate
+
+	query := r.queries["getRateByDynamic"]
+	if len(payload.Q) > 0 {
+		query += ` WHERE lower(province) LIKE '%'|| lower(:q) ||'%' OR lower(city) LIKE '%'|| lower(:q) ||'%'`
+	}
+	query += ` ORDER BY lower(province), city NULLS FIRST, max_weight`
+
+	// pagination
+	var count struct{ Total int }
+	stmt_count, _ := r.db.PrepareNamedContext(ctx, fmt.Sprintf("SELECT count(*) AS total FROM (%s) AS anon_1", query))
+	err := stmt_count.GetContext(ctx, &count, payload)
+	if err != nil {
+		return &results, err
+	}
+	payload.Offset = (payload.Page - 1) * payload.PerPage
+
+	// results
+	query += ` LIMIT :per_page OFFSET :offset`
+	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	err = stmt.SelectContext(ctx, &results.Data, payload)
+	if err != nil {
+		return &results, err
+	}
+
+	paginate := pagination.Paginate{Page: payload.Page, PerPage: payload.PerPage, Total: count.Total}
+	results.Total = paginate.Total
+	results.NextNum = paginate.NextNum()
+	results.PrevNum = paginate.PrevNum()
+	results.Page = paginate.Page
+	results.IterPages = paginate.IterPages()
+
+	return &results, nil
+}
+
+func (r *RepoShipping) Insert(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) (int, error) {
+	var id int
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertRate"])
+	if err := stmt.QueryRowxContext(ctx, payload).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *RepoShipping) Update(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["updateRate"])
+	_, err := stmt.ExecContext(ctx, payload)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *RepoShipping) Delete(ctx context.Context, rateId int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteRate"])
+	_, err := stmt.ExecContext(ctx, shippingentity.Rate{Id: rateId})
+	if err != nil {
+		return err
+	}
+	return nil
+}
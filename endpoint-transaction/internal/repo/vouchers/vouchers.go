@@ -0,0 +1,146 @@
+package vouchers
+
+import (
+	"context"
+	"fmt"
+
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
+	"github.com/creent-production/cdk-go/pagination"
+	"github.com/jmoiron/sqlx"
+)
+
+type RepoVouchers struct {
+	db      *sqlx.DB
+	queries map[string]string
+	execs   map[string]string
+}
+
+var queries = map[string]string{
+	"getVoucherByDynamic": `SELECT id, code, kind, amount, max_discount, min_spend, usage_limit, usage_limit_per_user, category_ids, product_ids, started_at, expired_at, created_at, updated_at FROM transaction.vouchers`,
+	"countVoucherUsage":   `SELECT count(*) AS total, count(*) FILTER (WHERE user_id = :user_id) AS user_total FROM transaction.voucher_usages WHERE voucher_id = :id`,
+}
+var execs = map[string]string{
+	"insertVoucher": `INSERT INTO transaction.vouchers (code, kind, amount, max_discount, min_spend, usage_limit, usage_limit_per_user, category_ids, product_ids, started_at, expired_at) VALUES (:code, :kind, :amount, :max_discount, :min_spend, :usage_limit, :usage_limit_per_user, :category_ids, :product_ids, :started_at, :expired_at) RETURNING id`,
+	"updateVoucher": `UPDATE transaction.vouchers SET code=:code, kind=:kind, amount=:amount, max_discount=:max_discount, min_spend=:min_spend, usage_limit=:usage_limit, usage_limit_per_user=:usage_limit_per_user, category_ids=:category_ids, product_ids=:product_ids, started_at=:started_at, expired_at=:expired_at, updated_at=CURRENT_TIMESTAMP WHERE id = :id`,
+	"deleteVoucher": `DELETE FROM transaction.vouchers WHERE id = :id`,
+}
+
+func New(db *sqlx.DB) (*RepoVouchers, error) {
+	rp := &RepoVouchers{
+		db:      db,
+		queries: queries,
+		execs:   execs,
+	}
+
+	err := rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// Validate will validate sql query to db
+func (r *RepoVouchers) Validate() error {
+	for _, q := range r.queries {
+		_, err := r.db.PrepareNamedContext(context.Background(), q)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.execs {
+		_, err := r.db.PrepareNamedContext(context.Background(), e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RepoVouchers) GetVoucherByCode(ctx context.Context, code string) (*vouchersentity.Voucher, error) {
+	var t vouchersentity.Voucher
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getVoucherByDynamic"]+" WHERE code = :code")
+
+	return &t, stmt.GetContext(ctx, &t, vouchersentity.Voucher{Code: code})
+}
+
+func (r *RepoVouchers) GetVoucherById(ctx context.Context, id int) (*vouchersentity.Voucher, error) {
+	var t vouchersentity.Voucher
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["getVoucherByDynamic"]+" WHERE id = :id")
+
+	return &t, stmt.GetContext(ctx, &t, vouchersentity.Voucher{Id: id})
+}
+
+func (r *RepoVouchers) CountVoucherUsage(ctx context.Context, voucherId, userId int) (*vouchersentity.VoucherUsageCount, error) {
+	var t vouchersentity.VoucherUsageCount
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.queries["countVoucherUsage"])
+
+	return &t, stmt.GetContext(ctx, &t, map[string]interface{}{"id": voucherId, "user_id": userId})
+}
+
+func (r *RepoVouchers) Insert(ctx context.Context, payload *vouchersentity.JsonCreateUpdateSchema) int {
+	var id int
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["insertVoucher"])
+	stmt.QueryRowxContext(ctx, payload).Scan(&id)
+
+	return id
+}
+
+func (r *RepoVouchers) GetAllVoucherPaginate(ctx context.Context,
+	payload *vouchersentity.QueryParamAllVoucherSchema) (*vouchersentity.VoucherPaginate, error) {
+
+	var results vouchersentity.VoucherPaginate
+
+	query := r.queries["getVoucherByDynamic"]
+	if len(payload.Q) > 0 {
+		query += ` WHERE lower(code) LIKE '%'|| lower(:q) ||'%'`
+	}
+	query += ` ORDER BY id DESC`
+
+	// pagination
+	var count struct{ Total int }
+	stmt_count, _ := r.db.PrepareNamedContext(ctx, fmt.Sprintf("SELECT count(*) AS total FROM (%s) AS anon_1", query))
+	err := stmt_count.GetContext(ctx, &count, payload)
+	if err != nil {
+		return &results, err
+	}
+	payload.Offset = (payload.Page - 1) * payload.PerPage
+
+	// results
+	query += ` LIMIT :per_page OFFSET :offset`
+	stmt, _ := r.db.PrepareNamedContext(ctx, query)
+	err = stmt.SelectContext(ctx, &results.Data, payload)
+	if err != nil {
+		return &results, err
+	}
+
+	paginate := pagination.Paginate{Page: payload.Page, PerPage: payload.PerPage, Total: count.Total}
+	results.Total = paginate.Total
+	results.NextNum = paginate.NextNum()
+	results.PrevNum = paginate.PrevNum()
+	results.Page = paginate.Page
+	results.IterPages = paginate.IterPages()
+
+	return &results, nil
+
+}
+
+func (r *RepoVouchers) Update(ctx context.Context, payload *vouchersentity.JsonCreateUpdateSchema) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["updateVoucher"])
+	_, err := stmt.ExecContext(ctx, payload)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *RepoVouchers) Delete(ctx context.Context, voucherId int) error {
+	stmt, _ := r.db.PrepareNamedContext(ctx, r.execs["deleteVoucher"])
+	_, err := stmt.ExecContext(ctx, vouchersentity.Voucher{Id: voucherId})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
 	"github.com/go-chi/jwtauth"
-	"github.com/gomodule/redigo/redis"
 	"gopkg.in/guregu/null.v4"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
@@ -20,13 +20,17 @@ type CartsUsecase struct {
 	cartsRepo    cartsRepo
 	authRepo     authRepo
 	productsRepo productsRepo
+	// checkoutExpires is how long the items moved to the payment wait for the order
+	checkoutExpires time.Duration
 }
 
-func NewCartsUsecase(cartRepo cartsRepo, authRepo authRepo, productRepo productsRepo) *CartsUsecase {
+func NewCartsUsecase(cartRepo cartsRepo, authRepo authRepo, productRepo productsRepo,
+	checkoutExpires time.Duration) *CartsUsecase {
 	return &CartsUsecase{
-		cartsRepo:    cartRepo,
-		authRepo:     authRepo,
-		productsRepo: productRepo,
+		cartsRepo:       cartRepo,
+		authRepo:        authRepo,
+		productsRepo:    productRepo,
+		checkoutExpires: checkoutExpires,
 	}
 }
 
@@ -154,7 +158,7 @@ func (uc *CartsUsecase) Delete(ctx context.Context, rw http.ResponseWriter, payl
 }
 
 func (uc *CartsUsecase) MoveToPayment(ctx context.Context, rw http.ResponseWriter,
-	redisCli *redis.Pool, payload *cartsentity.JsonMultipleSchema) {
+	payload *cartsentity.JsonMultipleSchema) {
 
 	if err := validation.StructValidate(payload); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, err)
@@ -174,14 +178,19 @@ func (uc *CartsUsecase) MoveToPayment(ctx context.Context, rw http.ResponseWrite
 
 	// move to payment
 	payload.UserId = user.Id
-	uc.cartsRepo.MoveItemToPayment(ctx, redisCli, payload)
+	if err := uc.cartsRepo.MoveItemToPayment(ctx, payload, uc.checkoutExpires); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
 		constant.App: fmt.Sprintf("%d items successfully moved to the payment.", len(payload.ListId)),
 	})
 }
 
-func (uc *CartsUsecase) ItemInPayment(ctx context.Context, rw http.ResponseWriter, redisCli *redis.Pool) {
+func (uc *CartsUsecase) ItemInPayment(ctx context.Context, rw http.ResponseWriter) {
 	_, claims, _ := jwtauth.FromContext(ctx)
 	sub, _ := strconv.Atoi(claims["sub"].(string))
 
@@ -193,7 +202,39 @@ func (uc *CartsUsecase) ItemInPayment(ctx context.Context, rw http.ResponseWrite
 		return
 	}
 
-	results, _ := uc.cartsRepo.ItemInPayment(ctx, redisCli, user.Id)
+	results, _ := uc.cartsRepo.ItemInPayment(ctx, user.Id)
 
 	response.WriteJSONResponse(rw, 200, results, nil)
 }
+
+func (uc *CartsUsecase) Checkout(ctx context.Context, rw http.ResponseWriter) {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+
+	checkout, err := uc.cartsRepo.GetCheckoutByUserId(ctx, user.Id)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Checkout not found or already expired.",
+		})
+		return
+	}
+
+	checkout.Items, _ = uc.cartsRepo.ItemInPayment(ctx, user.Id)
+	if checkout.Items == nil {
+		checkout.Items = []cartsentity.CartProduct{}
+	}
+	for _, item := range checkout.Items {
+		checkout.TotalQty += item.CartQty
+		checkout.TotalAmount += item.CartQty * item.ProductPrice
+	}
+
+	response.WriteJSONResponse(rw, 200, checkout, nil)
+}
@@ -2,11 +2,11 @@ package carts
 
 import (
 	"context"
+	"time"
 
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
 	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
 	productsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/products"
-	"github.com/gomodule/redigo/redis"
 )
 
 type cartsRepo interface {
@@ -16,8 +16,9 @@ type cartsRepo interface {
 	Insert(ctx context.Context, payload *cartsentity.Cart) int
 	Update(ctx context.Context, payload *cartsentity.Cart) error
 	Delete(ctx context.Context, payload *cartsentity.JsonMultipleSchema) error
-	MoveItemToPayment(ctx context.Context, redisCli *redis.Pool, payload *cartsentity.JsonMultipleSchema)
-	ItemInPayment(ctx context.Context, redisCli *redis.Pool, userId int) ([]cartsentity.CartProduct, error)
+	MoveItemToPayment(ctx context.Context, payload *cartsentity.JsonMultipleSchema, expires time.Duration) error
+	ItemInPayment(ctx context.Context, userId int) ([]cartsentity.CartProduct, error)
+	GetCheckoutByUserId(ctx context.Context, userId int) (*cartsentity.Checkout, error)
 }
 
 type authRepo interface {
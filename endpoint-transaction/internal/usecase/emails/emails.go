@@ -0,0 +1,180 @@
+package emails
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/emails"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/invoice"
+	mailerpkg "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/mailer"
+)
+
+const templateDir = "/app/templates/email"
+
+type orderEmail struct {
+	subject  string
+	template string
+	// message is the sentence of the generic template
+	message string
+}
+
+// orderStatusEmails declares the email sent when the order moves into a status,
+// every status in the state machine of the orders must be listed here
+var (
+	orderCreatedEmail = orderEmail{subject: "Order #%d Received", template: "OrderCreated.html"}
+	orderStatusEmails = map[string]orderEmail{
+		ordersentity.StatusPaymentVerified: {
+			subject:  "Payment of Order #%d Verified",
+			template: "OrderStatusChanged.html",
+			message:  "Your payment has been verified, we are preparing the order.",
+		},
+		ordersentity.StatusReuploadRequested: {
+			subject:  "New Proof of Payment Needed for Order #%d",
+			template: "OrderStatusChanged.html",
+			message:  "We couldn't verify your proof of payment, please upload a new one.",
+		},
+		ordersentity.StatusOngoing: {
+			subject:  "Proof of Payment of Order #%d Received",
+			template: "OrderStatusChanged.html",
+			message:  "We have received your new proof of payment and will verify it soon.",
+		},
+		ordersentity.StatusReject:   {subject: "Order #%d Rejected", template: "OrderRejected.html"},
+		ordersentity.StatusOnTheWay: {subject: "Order #%d Shipped", template: "OrderShipped.html"},
+		ordersentity.StatusSuccess:  {subject: "Order #%d Completed", template: "OrderCompleted.html"},
+		ordersentity.StatusCancelled: {
+			subject:  "Order #%d Cancelled",
+			template: "OrderStatusChanged.html",
+			message:  "Your order has been cancelled.",
+		},
+		ordersentity.StatusExpired: {
+			subject:  "Order #%d Expired",
+			template: "OrderStatusChanged.html",
+			message:  "Your order has expired because there was no progress for too long.",
+		},
+	}
+)
+
+type emailData struct {
+	Fullname       string
+	OrderId        int
+	InvoiceNumber  string
+	Status         string
+	TotalAmount    string
+	Message        string
+	Note           string
+	CourierCode    string
+	TrackingNumber string
+}
+
+type EmailsUsecase struct {
+	emailsRepo  emailsRepo
+	ordersRepo  ordersRepo
+	authRepo    authRepo
+	mailer      mailer
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewEmailsUsecase(emailRepo emailsRepo, orderRepo ordersRepo, authRepo authRepo,
+	mailer mailer, maxAttempts int, backoff time.Duration) *EmailsUsecase {
+	return &EmailsUsecase{
+		emailsRepo:  emailRepo,
+		ordersRepo:  orderRepo,
+		authRepo:    authRepo,
+		mailer:      mailer,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// DeliverEmails will send at most limit emails waiting in the outbox, it returns the
+// number of sent emails. A failed email is tried again after the backoff, doubled on
+// every attempt, and given up after maxAttempts
+func (uc *EmailsUsecase) DeliverEmails(ctx context.Context, limit int) (int, error) {
+	emails, err := uc.emailsRepo.GetEmailDue(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, email := range emails {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		if err := uc.send(ctx, &email); err != nil {
+			if email.Attempts+1 >= uc.maxAttempts {
+				err = uc.emailsRepo.MarkFailed(ctx, email.Id, err.Error())
+			} else {
+				err = uc.emailsRepo.MarkRetry(ctx, email.Id, err.Error(), uc.backoff<<email.Attempts)
+			}
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err := uc.emailsRepo.MarkSent(ctx, email.Id); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// send render the email of the event with the current data of the order
+func (uc *EmailsUsecase) send(ctx context.Context, email *emailsentity.Email) error {
+	t, ok := orderStatusEmails[email.OrderStatus]
+	if email.Event == emailsentity.EventOrderCreated {
+		t, ok = orderCreatedEmail, true
+	}
+	if !ok {
+		return fmt.Errorf("no email for the event %s of status %s", email.Event, email.OrderStatus)
+	}
+
+	order, err := uc.ordersRepo.GetOrderById(ctx, email.OrderId)
+	if err != nil {
+		return err
+	}
+	user, err := uc.authRepo.GetUserById(ctx, order.UserId)
+	if err != nil {
+		return err
+	}
+
+	data := emailData{
+		Fullname:      order.Fullname,
+		OrderId:       order.Id,
+		InvoiceNumber: order.InvoiceNumber.String,
+		Status:        strings.ReplaceAll(email.OrderStatus, "_", " "),
+		TotalAmount:   invoice.Rupiah(order.TotalAmount),
+		Message:       t.message,
+	}
+
+	// the note of the change, e.g. the reason of the reupload
+	if email.Event == emailsentity.EventOrderStatusChanged {
+		histories, _ := uc.ordersRepo.GetOrderStatusHistories(ctx, order.Id)
+		for i := len(histories) - 1; i >= 0; i-- {
+			if histories[i].ToStatus == email.OrderStatus {
+				data.Note = histories[i].Note.String
+				break
+			}
+		}
+	}
+	if email.OrderStatus == ordersentity.StatusOnTheWay {
+		if shipment, err := uc.ordersRepo.GetShipmentByOrderId(ctx, order.Id); err == nil {
+			data.CourierCode = strings.ToUpper(shipment.CourierCode)
+			data.TrackingNumber = shipment.TrackingNumber
+		}
+	}
+
+	body, err := mailerpkg.Render(fmt.Sprintf("%s/%s", templateDir, t.template), data)
+	if err != nil {
+		return err
+	}
+
+	return uc.mailer.Send(user.Email, fmt.Sprintf(t.subject, order.Id), body)
+}
@@ -0,0 +1,33 @@
+package emails
+
+import (
+	"context"
+	"time"
+
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/emails"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+)
+
+type emailsRepo interface {
+	GetEmailDue(ctx context.Context, limit int) ([]emailsentity.Email, error)
+	MarkSent(ctx context.Context, emailId int) error
+	MarkRetry(ctx context.Context, emailId int, lastError string, delay time.Duration) error
+	MarkFailed(ctx context.Context, emailId int, lastError string) error
+}
+
+type ordersRepo interface {
+	GetOrderById(ctx context.Context, orderId int) (*ordersentity.Order, error)
+	GetOrderStatusHistories(ctx context.Context, orderId int) ([]ordersentity.OrderStatusHistory, error)
+	GetShipmentByOrderId(ctx context.Context, orderId int) (*shippingentity.Shipment, error)
+}
+
+type authRepo interface {
+	GetUserById(ctx context.Context, userId int) (*authentity.User, error)
+}
+
+// mailer send the rendered email to the smtp server
+type mailer interface {
+	Send(to, subject, body string) error
+}
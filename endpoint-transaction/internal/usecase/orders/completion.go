@@ -0,0 +1,43 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"gopkg.in/guregu/null.v4"
+)
+
+// CompleteOrders will move at most limit orders that stay on the way longer than the
+// deadline into success on behalf of the buyer, it returns the number of completed
+// orders. Orders moved by someone else in the meantime are skipped
+func (uc *OrdersUsecase) CompleteOrders(ctx context.Context, deadline time.Duration, limit int) (int, error) {
+	orders, err := uc.ordersRepo.GetOrderStaleByStatus(ctx, ordersentity.StatusOnTheWay, deadline, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	note := fmt.Sprintf("Completed automatically after %s on the way.", deadline)
+	completed := 0
+	for _, order := range orders {
+		err := uc.ordersRepo.UpdateStatus(ctx, &ordersentity.Order{
+			Id:            order.Id,
+			Status:        ordersentity.StatusSuccess,
+			AutoCompleted: true,
+		}, &ordersentity.OrderStatusHistory{
+			FromStatus: null.StringFrom(order.Status),
+			Note:       null.StringFrom(note),
+		}, orderTransitions[ordersentity.StatusSuccess].restoreStock)
+		if errors.Is(err, ordersentity.ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return completed, err
+		}
+		completed++
+	}
+
+	return completed, nil
+}
@@ -0,0 +1,67 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"gopkg.in/guregu/null.v4"
+)
+
+// ExpireOrders will move at most limit orders that stay in status longer than the
+// deadline into expired and give back the stock, it returns the number of expired
+// orders. Orders moved by someone else in the meantime are skipped
+func (uc *OrdersUsecase) ExpireOrders(ctx context.Context, status string, deadline time.Duration, limit int) (int, error) {
+	t := orderTransitions[ordersentity.StatusExpired]
+	if !t.allows(status, actorSystem) {
+		return 0, errors.New(t.invalidMessage)
+	}
+
+	orders, err := uc.ordersRepo.GetOrderStaleByStatus(ctx, status, deadline, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	note := fmt.Sprintf("No progress after %s.", deadline)
+	expired := 0
+	for _, order := range orders {
+		payload := &ordersentity.Order{Id: order.Id, Status: ordersentity.StatusExpired}
+		history := &ordersentity.OrderStatusHistory{
+			FromStatus: null.StringFrom(order.Status),
+			Note:       null.StringFrom(note),
+		}
+
+		// the payment must not be settled anymore once the order expired
+		payment, err := uc.paymentsRepo.GetPaymentByOrderId(ctx, order.Id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err = uc.ordersRepo.UpdateStatus(ctx, payload, history, t.restoreStock)
+		case err != nil:
+			// the payment is unknown, the order is tried again on the next run
+			continue
+		case payment.Status == paymentsentity.StatusPending:
+			// void the charge first so the buyer cannot pay it anymore, the order
+			// is tried again on the next run when the gateway doesn't take it
+			if err := uc.paymentProvider.Void(ctx, &paymentsentity.Charge{ExternalId: payment.ExternalId, Method: payment.Method}); err != nil {
+				continue
+			}
+			payment.Status = paymentsentity.StatusExpired
+			err = uc.ordersRepo.SettlePayment(ctx, payment, payload, history, t.restoreStock)
+		default:
+			err = uc.ordersRepo.UpdateStatus(ctx, payload, history, t.restoreStock)
+		}
+		if errors.Is(err, ordersentity.ErrStatusChanged) || errors.Is(err, paymentsentity.ErrPaymentProcessed) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,59 @@
+package orders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/invoice"
+	"github.com/creent-production/cdk-go/response"
+	"gopkg.in/guregu/null.v4"
+)
+
+func (uc *OrdersUsecase) GetInvoice(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	order, ok := uc.authorizeView(ctx, rw, orderId)
+	if !ok {
+		return
+	}
+
+	// the invoice is a proof of a sale, the order must be paid and not called off
+	switch order.Status {
+	case ordersentity.StatusPaymentVerified, ordersentity.StatusOnTheWay, ordersentity.StatusSuccess:
+	default:
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "The invoice is only available once the payment is verified.",
+		})
+		return
+	}
+
+	// orders paid before the invoice existed get the number on the first download
+	if !order.InvoiceNumber.Valid {
+		invoiceNumber, err := uc.ordersRepo.AssignInvoiceNumber(ctx, order)
+		if err != nil {
+			response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+				constant.App: "Failed to create the invoice, please try again.",
+			})
+			return
+		}
+		order.InvoiceNumber = null.StringFrom(invoiceNumber)
+	}
+
+	items, _ := uc.ordersRepo.GetAllOrderItems(ctx, order.Id)
+
+	// render first, so the error can still be answered with json
+	var b bytes.Buffer
+	if err := invoice.Render(&b, order, items); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to create the invoice, please try again.",
+		})
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/pdf")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", invoice.Filename(order.InvoiceNumber.String)))
+	rw.WriteHeader(200)
+	rw.Write(b.Bytes())
+}
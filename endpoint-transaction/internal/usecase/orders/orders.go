@@ -2,47 +2,66 @@ package orders
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
-	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
 	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
 	"github.com/creent-production/cdk-go/magicimage"
-	"github.com/creent-production/cdk-go/parser"
 	"github.com/creent-production/cdk-go/response"
 	"github.com/creent-production/cdk-go/validation"
 	"github.com/go-chi/jwtauth"
-	"github.com/gomodule/redigo/redis"
 	"gopkg.in/guregu/null.v4"
 )
 
 type OrdersUsecase struct {
-	ordersRepo ordersRepo
-	authRepo   authRepo
-	cartsRepo  cartsRepo
+	ordersRepo       ordersRepo
+	authRepo         authRepo
+	cartsRepo        cartsRepo
+	vouchersRepo     vouchersRepo
+	shippingProvider shippingProvider
+	paymentsRepo     paymentsRepo
+	paymentProvider  paymentProvider
+	courierTracker   courierTracker
 }
 
-func NewOrdersUsecase(orderRepo ordersRepo, authRepo authRepo, cartRepo cartsRepo) *OrdersUsecase {
+func NewOrdersUsecase(orderRepo ordersRepo, authRepo authRepo, cartRepo cartsRepo,
+	voucherRepo vouchersRepo, shippingProvider shippingProvider,
+	paymentRepo paymentsRepo, paymentProvider paymentProvider, courierTracker courierTracker) *OrdersUsecase {
 	return &OrdersUsecase{
-		ordersRepo: orderRepo,
-		authRepo:   authRepo,
-		cartsRepo:  cartRepo,
+		ordersRepo:       orderRepo,
+		authRepo:         authRepo,
+		cartsRepo:        cartRepo,
+		vouchersRepo:     voucherRepo,
+		shippingProvider: shippingProvider,
+		paymentsRepo:     paymentRepo,
+		paymentProvider:  paymentProvider,
+		courierTracker:   courierTracker,
 	}
 }
 
 func (uc *OrdersUsecase) Create(ctx context.Context, rw http.ResponseWriter,
-	redisCli *redis.Pool, file *multipart.Form, payload *ordersentity.FormCreateSchema) {
+	file *multipart.Form, payload *ordersentity.FormCreateSchema) {
 
+	if len(payload.PaymentMethod) < 1 {
+		payload.PaymentMethod = paymentsentity.MethodManual
+	}
+
+	// only manual transfer need the proof, the others are confirmed by the payment gateway
 	magicImage := magicimage.New(file)
-	if err := magicImage.ValidateSingleImage("proof_of_payment"); err != nil {
-		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
-			"proof_of_payment": err.Error(),
-		})
-		return
+	if payload.PaymentMethod == paymentsentity.MethodManual {
+		if err := magicImage.ValidateSingleImage("proof_of_payment"); err != nil {
+			response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+				"proof_of_payment": err.Error(),
+			})
+			return
+		}
 	}
 
 	if err := validation.StructValidate(payload); err != nil {
@@ -50,6 +69,13 @@ func (uc *OrdersUsecase) Create(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
+	if payload.PaymentMethod == paymentsentity.MethodVirtualAccount && len(payload.BankCode) < 1 {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			"bank_code": validation.Required,
+		})
+		return
+	}
+
 	_, claims, _ := jwtauth.FromContext(ctx)
 	sub, _ := strconv.Atoi(claims["sub"].(string))
 
@@ -61,7 +87,7 @@ func (uc *OrdersUsecase) Create(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
-	productData, _ := uc.cartsRepo.ItemInPayment(ctx, redisCli, user.Id)
+	productData, _ := uc.cartsRepo.ItemInPayment(ctx, user.Id)
 
 	if len(productData) < 1 {
 		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
@@ -70,6 +96,26 @@ func (uc *OrdersUsecase) Create(ctx context.Context, rw http.ResponseWriter,
 		return
 	}
 
+	// the buyer must reconfirm the checkout when the price has changed
+	var priceChanges []ordersentity.PriceChange
+	for _, product := range productData {
+		if product.ProductPrice != product.ProductCurrentPrice {
+			priceChanges = append(priceChanges, ordersentity.PriceChange{
+				ProductId:   product.CartProductId,
+				ProductName: product.ProductName,
+				OldPrice:    product.ProductPrice,
+				NewPrice:    product.ProductCurrentPrice,
+			})
+		}
+	}
+	if len(priceChanges) > 0 {
+		priceChanged := &ordersentity.PriceChangedError{Products: priceChanges}
+		response.WriteJSONResponse(rw, 409, priceChanged.Products, map[string]interface{}{
+			constant.App: priceChanged.Error(),
+		})
+		return
+	}
+
 	payload.TotalAmount = 0
 	payload.UserId = user.Id
 	for _, product := range productData {
@@ -83,42 +129,103 @@ func (uc *OrdersUsecase) Create(ctx context.Context, rw http.ResponseWriter,
 		payload.TotalAmount += product.CartQty * product.ProductPrice
 	}
 
-	magicImage.SaveImages(500, 500, "/app/static/proof_payments", false)
-	payload.ProofOfPayment = magicImage.FileNames[0]
+	if !uc.applyVoucher(ctx, rw, payload, productData) {
+		return
+	}
 
-	// insert into db
-	orderId := uc.ordersRepo.Insert(ctx, payload)
+	quote, ok := uc.quoteShipping(ctx, rw, payload.Province, payload.City, productData)
+	if !ok {
+		return
+	}
+	payload.ShippingCost = quote.Cost
+	payload.TotalAmount += payload.ShippingCost - payload.DiscountAmount
+
+	var payment *paymentsentity.Payment
+	if payload.PaymentMethod == paymentsentity.MethodManual {
+		payload.Status = ordersentity.StatusOngoing
+		magicImage.SaveImages(500, 500, "/app/static/proof_payments", false)
+		payload.ProofOfPayment = magicImage.FileNames[0]
+	} else {
+		payload.Status = ordersentity.StatusPendingPayment
+		if payment, ok = uc.charge(ctx, rw, payload); !ok {
+			return
+		}
+	}
 
+	var (
+		orderItems []ordersentity.OrderItem
+		cartIds    []int
+	)
 	for _, orderItem := range productData {
-		uc.ordersRepo.InsertItem(ctx, &ordersentity.OrderItem{
+		orderItems = append(orderItems, ordersentity.OrderItem{
 			Notes:     orderItem.CartNotes,
 			Qty:       orderItem.CartQty,
 			Price:     orderItem.ProductPrice,
 			ProductId: orderItem.CartProductId,
-			OrderId:   orderId,
 		})
-
+		cartIds = append(cartIds, orderItem.CartId)
 	}
 
-	// delete cart db and redis payment
-	conn := redisCli.Get()
-	defer conn.Close()
+	// insert into db, reserve the stock and delete the carts with the checkout session
+	if _, err := uc.ordersRepo.Create(ctx, payload, orderItems, cartIds, payment); err != nil {
+		if len(payload.ProofOfPayment) > 0 {
+			magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/proof_payments/%s", payload.ProofOfPayment))
+		}
+		// without the order nothing settles the charge, the buyer must not be able to pay it
+		if payment != nil {
+			uc.paymentProvider.Void(ctx, &paymentsentity.Charge{ExternalId: payment.ExternalId, Method: payment.Method})
+		}
 
-	item, _ := redis.String(conn.Do("GET", fmt.Sprintf("checkout:%d", user.Id)))
-	listid, _ := parser.ParseSliceStrToSliceInt(strings.Split(item, ","))
-	uc.cartsRepo.Delete(ctx, &carts.JsonMultipleSchema{
-		UserId: user.Id,
-		ListId: listid,
-	})
+		var priceChanged *ordersentity.PriceChangedError
+		if errors.As(err, &priceChanged) {
+			response.WriteJSONResponse(rw, 409, priceChanged.Products, map[string]interface{}{
+				constant.App: priceChanged.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, ordersentity.ErrVoucherUnavailable) {
+			response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+				constant.App: "Voucher is no longer available.",
+			})
+			return
+		}
+
+		var outOfStock *ordersentity.OutOfStockError
+		if errors.As(err, &outOfStock) {
+			response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+				constant.App: outOfStock.Error(),
+			})
+			return
+		}
 
-	conn.Do("DEL", fmt.Sprintf("checkout:%d", payload.UserId))
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	// the buyer needs the payment instruction to pay the order
+	if payment != nil {
+		response.WriteJSONResponse(rw, 201, payment, map[string]interface{}{
+			constant.App: "Successfully save the order, please complete the payment.",
+		})
+		return
+	}
 
 	response.WriteJSONResponse(rw, 201, nil, map[string]interface{}{
 		constant.App: "Successfully save the order.",
 	})
 }
 
-func (uc *OrdersUsecase) SetReject(ctx context.Context, rw http.ResponseWriter, orderId int) {
+// authorizeStatus will check the user and the order against the state machine
+// before the order moves into status, the error response is already written
+// when it returns false
+func (uc *OrdersUsecase) authorizeStatus(ctx context.Context, rw http.ResponseWriter,
+	orderId int, status string) (*authentity.User, *ordersentity.Order, bool) {
+
+	t := orderTransitions[status]
+
 	_, claims, _ := jwtauth.FromContext(ctx)
 	sub, _ := strconv.Atoi(claims["sub"].(string))
 
@@ -127,14 +234,14 @@ func (uc *OrdersUsecase) SetReject(ctx context.Context, rw http.ResponseWriter,
 		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 			constant.Header: constant.UserNotFound,
 		})
-		return
+		return nil, nil, false
 	}
 
-	if user.Role != "admin" {
+	if t.adminOnly() && user.Role != "admin" {
 		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
 		})
-		return
+		return nil, nil, false
 	}
 
 	order, err := uc.ordersRepo.GetOrderById(ctx, orderId)
@@ -142,82 +249,259 @@ func (uc *OrdersUsecase) SetReject(ctx context.Context, rw http.ResponseWriter,
 		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
 			constant.App: "Order not found.",
 		})
-		return
+		return nil, nil, false
+	}
+
+	// the system transitions are not available from the api
+	actors := t.from[order.Status]
+	if !actors.has(actorAdmin | actorBuyer) {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: t.invalidMessage,
+		})
+		return nil, nil, false
+	}
+
+	if !actors.has(actorBuyer) && user.Role != "admin" {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
+		})
+		return nil, nil, false
+	}
+
+	if !actors.has(actorAdmin) && user.Id != order.UserId {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "User doesn't have this order.",
+		})
+		return nil, nil, false
 	}
 
-	if order.Status != "ongoing" {
+	return user, order, true
+}
+
+// updateStatus will save the status change together with the history,
+// the error response is already written when it returns false
+func (uc *OrdersUsecase) updateStatus(ctx context.Context, rw http.ResponseWriter,
+	user *authentity.User, order *ordersentity.Order, payload *ordersentity.Order, note string) bool {
+
+	t := orderTransitions[payload.Status]
+
+	payload.Id = order.Id
+	err := uc.ordersRepo.UpdateStatus(ctx, payload, &ordersentity.OrderStatusHistory{
+		FromStatus: null.StringFrom(order.Status),
+		ActorId:    null.IntFrom(int64(user.Id)),
+		Note:       null.NewString(note, len(note) > 0),
+	}, t.restoreStock)
+	if err != nil {
+		writeStatusError(rw, t, err)
+		return false
+	}
+
+	return true
+}
+
+// writeStatusError respond the failure of saving the status change,
+// the order that moved in the meantime is refused with the state machine message
+func writeStatusError(rw http.ResponseWriter, t transition, err error) {
+	if errors.Is(err, ordersentity.ErrStatusChanged) {
 		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
-			constant.App: "Cannot change status rejected if status other than ongoing.",
+			constant.App: t.invalidMessage,
 		})
 		return
 	}
 
-	// update status
-	uc.ordersRepo.UpdateOrder(ctx, &ordersentity.Order{
-		Id:     order.Id,
-		Status: "reject",
+	response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+		constant.App: constant.FailedSaveData,
 	})
+}
+
+func (uc *OrdersUsecase) SetReject(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusReject)
+	if !ok {
+		return
+	}
+
+	// update status and give back the stock
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{Status: ordersentity.StatusReject}, "") {
+		return
+	}
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
 		constant.App: "Successfully set the order to reject.",
 	})
 }
 
-func (uc *OrdersUsecase) SetOnTheWay(ctx context.Context, rw http.ResponseWriter, orderId int, file *multipart.Form) {
+func (uc *OrdersUsecase) VerifyPayment(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusPaymentVerified)
+	if !ok {
+		return
+	}
+
+	// update status with the verifier
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{
+		Status:     ordersentity.StatusPaymentVerified,
+		VerifiedBy: null.IntFrom(int64(user.Id)),
+	}, "") {
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully verify the payment.",
+	})
+}
+
+func (uc *OrdersUsecase) RequestReupload(ctx context.Context, rw http.ResponseWriter, orderId int,
+	payload *ordersentity.JsonRequestReuploadSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusReuploadRequested)
+	if !ok {
+		return
+	}
+
+	// the reason is kept in the history so the buyer knows what to fix
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{Status: ordersentity.StatusReuploadRequested}, payload.Reason) {
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully request a new proof of payment.",
+	})
+}
+
+func (uc *OrdersUsecase) ReuploadProof(ctx context.Context, rw http.ResponseWriter, orderId int, file *multipart.Form) {
 	magicImage := magicimage.New(file)
+	if err := magicImage.ValidateSingleImage("proof_of_payment"); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			"proof_of_payment": err.Error(),
+		})
+		return
+	}
+
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusOngoing)
+	if !ok {
+		return
+	}
+
+	// replace the proof and wait for the admin to check it again
+	magicImage.SaveImages(500, 500, "/app/static/proof_payments", false)
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{
+		Status:         ordersentity.StatusOngoing,
+		ProofOfPayment: magicImage.FileNames[0],
+	}, "") {
+		magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/proof_payments/%s", magicImage.FileNames[0]))
+		return
+	}
+	if len(order.ProofOfPayment) > 0 {
+		magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/proof_payments/%s", order.ProofOfPayment))
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully reupload the proof of payment.",
+	})
+}
+
+func (uc *OrdersUsecase) SetOnTheWay(ctx context.Context, rw http.ResponseWriter, orderId int,
+	file *multipart.Form, payload *ordersentity.FormOnTheWaySchema) {
+
+	// the photo of the receipt is optional, the tracking number is enough to follow the package
+	magicImage := magicimage.New(file)
+	magicImage.Required = false
 	if err := magicImage.ValidateSingleImage("no_receipt"); err != nil {
 		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
 			"no_receipt": err.Error(),
 		})
 		return
 	}
-	_, claims, _ := jwtauth.FromContext(ctx)
-	sub, _ := strconv.Atoi(claims["sub"].(string))
 
-	user, err := uc.authRepo.GetUserById(ctx, sub)
-	if err != nil {
-		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
-			constant.Header: constant.UserNotFound,
-		})
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
 		return
 	}
 
-	if user.Role != "admin" {
-		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
-			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
-		})
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusOnTheWay)
+	if !ok {
 		return
 	}
 
-	order, err := uc.ordersRepo.GetOrderById(ctx, orderId)
+	var receiptPhoto string
+	if len(magicImage.Files) > 0 {
+		magicImage.SaveImages(500, 500, "/app/static/no_receipts", false)
+		receiptPhoto = magicImage.FileNames[0]
+	}
+
+	// update status and save the shipment
+	err := uc.ordersRepo.Ship(ctx, &ordersentity.Order{
+		Id:     order.Id,
+		Status: ordersentity.StatusOnTheWay,
+	}, &ordersentity.OrderStatusHistory{
+		FromStatus: null.StringFrom(order.Status),
+		ActorId:    null.IntFrom(int64(user.Id)),
+	}, &shippingentity.Shipment{
+		CourierCode:    payload.CourierCode,
+		TrackingNumber: payload.TrackingNumber,
+		ReceiptPhoto:   null.NewString(receiptPhoto, len(receiptPhoto) > 0),
+	})
 	if err != nil {
-		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
-			constant.App: "Order not found.",
-		})
+		if len(receiptPhoto) > 0 {
+			magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/no_receipts/%s", receiptPhoto))
+		}
+		writeStatusError(rw, orderTransitions[ordersentity.StatusOnTheWay], err)
 		return
 	}
 
-	if order.Status != "ongoing" {
-		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
-			constant.App: "Cannot change status on the way if status other than ongoing.",
-		})
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully set the order to on the way.",
+	})
+}
+
+func (uc *OrdersUsecase) SetSuccess(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusSuccess)
+	if !ok {
 		return
 	}
 
 	// update status
-	magicImage.SaveImages(500, 500, "/app/static/no_receipts", false)
-	uc.ordersRepo.UpdateOrder(ctx, &ordersentity.Order{
-		Id:        order.Id,
-		Status:    "on the way",
-		NoReceipt: null.StringFrom(magicImage.FileNames[0]),
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{Status: ordersentity.StatusSuccess}, "") {
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully set the order to success.",
 	})
+}
+
+func (uc *OrdersUsecase) Cancel(ctx context.Context, rw http.ResponseWriter, orderId int, payload *ordersentity.JsonCancelSchema) {
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	user, order, ok := uc.authorizeStatus(ctx, rw, orderId, ordersentity.StatusCancelled)
+	if !ok {
+		return
+	}
+
+	// update status and give back the stock
+	if !uc.updateStatus(ctx, rw, user, order, &ordersentity.Order{
+		Status:       ordersentity.StatusCancelled,
+		CancelReason: null.NewString(payload.Reason, len(payload.Reason) > 0),
+	}, payload.Reason) {
+		return
+	}
 
 	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
-		constant.App: "Successfully set the order to on the way.",
+		constant.App: "Successfully cancelled the order.",
 	})
 }
 
-func (uc *OrdersUsecase) SetSuccess(ctx context.Context, rw http.ResponseWriter, orderId int) {
+// authorizeView will find the order that can be seen by the user, admin can see
+// any order while guest only their own, the error response is already written
+// when it returns false
+func (uc *OrdersUsecase) authorizeView(ctx context.Context, rw http.ResponseWriter, orderId int) (*ordersentity.Order, bool) {
 	_, claims, _ := jwtauth.FromContext(ctx)
 	sub, _ := strconv.Atoi(claims["sub"].(string))
 
@@ -226,7 +510,7 @@ func (uc *OrdersUsecase) SetSuccess(ctx context.Context, rw http.ResponseWriter,
 		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
 			constant.Header: constant.UserNotFound,
 		})
-		return
+		return nil, false
 	}
 
 	order, err := uc.ordersRepo.GetOrderById(ctx, orderId)
@@ -234,32 +518,85 @@ func (uc *OrdersUsecase) SetSuccess(ctx context.Context, rw http.ResponseWriter,
 		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
 			constant.App: "Order not found.",
 		})
-		return
+		return nil, false
 	}
 
-	if order.Status != "on the way" {
+	if user.Role != "admin" && user.Id != order.UserId {
 		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
-			constant.App: "Cannot change status success if status other than on the way.",
+			constant.App: "User doesn't have this order.",
 		})
+		return nil, false
+	}
+
+	return order, true
+}
+
+func (uc *OrdersUsecase) GetHistory(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	order, ok := uc.authorizeView(ctx, rw, orderId)
+	if !ok {
 		return
 	}
 
-	if user.Id != order.UserId {
-		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
-			constant.App: "User doesn't have this order.",
+	results, _ := uc.ordersRepo.GetOrderStatusHistories(ctx, order.Id)
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *OrdersUsecase) GetDetail(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	order, ok := uc.authorizeView(ctx, rw, orderId)
+	if !ok {
+		return
+	}
+
+	order.OrderItems, _ = uc.ordersRepo.GetAllOrderItems(ctx, order.Id)
+	histories, _ := uc.ordersRepo.GetOrderStatusHistories(ctx, order.Id)
+
+	results := ordersentity.OrderDetail{
+		Order:           *order,
+		StatusHistories: histories,
+	}
+	if len(order.ProofOfPayment) > 0 {
+		results.ProofOfPaymentUrl = null.StringFrom(fmt.Sprintf("/static/proof_payments/%s", order.ProofOfPayment))
+	}
+	if payment, err := uc.paymentsRepo.GetPaymentByOrderId(ctx, order.Id); err == nil {
+		results.Payment = payment
+	}
+	// orders shipped before the shipment existed only have the receipt on the order
+	noReceipt := order.NoReceipt
+	if shipment, err := uc.ordersRepo.GetShipmentByOrderId(ctx, order.Id); err == nil {
+		results.Shipment = shipment
+		noReceipt = shipment.ReceiptPhoto
+	}
+	if noReceipt.Valid {
+		results.NoReceiptUrl = null.StringFrom(fmt.Sprintf("/static/no_receipts/%s", noReceipt.String))
+	}
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *OrdersUsecase) GetTracking(ctx context.Context, rw http.ResponseWriter, orderId int) {
+	order, ok := uc.authorizeView(ctx, rw, orderId)
+	if !ok {
+		return
+	}
+
+	shipment, err := uc.ordersRepo.GetShipmentByOrderId(ctx, order.Id)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Shipment not found.",
 		})
 		return
 	}
 
-	// update status
-	uc.ordersRepo.UpdateOrder(ctx, &ordersentity.Order{
-		Id:     order.Id,
-		Status: "success",
-	})
+	results, err := uc.courierTracker.Track(ctx, shipment)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to get the tracking, please try again.",
+		})
+		return
+	}
 
-	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
-		constant.App: "Successfully set the order to success.",
-	})
+	response.WriteJSONResponse(rw, 200, results, nil)
 }
 
 func (uc *OrdersUsecase) GetMine(ctx context.Context, rw http.ResponseWriter, payload *ordersentity.QueryParamAllOrderSchema) {
@@ -282,10 +619,7 @@ func (uc *OrdersUsecase) GetMine(ctx context.Context, rw http.ResponseWriter, pa
 	payload.UserId = user.Id
 	results, _ := uc.ordersRepo.GetAllOrderPaginate(ctx, payload, false)
 
-	for i := 0; i < len(results.Data); i++ {
-		orderItems, _ := uc.ordersRepo.GetAllOrderItems(ctx, results.Data[i].Id)
-		results.Data[i].OrderItems = orderItems
-	}
+	uc.attachOrderItems(ctx, results.Data)
 
 	response.WriteJSONResponse(rw, 200, results, nil)
 }
@@ -316,10 +650,21 @@ func (uc *OrdersUsecase) GetAllOrder(ctx context.Context, rw http.ResponseWriter
 
 	results, _ := uc.ordersRepo.GetAllOrderPaginate(ctx, payload, true)
 
-	for i := 0; i < len(results.Data); i++ {
-		orderItems, _ := uc.ordersRepo.GetAllOrderItems(ctx, results.Data[i].Id)
-		results.Data[i].OrderItems = orderItems
-	}
+	uc.attachOrderItems(ctx, results.Data)
 
 	response.WriteJSONResponse(rw, 200, results, nil)
 }
+
+// attachOrderItems will load the items of every order with a single query
+func (uc *OrdersUsecase) attachOrderItems(ctx context.Context, orders []ordersentity.Order) {
+	orderIds := make([]int, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.Id
+	}
+
+	orderItems, _ := uc.ordersRepo.GetAllOrderItemsByOrderIds(ctx, orderIds)
+
+	for i := range orders {
+		orders[i].OrderItems = orderItems[orders[i].Id]
+	}
+}
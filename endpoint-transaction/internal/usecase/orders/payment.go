@@ -0,0 +1,187 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"gopkg.in/guregu/null.v4"
+)
+
+// charge will create the payment of the order on the provider, the order waits
+// in pending payment until the webhook tells the payment is done, the error
+// response is already written when it returns false
+func (uc *OrdersUsecase) charge(ctx context.Context, rw http.ResponseWriter,
+	payload *ordersentity.FormCreateSchema) (*paymentsentity.Payment, bool) {
+
+	externalId := fmt.Sprintf("ORDER-%d-%d", payload.UserId, time.Now().UnixNano())
+
+	result, err := uc.paymentProvider.Charge(ctx, &paymentsentity.Charge{
+		ExternalId: externalId,
+		Method:     payload.PaymentMethod,
+		BankCode:   payload.BankCode,
+		Amount:     payload.TotalAmount,
+	})
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to create the payment, please try again.",
+		})
+		return nil, false
+	}
+
+	return &paymentsentity.Payment{
+		Provider:   uc.paymentProvider.Name(),
+		Method:     payload.PaymentMethod,
+		BankCode:   null.NewString(payload.BankCode, payload.PaymentMethod == paymentsentity.MethodVirtualAccount),
+		ExternalId: externalId,
+		Amount:     payload.TotalAmount,
+		Status:     paymentsentity.StatusPending,
+		VaNumber:   result.VaNumber,
+		QrString:   result.QrString,
+		ExpiredAt:  result.ExpiredAt,
+	}, true
+}
+
+// requireRefund will keep the payment of an order that is no longer waiting for it,
+// the money is already taken so it is recorded to be refunded instead of rejected.
+// An expired payment can still arrive when the gateway took it before the void
+func (uc *OrdersUsecase) requireRefund(ctx context.Context, rw http.ResponseWriter, payment *paymentsentity.Payment) {
+	payment.Status = paymentsentity.StatusRefundRequired
+	payment.PaidAt = null.TimeFrom(time.Now())
+
+	if err := uc.paymentsRepo.RequireRefund(ctx, payment); err != nil {
+		if errors.Is(err, paymentsentity.ErrPaymentProcessed) {
+			response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+				constant.App: "Payment already processed.",
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Order is no longer waiting for payment, the payment will be refunded.",
+	})
+}
+
+// PaymentWebhook will settle the payment from the notification of the gateway,
+// a paid payment moves the order into payment verified while an expired or failed one
+// cancels the order and gives back the stock. A payment for an order that already left
+// pending payment or for an expired payment is kept as refund required
+func (uc *OrdersUsecase) PaymentWebhook(ctx context.Context, rw http.ResponseWriter,
+	payload *paymentsentity.JsonWebhookSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	payment, err := uc.paymentsRepo.GetPaymentByExternalId(ctx, payload.ExternalId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Payment not found.",
+		})
+		return
+	}
+
+	if payment.Amount != payload.Amount {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "The amount doesn't match the payment.",
+		})
+		return
+	}
+
+	if payment.Status == paymentsentity.StatusExpired && payload.Status == paymentsentity.StatusPaid {
+		uc.requireRefund(ctx, rw, payment)
+		return
+	}
+
+	// the gateway may send the same notification more than once
+	if payment.Status != paymentsentity.StatusPending {
+		response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+			constant.App: "Payment already processed.",
+		})
+		return
+	}
+
+	order, err := uc.ordersRepo.GetOrderById(ctx, payment.OrderId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Order not found.",
+		})
+		return
+	}
+
+	// the payment is already verified by the gateway
+	orderPayload := &ordersentity.Order{Id: order.Id, Status: ordersentity.StatusPaymentVerified}
+	var note string
+	if payload.Status != paymentsentity.StatusPaid {
+		note = fmt.Sprintf("Payment %s.", payload.Status)
+		orderPayload.Status = ordersentity.StatusCancelled
+		orderPayload.CancelReason = null.StringFrom(note)
+	}
+
+	notPending := "Order is no longer waiting for payment."
+	if !orderTransitions[orderPayload.Status].allows(order.Status, actorSystem) {
+		if payload.Status == paymentsentity.StatusPaid {
+			uc.requireRefund(ctx, rw, payment)
+			return
+		}
+
+		response.WriteJSONResponse(rw, 409, nil, map[string]interface{}{
+			constant.App: notPending,
+		})
+		return
+	}
+
+	payment.Status = payload.Status
+	if payload.Status == paymentsentity.StatusPaid {
+		payment.PaidAt = null.TimeFrom(time.Now())
+	}
+
+	err = uc.ordersRepo.SettlePayment(ctx, payment, orderPayload, &ordersentity.OrderStatusHistory{
+		FromStatus: null.StringFrom(order.Status),
+		Note:       null.NewString(note, len(note) > 0),
+	}, orderTransitions[orderPayload.Status].restoreStock)
+	if err != nil {
+		if errors.Is(err, paymentsentity.ErrPaymentProcessed) {
+			response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+				constant.App: "Payment already processed.",
+			})
+			return
+		}
+
+		if errors.Is(err, ordersentity.ErrStatusChanged) {
+			// the order got cancelled while the payment was being settled
+			if payload.Status == paymentsentity.StatusPaid {
+				uc.requireRefund(ctx, rw, payment)
+				return
+			}
+
+			response.WriteJSONResponse(rw, 409, nil, map[string]interface{}{
+				constant.App: notPending,
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully process the payment.",
+	})
+}
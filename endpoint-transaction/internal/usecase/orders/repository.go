@@ -2,21 +2,35 @@ package orders
 
 import (
 	"context"
+	"time"
 
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
 	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
 	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
-	"github.com/gomodule/redigo/redis"
+	paymentsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/payments"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
 )
 
 type ordersRepo interface {
 	GetOrderById(ctx context.Context, orderId int) (*ordersentity.Order, error)
+	GetOrderStaleByStatus(ctx context.Context, status string, deadline time.Duration, limit int) ([]ordersentity.Order, error)
 	UpdateOrder(ctx context.Context, payload *ordersentity.Order) error
-	Insert(ctx context.Context, payload *ordersentity.FormCreateSchema) int
-	InsertItem(ctx context.Context, payload *ordersentity.OrderItem) int
+	Create(ctx context.Context, payload *ordersentity.FormCreateSchema,
+		items []ordersentity.OrderItem, cartIds []int, payment *paymentsentity.Payment) (int, error)
+	UpdateStatus(ctx context.Context, payload *ordersentity.Order,
+		history *ordersentity.OrderStatusHistory, restoreStock bool) error
+	SettlePayment(ctx context.Context, payment *paymentsentity.Payment,
+		payload *ordersentity.Order, history *ordersentity.OrderStatusHistory, restoreStock bool) error
+	Ship(ctx context.Context, payload *ordersentity.Order,
+		history *ordersentity.OrderStatusHistory, shipment *shippingentity.Shipment) error
+	GetShipmentByOrderId(ctx context.Context, orderId int) (*shippingentity.Shipment, error)
+	AssignInvoiceNumber(ctx context.Context, order *ordersentity.Order) (string, error)
+	GetOrderStatusHistories(ctx context.Context, orderId int) ([]ordersentity.OrderStatusHistory, error)
 	GetAllOrderPaginate(ctx context.Context,
 		payload *ordersentity.QueryParamAllOrderSchema, isAdmin bool) (*ordersentity.OrderPaginate, error)
 	GetAllOrderItems(ctx context.Context, orderId int) ([]ordersentity.OrderItemProduct, error)
+	GetAllOrderItemsByOrderIds(ctx context.Context, orderIds []int) (map[int][]ordersentity.OrderItemProduct, error)
 }
 
 type authRepo interface {
@@ -24,6 +38,36 @@ type authRepo interface {
 }
 
 type cartsRepo interface {
-	Delete(ctx context.Context, payload *cartsentity.JsonMultipleSchema) error
-	ItemInPayment(ctx context.Context, redisCli *redis.Pool, userId int) ([]cartsentity.CartProduct, error)
+	ItemInPayment(ctx context.Context, userId int) ([]cartsentity.CartProduct, error)
+}
+
+type vouchersRepo interface {
+	GetVoucherByCode(ctx context.Context, code string) (*vouchersentity.Voucher, error)
+	CountVoucherUsage(ctx context.Context, voucherId, userId int) (*vouchersentity.VoucherUsageCount, error)
+}
+
+// shippingProvider calculate the shipping cost to the destination,
+// weight is in grams and an empty province means the destination is unknown
+type shippingProvider interface {
+	Quote(ctx context.Context, province, city string, weight int) (*shippingentity.Quote, error)
+}
+
+type paymentsRepo interface {
+	GetPaymentByExternalId(ctx context.Context, externalId string) (*paymentsentity.Payment, error)
+	GetPaymentByOrderId(ctx context.Context, orderId int) (*paymentsentity.Payment, error)
+	RequireRefund(ctx context.Context, payload *paymentsentity.Payment) error
+}
+
+// paymentProvider create the charge on the payment gateway, the order
+// is settled later by the webhook of the gateway or the charge is voided
+// when the order cannot be saved
+type paymentProvider interface {
+	Name() string
+	Charge(ctx context.Context, payload *paymentsentity.Charge) (*paymentsentity.ChargeResult, error)
+	Void(ctx context.Context, payload *paymentsentity.Charge) error
+}
+
+// courierTracker find the journey of the shipment from the courier
+type courierTracker interface {
+	Track(ctx context.Context, shipment *shippingentity.Shipment) (*shippingentity.Tracking, error)
 }
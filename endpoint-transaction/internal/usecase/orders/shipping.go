@@ -0,0 +1,77 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+)
+
+// quoteShipping will ask the provider the shipping cost of the items in payment,
+// the error response is already written when it returns false
+func (uc *OrdersUsecase) quoteShipping(ctx context.Context, rw http.ResponseWriter,
+	province, city string, productData []cartsentity.CartProduct) (*shippingentity.Quote, bool) {
+
+	weight := 0
+	for _, product := range productData {
+		weight += product.CartQty * product.ProductWeight
+	}
+
+	quote, err := uc.shippingProvider.Quote(ctx, province, city, weight)
+	if errors.Is(err, shippingentity.ErrNotCovered) {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Shipping is not available for the destination.",
+		})
+		return nil, false
+	}
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: "Failed to calculate the shipping cost, please try again.",
+		})
+		return nil, false
+	}
+
+	return quote, true
+}
+
+func (uc *OrdersUsecase) ShippingQuote(ctx context.Context, rw http.ResponseWriter,
+	payload *shippingentity.JsonQuoteSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return
+	}
+
+	productData, _ := uc.cartsRepo.ItemInPayment(ctx, user.Id)
+	if len(productData) < 1 {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Ups, item in payment not found.",
+		})
+		return
+	}
+
+	quote, ok := uc.quoteShipping(ctx, rw, payload.Province, payload.City, productData)
+	if !ok {
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, quote, nil)
+}
@@ -0,0 +1,101 @@
+package orders
+
+import (
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+)
+
+// actor is who allowed to move an order into a status,
+// several actors can be combined with a bitwise or
+type actor uint8
+
+const (
+	actorAdmin actor = 1 << iota
+	actorBuyer
+	// actorSystem is the service itself, e.g. the payment webhook or the worker
+	actorSystem
+)
+
+func (a actor) has(b actor) bool {
+	return a&b != 0
+}
+
+type transition struct {
+	// from is the status the order can leave with the actors allowed to do it
+	from         map[string]actor
+	restoreStock bool
+	// invalidMessage returned when the order is not in one of the from status
+	invalidMessage string
+}
+
+// orderTransitions declares every status an order can move into,
+// any status change must be listed here
+var orderTransitions = map[string]transition{
+	ordersentity.StatusPaymentVerified: {
+		from: map[string]actor{
+			ordersentity.StatusOngoing:        actorAdmin,
+			ordersentity.StatusPendingPayment: actorSystem,
+		},
+		invalidMessage: "Cannot verify the payment if status other than ongoing.",
+	},
+	ordersentity.StatusReuploadRequested: {
+		from:           map[string]actor{ordersentity.StatusOngoing: actorAdmin},
+		invalidMessage: "Cannot request a new proof of payment if status other than ongoing.",
+	},
+	ordersentity.StatusOngoing: {
+		from:           map[string]actor{ordersentity.StatusReuploadRequested: actorBuyer},
+		invalidMessage: "Cannot reupload the proof of payment if status other than reupload requested.",
+	},
+	ordersentity.StatusReject: {
+		from: map[string]actor{
+			ordersentity.StatusOngoing:           actorAdmin,
+			ordersentity.StatusReuploadRequested: actorAdmin,
+			ordersentity.StatusPaymentVerified:   actorAdmin,
+		},
+		restoreStock:   true,
+		invalidMessage: "Cannot change status rejected if status other than ongoing, reupload requested or payment verified.",
+	},
+	ordersentity.StatusOnTheWay: {
+		from:           map[string]actor{ordersentity.StatusPaymentVerified: actorAdmin},
+		invalidMessage: "Cannot change status on the way if status other than payment verified.",
+	},
+	ordersentity.StatusSuccess: {
+		// the system completes the order when the buyer never confirm it
+		from:           map[string]actor{ordersentity.StatusOnTheWay: actorBuyer | actorSystem},
+		invalidMessage: "Cannot change status success if status other than on the way.",
+	},
+	ordersentity.StatusCancelled: {
+		// the payment webhook cancels the order when the payment failed or expired
+		from: map[string]actor{
+			ordersentity.StatusPendingPayment:    actorBuyer | actorSystem,
+			ordersentity.StatusOngoing:           actorBuyer,
+			ordersentity.StatusReuploadRequested: actorBuyer,
+		},
+		restoreStock:   true,
+		invalidMessage: "Cannot cancel the order if status other than pending payment, ongoing or reupload requested.",
+	},
+	ordersentity.StatusExpired: {
+		from: map[string]actor{
+			ordersentity.StatusPendingPayment:    actorSystem,
+			ordersentity.StatusOngoing:           actorSystem,
+			ordersentity.StatusReuploadRequested: actorSystem,
+		},
+		restoreStock:   true,
+		invalidMessage: "Cannot expire the order if status other than pending payment, ongoing or reupload requested.",
+	},
+}
+
+// allows is true when the actor can move the order out of status
+func (t transition) allows(status string, a actor) bool {
+	return t.from[status].has(a)
+}
+
+// adminOnly is true when no buyer can make the transition,
+// so the user can be refused before the order is loaded
+func (t transition) adminOnly() bool {
+	for _, a := range t.from {
+		if a.has(actorBuyer) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	"github.com/creent-production/cdk-go/response"
+	"gopkg.in/guregu/null.v4"
+)
+
+// applyVoucher will check the voucher against the items in payment and set the
+// discount into the payload, the error response is already written when it returns false
+func (uc *OrdersUsecase) applyVoucher(ctx context.Context, rw http.ResponseWriter,
+	payload *ordersentity.FormCreateSchema, productData []cartsentity.CartProduct) bool {
+
+	payload.DiscountAmount = 0
+	payload.VoucherId = null.Int{}
+	if len(payload.VoucherCode) < 1 {
+		return true
+	}
+
+	voucher, err := uc.vouchersRepo.GetVoucherByCode(ctx, strings.ToUpper(payload.VoucherCode))
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Voucher not found.",
+		})
+		return false
+	}
+
+	now := time.Now()
+	if now.Before(voucher.StartedAt) || !now.Before(voucher.ExpiredAt) {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Voucher is not valid at this time.",
+		})
+		return false
+	}
+
+	usage, err := uc.vouchersRepo.CountVoucherUsage(ctx, voucher.Id, payload.UserId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return false
+	}
+	if voucher.UsageLimit > 0 && usage.Total >= voucher.UsageLimit {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Voucher usage limit has been reached.",
+		})
+		return false
+	}
+	if voucher.UsageLimitPerUser > 0 && usage.User >= voucher.UsageLimitPerUser {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "You have reached the usage limit of this voucher.",
+		})
+		return false
+	}
+
+	// only the products in the voucher scope count for min spend and discount
+	subtotal := 0
+	for _, product := range productData {
+		if voucher.InScope(product.CartProductId, product.ProductCategoryId) {
+			subtotal += product.CartQty * product.ProductPrice
+		}
+	}
+	if subtotal < 1 {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Voucher cannot be used for the products in the payment.",
+		})
+		return false
+	}
+	if subtotal < voucher.MinSpend {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: fmt.Sprintf("Minimum spend for this voucher is %d.", voucher.MinSpend),
+		})
+		return false
+	}
+
+	payload.DiscountAmount = voucher.Discount(subtotal)
+	payload.VoucherId = null.IntFrom(int64(voucher.Id))
+
+	return true
+}
@@ -0,0 +1,31 @@
+package returns
+
+import (
+	"context"
+
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	returnsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/returns"
+	"gopkg.in/guregu/null.v4"
+)
+
+type returnsRepo interface {
+	Create(ctx context.Context, payload *returnsentity.FormCreateSchema,
+		items []returnsentity.ReturnItem, orderedQty map[int]int) (int, error)
+	Approve(ctx context.Context, returnId, processedBy int, refund *returnsentity.Refund) error
+	Reject(ctx context.Context, returnId, processedBy int, reason null.String) error
+	GetReturnById(ctx context.Context, returnId int) (*returnsentity.Return, error)
+	GetReturnItems(ctx context.Context, returnId int) ([]returnsentity.ReturnItem, error)
+	GetRefundByReturnId(ctx context.Context, returnId int) (*returnsentity.Refund, error)
+	GetAllReturnPaginate(ctx context.Context,
+		payload *returnsentity.QueryParamAllReturnSchema, isAdmin bool) (*returnsentity.ReturnPaginate, error)
+}
+
+type ordersRepo interface {
+	GetOrderById(ctx context.Context, orderId int) (*ordersentity.Order, error)
+	GetAllOrderItems(ctx context.Context, orderId int) ([]ordersentity.OrderItemProduct, error)
+}
+
+type authRepo interface {
+	GetUserById(ctx context.Context, userId int) (*authentity.User, error)
+}
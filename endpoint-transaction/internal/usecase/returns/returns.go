@@ -0,0 +1,356 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	returnsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/returns"
+	"github.com/creent-production/cdk-go/magicimage"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+	"gopkg.in/guregu/null.v4"
+)
+
+type ReturnsUsecase struct {
+	returnsRepo returnsRepo
+	ordersRepo  ordersRepo
+	authRepo    authRepo
+}
+
+func NewReturnsUsecase(returnRepo returnsRepo, orderRepo ordersRepo, authRepo authRepo) *ReturnsUsecase {
+	return &ReturnsUsecase{
+		returnsRepo: returnRepo,
+		ordersRepo:  orderRepo,
+		authRepo:    authRepo,
+	}
+}
+
+// currentUser will find the user of the token,
+// the error response is already written when it returns false
+func (uc *ReturnsUsecase) currentUser(ctx context.Context, rw http.ResponseWriter) (*authentity.User, bool) {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// authorizeAdmin will make sure only admin can process the returns,
+// the error response is already written when it returns false
+func (uc *ReturnsUsecase) authorizeAdmin(ctx context.Context, rw http.ResponseWriter) (*authentity.User, bool) {
+	user, ok := uc.currentUser(ctx, rw)
+	if !ok {
+		return nil, false
+	}
+
+	if user.Role != "admin" {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
+		})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// pendingReturn will find the return that is still waiting for the admin,
+// the error response is already written when it returns false
+func (uc *ReturnsUsecase) pendingReturn(ctx context.Context, rw http.ResponseWriter,
+	returnId int, invalidMessage string) (*returnsentity.Return, bool) {
+
+	t, err := uc.returnsRepo.GetReturnById(ctx, returnId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Return not found.",
+		})
+		return nil, false
+	}
+
+	if t.Status != returnsentity.StatusPending {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: invalidMessage,
+		})
+		return nil, false
+	}
+
+	return t, true
+}
+
+func (uc *ReturnsUsecase) Create(ctx context.Context, rw http.ResponseWriter,
+	file *multipart.Form, payload *returnsentity.FormCreateSchema) {
+
+	magicImage := magicimage.New(file)
+	if err := magicImage.ValidateSingleImage("photo"); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, map[string]interface{}{
+			"photo": err.Error(),
+		})
+		return
+	}
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	user, ok := uc.currentUser(ctx, rw)
+	if !ok {
+		return
+	}
+
+	order, err := uc.ordersRepo.GetOrderById(ctx, payload.OrderId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Order not found.",
+		})
+		return
+	}
+
+	if user.Id != order.UserId {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "User doesn't have this order.",
+		})
+		return
+	}
+
+	if order.Status != ordersentity.StatusSuccess {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "Cannot return the order if status other than success.",
+		})
+		return
+	}
+
+	orderItems, _ := uc.ordersRepo.GetAllOrderItems(ctx, order.Id)
+	orderedItems := make(map[int]ordersentity.OrderItemProduct)
+	orderedQty := make(map[int]int)
+	for _, item := range orderItems {
+		orderedItems[item.OrderItemsId] = item
+		orderedQty[item.OrderItemsId] = item.OrderItemsQty
+	}
+
+	// the price is taken from the order item, that is what the buyer paid
+	var items []returnsentity.ReturnItem
+	for _, item := range payload.Items {
+		orderItem, ok := orderedItems[item.OrderItemId]
+		if !ok {
+			response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+				constant.App: fmt.Sprintf("Order item %d not found in the order.", item.OrderItemId),
+			})
+			return
+		}
+		items = append(items, returnsentity.ReturnItem{
+			Qty:         item.Qty,
+			Price:       orderItem.OrderItemsPrice,
+			OrderItemId: orderItem.OrderItemsId,
+			ProductId:   orderItem.OrderItemsProductId,
+		})
+	}
+
+	magicImage.SaveImages(500, 500, "/app/static/returns", false)
+	payload.Photo = magicImage.FileNames[0]
+	payload.UserId = user.Id
+
+	if _, err := uc.returnsRepo.Create(ctx, payload, items, orderedQty); err != nil {
+		magicimage.DeleteFolderAndFile(fmt.Sprintf("/app/static/returns/%s", payload.Photo))
+
+		var exceedQty *returnsentity.ExceedQtyError
+		if errors.As(err, &exceedQty) {
+			response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+				constant.App: exceedQty.Error(),
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 201, nil, map[string]interface{}{
+		constant.App: "Successfully open the return request.",
+	})
+}
+
+func (uc *ReturnsUsecase) Approve(ctx context.Context, rw http.ResponseWriter, returnId int) {
+	const invalidMessage = "Cannot approve the return if status other than pending."
+
+	user, ok := uc.authorizeAdmin(ctx, rw)
+	if !ok {
+		return
+	}
+
+	t, ok := uc.pendingReturn(ctx, rw, returnId, invalidMessage)
+	if !ok {
+		return
+	}
+
+	order, err := uc.ordersRepo.GetOrderById(ctx, t.OrderId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Order not found.",
+		})
+		return
+	}
+	items, _ := uc.returnsRepo.GetReturnItems(ctx, t.Id)
+
+	refund := &returnsentity.Refund{
+		Amount:  refundAmount(order, items),
+		Status:  returnsentity.RefundStatusPending,
+		OrderId: order.Id,
+	}
+
+	// approve, give back the stock and save the refund
+	if err := uc.returnsRepo.Approve(ctx, t.Id, user.Id, refund); err != nil {
+		if errors.Is(err, returnsentity.ErrReturnProcessed) {
+			response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+				constant.App: invalidMessage,
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, refund, map[string]interface{}{
+		constant.App: "Successfully approve the return.",
+	})
+}
+
+// refundAmount is the price of the returned items minus their share of the
+// order discount, the shipping cost is not refunded
+func refundAmount(order *ordersentity.Order, items []returnsentity.ReturnItem) int {
+	subtotal := 0
+	for _, item := range items {
+		subtotal += item.Qty * item.Price
+	}
+
+	itemsTotal := order.TotalAmount - order.ShippingCost + order.DiscountAmount
+	if order.DiscountAmount < 1 || itemsTotal < 1 {
+		return subtotal
+	}
+
+	return subtotal - subtotal*order.DiscountAmount/itemsTotal
+}
+
+func (uc *ReturnsUsecase) Reject(ctx context.Context, rw http.ResponseWriter, returnId int,
+	payload *returnsentity.JsonRejectSchema) {
+
+	const invalidMessage = "Cannot reject the return if status other than pending."
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	user, ok := uc.authorizeAdmin(ctx, rw)
+	if !ok {
+		return
+	}
+
+	t, ok := uc.pendingReturn(ctx, rw, returnId, invalidMessage)
+	if !ok {
+		return
+	}
+
+	err := uc.returnsRepo.Reject(ctx, t.Id, user.Id, null.NewString(payload.Reason, len(payload.Reason) > 0))
+	if err != nil {
+		if errors.Is(err, returnsentity.ErrReturnProcessed) {
+			response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+				constant.App: invalidMessage,
+			})
+			return
+		}
+
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully reject the return.",
+	})
+}
+
+func (uc *ReturnsUsecase) GetDetail(ctx context.Context, rw http.ResponseWriter, returnId int) {
+	user, ok := uc.currentUser(ctx, rw)
+	if !ok {
+		return
+	}
+
+	t, err := uc.returnsRepo.GetReturnById(ctx, returnId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Return not found.",
+		})
+		return
+	}
+
+	if user.Role != "admin" && user.Id != t.UserId {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: "User doesn't have this return.",
+		})
+		return
+	}
+
+	results := returnsentity.ReturnDetail{
+		Return:   *t,
+		PhotoUrl: fmt.Sprintf("/static/returns/%s", t.Photo),
+	}
+	results.Items, _ = uc.returnsRepo.GetReturnItems(ctx, t.Id)
+	if refund, err := uc.returnsRepo.GetRefundByReturnId(ctx, t.Id); err == nil {
+		results.Refund = refund
+	}
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *ReturnsUsecase) GetMine(ctx context.Context, rw http.ResponseWriter, payload *returnsentity.QueryParamAllReturnSchema) {
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	user, ok := uc.currentUser(ctx, rw)
+	if !ok {
+		return
+	}
+
+	payload.UserId = user.Id
+	results, _ := uc.returnsRepo.GetAllReturnPaginate(ctx, payload, false)
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *ReturnsUsecase) GetAll(ctx context.Context, rw http.ResponseWriter, payload *returnsentity.QueryParamAllReturnSchema) {
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if _, ok := uc.authorizeAdmin(ctx, rw); !ok {
+		return
+	}
+
+	results, _ := uc.returnsRepo.GetAllReturnPaginate(ctx, payload, true)
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
@@ -0,0 +1,22 @@
+package shipping
+
+import (
+	"context"
+
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+)
+
+type shippingRepo interface {
+	GetRateById(ctx context.Context, id int) (*shippingentity.Rate, error)
+	GetRateByTier(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) (*shippingentity.Rate, error)
+	GetAllRatePaginate(ctx context.Context,
+		payload *shippingentity.QueryParamAllRateSchema) (*shippingentity.RatePaginate, error)
+	Insert(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) (int, error)
+	Update(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) error
+	Delete(ctx context.Context, rateId int) error
+}
+
+type authRepo interface {
+	GetUserById(ctx context.Context, userId int) (*authentity.User, error)
+}
@@ -0,0 +1,187 @@
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+)
+
+type ShippingUsecase struct {
+	shippingRepo shippingRepo
+	authRepo     authRepo
+}
+
+func NewShippingUsecase(shippingRepo shippingRepo, authRepo authRepo) *ShippingUsecase {
+	return &ShippingUsecase{
+		shippingRepo: shippingRepo,
+		authRepo:     authRepo,
+	}
+}
+
+// authorizeAdmin will make sure only admin can manage the rates,
+// the error response is already written when it returns false
+func (uc *ShippingUsecase) authorizeAdmin(ctx context.Context, rw http.ResponseWriter) bool {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return false
+	}
+
+	if user.Role != "admin" {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
+		})
+		return false
+	}
+
+	return true
+}
+
+// tierTaken tells whether another rate already covers the same destination and weight,
+// the quote could not pick between them
+func (uc *ShippingUsecase) tierTaken(ctx context.Context, payload *shippingentity.JsonCreateUpdateRateSchema) bool {
+	rate, err := uc.shippingRepo.GetRateByTier(ctx, payload)
+	return err == nil && rate.Id != payload.Id
+}
+
+func (uc *ShippingUsecase) Create(ctx context.Context,
+	rw http.ResponseWriter, payload *shippingentity.JsonCreateUpdateRateSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	payload.Province = strings.TrimSpace(payload.Province)
+	payload.City = strings.TrimSpace(payload.City)
+	if uc.tierTaken(ctx, payload) {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: fmt.Sprintf(constant.AlreadyTaken, "max_weight"),
+		})
+		return
+	}
+
+	// save into database
+	if _, err := uc.shippingRepo.Insert(ctx, payload); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 201, nil, map[string]interface{}{
+		constant.App: "Successfully add a new shipping rate.",
+	})
+}
+
+func (uc *ShippingUsecase) GetAll(ctx context.Context,
+	rw http.ResponseWriter, payload *shippingentity.QueryParamAllRateSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	results, _ := uc.shippingRepo.GetAllRatePaginate(ctx, payload)
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *ShippingUsecase) GetById(ctx context.Context, rw http.ResponseWriter, rateId int) {
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	t, err := uc.shippingRepo.GetRateById(ctx, rateId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Shipping rate not found.",
+		})
+		return
+	}
+	response.WriteJSONResponse(rw, 200, t, nil)
+}
+
+func (uc *ShippingUsecase) Update(ctx context.Context,
+	rw http.ResponseWriter, payload *shippingentity.JsonCreateUpdateRateSchema, rateId int) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	rate, err := uc.shippingRepo.GetRateById(ctx, rateId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Shipping rate not found.",
+		})
+		return
+	}
+
+	payload.Id = rate.Id
+	payload.Province = strings.TrimSpace(payload.Province)
+	payload.City = strings.TrimSpace(payload.City)
+	if uc.tierTaken(ctx, payload) {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: fmt.Sprintf(constant.AlreadyTaken, "max_weight"),
+		})
+		return
+	}
+
+	if err := uc.shippingRepo.Update(ctx, payload); err != nil {
+		response.WriteJSONResponse(rw, 500, nil, map[string]interface{}{
+			constant.App: constant.FailedSaveData,
+		})
+		return
+	}
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully update the shipping rate.",
+	})
+}
+
+func (uc *ShippingUsecase) Delete(ctx context.Context, rw http.ResponseWriter, rateId int) {
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	rate, err := uc.shippingRepo.GetRateById(ctx, rateId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Shipping rate not found.",
+		})
+		return
+	}
+
+	// the orders keep their own shipping cost
+	uc.shippingRepo.Delete(ctx, rate.Id)
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully delete the shipping rate.",
+	})
+}
@@ -0,0 +1,22 @@
+package vouchers
+
+import (
+	"context"
+
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
+)
+
+type vouchersRepo interface {
+	GetVoucherByCode(ctx context.Context, code string) (*vouchersentity.Voucher, error)
+	GetVoucherById(ctx context.Context, id int) (*vouchersentity.Voucher, error)
+	Insert(ctx context.Context, payload *vouchersentity.JsonCreateUpdateSchema) int
+	GetAllVoucherPaginate(ctx context.Context,
+		payload *vouchersentity.QueryParamAllVoucherSchema) (*vouchersentity.VoucherPaginate, error)
+	Update(ctx context.Context, payload *vouchersentity.JsonCreateUpdateSchema) error
+	Delete(ctx context.Context, voucherId int) error
+}
+
+type authRepo interface {
+	GetUserById(ctx context.Context, userId int) (*authentity.User, error)
+}
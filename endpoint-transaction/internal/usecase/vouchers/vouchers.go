@@ -0,0 +1,193 @@
+package vouchers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
+	"github.com/creent-production/cdk-go/response"
+	"github.com/creent-production/cdk-go/validation"
+	"github.com/go-chi/jwtauth"
+	"github.com/lib/pq"
+)
+
+type VouchersUsecase struct {
+	vouchersRepo vouchersRepo
+	authRepo     authRepo
+}
+
+func NewVouchersUsecase(voucherRepo vouchersRepo, authRepo authRepo) *VouchersUsecase {
+	return &VouchersUsecase{
+		vouchersRepo: voucherRepo,
+		authRepo:     authRepo,
+	}
+}
+
+// authorizeAdmin will make sure only admin can manage the vouchers,
+// the error response is already written when it returns false
+func (uc *VouchersUsecase) authorizeAdmin(ctx context.Context, rw http.ResponseWriter) bool {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := strconv.Atoi(claims["sub"].(string))
+
+	user, err := uc.authRepo.GetUserById(ctx, sub)
+	if err != nil {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: constant.UserNotFound,
+		})
+		return false
+	}
+
+	if user.Role != "admin" {
+		response.WriteJSONResponse(rw, 401, nil, map[string]interface{}{
+			constant.Header: fmt.Sprintf(constant.PrivilegesOnly, "admin"),
+		})
+		return false
+	}
+
+	return true
+}
+
+// validatePayload run the checks that cannot be expressed in the struct tags
+func validatePayload(payload *vouchersentity.JsonCreateUpdateSchema) map[string]interface{} {
+	if err := validation.StructValidate(payload); err != nil {
+		return err
+	}
+
+	if payload.Kind == vouchersentity.KindPercentage && payload.Amount > 100 {
+		return map[string]interface{}{"amount": fmt.Sprintf(validation.Lte, "100")}
+	}
+
+	if !payload.ExpiredAt.After(payload.StartedAt) {
+		return map[string]interface{}{"expired_at": fmt.Sprintf(validation.Gt, "started_at")}
+	}
+
+	payload.Code = strings.ToUpper(payload.Code)
+	if payload.CategoryIds == nil {
+		payload.CategoryIds = pq.Int64Array{}
+	}
+	if payload.ProductIds == nil {
+		payload.ProductIds = pq.Int64Array{}
+	}
+
+	return nil
+}
+
+func (uc *VouchersUsecase) Create(ctx context.Context,
+	rw http.ResponseWriter, payload *vouchersentity.JsonCreateUpdateSchema) {
+
+	if err := validatePayload(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	if _, err := uc.vouchersRepo.GetVoucherByCode(ctx, payload.Code); err == nil {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: fmt.Sprintf(constant.AlreadyTaken, "code"),
+		})
+		return
+	}
+
+	// save into database
+	uc.vouchersRepo.Insert(ctx, payload)
+
+	response.WriteJSONResponse(rw, 201, nil, map[string]interface{}{
+		constant.App: "Successfully add a new voucher.",
+	})
+}
+
+func (uc *VouchersUsecase) GetAll(ctx context.Context,
+	rw http.ResponseWriter, payload *vouchersentity.QueryParamAllVoucherSchema) {
+
+	if err := validation.StructValidate(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	results, _ := uc.vouchersRepo.GetAllVoucherPaginate(ctx, payload)
+
+	response.WriteJSONResponse(rw, 200, results, nil)
+}
+
+func (uc *VouchersUsecase) GetById(ctx context.Context, rw http.ResponseWriter, voucherId int) {
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	t, err := uc.vouchersRepo.GetVoucherById(ctx, voucherId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Voucher not found.",
+		})
+		return
+	}
+	response.WriteJSONResponse(rw, 200, t, nil)
+}
+
+func (uc *VouchersUsecase) Update(ctx context.Context,
+	rw http.ResponseWriter, payload *vouchersentity.JsonCreateUpdateSchema, voucherId int) {
+
+	if err := validatePayload(payload); err != nil {
+		response.WriteJSONResponse(rw, 422, nil, err)
+		return
+	}
+
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	voucher, err := uc.vouchersRepo.GetVoucherById(ctx, voucherId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Voucher not found.",
+		})
+		return
+	}
+
+	// check code duplicate
+	if _, err := uc.vouchersRepo.GetVoucherByCode(ctx, payload.Code); err == nil && voucher.Code != payload.Code {
+		response.WriteJSONResponse(rw, 400, nil, map[string]interface{}{
+			constant.App: fmt.Sprintf(constant.AlreadyTaken, "code"),
+		})
+		return
+	}
+
+	payload.Id = voucher.Id
+	uc.vouchersRepo.Update(ctx, payload)
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully update the voucher.",
+	})
+}
+
+func (uc *VouchersUsecase) Delete(ctx context.Context, rw http.ResponseWriter, voucherId int) {
+	if !uc.authorizeAdmin(ctx, rw) {
+		return
+	}
+
+	voucher, err := uc.vouchersRepo.GetVoucherById(ctx, voucherId)
+	if err != nil {
+		response.WriteJSONResponse(rw, 404, nil, map[string]interface{}{
+			constant.App: "Voucher not found.",
+		})
+		return
+	}
+
+	// the usages stay as the history of the orders
+	uc.vouchersRepo.Delete(ctx, voucher.Id)
+
+	response.WriteJSONResponse(rw, 200, nil, map[string]interface{}{
+		constant.App: "Successfully delete the voucher.",
+	})
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// renewScript extend the lock only when it still belongs to the caller
+var renewScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript delete the lock only when it still belongs to the caller
+var releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Leader is a lock in redis held by a single replica, the holder must call
+// Acquire again before the ttl is over to keep it
+type Leader struct {
+	redisCli *redis.Pool
+	key      string
+	id       string
+	ttl      time.Duration
+}
+
+func NewLeader(redisCli *redis.Pool, key string, ttl time.Duration) *Leader {
+	hostname, _ := os.Hostname()
+
+	return &Leader{
+		redisCli: redisCli,
+		key:      key,
+		id:       fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		ttl:      ttl,
+	}
+}
+
+// Acquire will take the lock or extend it when it is already held by this replica,
+// it returns false when another replica is the leader
+func (l *Leader) Acquire() (bool, error) {
+	conn := l.redisCli.Get()
+	defer conn.Close()
+
+	renewed, err := redis.Int(renewScript.Do(conn, l.key, l.id, l.ttl.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+	if renewed == 1 {
+		return true, nil
+	}
+
+	_, err = redis.String(conn.Do("SET", l.key, l.id, "NX", "PX", l.ttl.Milliseconds()))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Release will give up the lock so another replica can take over right away
+func (l *Leader) Release() error {
+	conn := l.redisCli.Get()
+	defer conn.Close()
+
+	_, err := releaseScript.Do(conn, l.key, l.id)
+
+	return err
+}
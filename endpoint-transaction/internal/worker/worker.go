@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a task run on every tick by the leader only
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Worker struct {
+	leader   *Leader
+	interval time.Duration
+	jobs     []Job
+}
+
+func New(leader *Leader, interval time.Duration, jobs ...Job) *Worker {
+	return &Worker{
+		leader:   leader,
+		interval: interval,
+		jobs:     jobs,
+	}
+}
+
+// Run will run the jobs every interval until ctx is done, a replica that is
+// not the leader only keeps trying to become one
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			if err := w.leader.Release(); err != nil {
+				log.Printf("failed to release the leader: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) tick(ctx context.Context) {
+	leader, err := w.leader.Acquire()
+	if err != nil {
+		log.Printf("failed to acquire the leader: %v", err)
+		return
+	}
+	if !leader {
+		return
+	}
+
+	for _, job := range w.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := job.Run(ctx); err != nil {
+			log.Printf("job %s failed: %v", job.Name, err)
+		}
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"testing"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/constant"
 	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
 	categoriesentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/categories"
 	productsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/products"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
 	"github.com/creent-production/cdk-go/auth"
 	"github.com/go-chi/jwtauth"
 	"github.com/stretchr/testify/assert"
@@ -32,6 +35,9 @@ var (
 	tokenAdmin    = ""
 	tokenGuest    = ""
 	tokenNotFound = ""
+	// shipping rate of the test destination
+	shippingRateId = 0
+	shippingCost   = 10000
 )
 
 func TestUpCart(t *testing.T) {
@@ -82,6 +88,18 @@ func TestUpCart(t *testing.T) {
 		CategoryId:  categoryId2,
 	}
 	productId2 = repo.productsRepo.Insert(context.Background(), &payload2)
+
+	// create shipping rate
+	var err error
+	shippingRateId, err = repo.shippingRepo.Insert(context.Background(), &shippingentity.JsonCreateUpdateRateSchema{
+		Province:  provinceShipping,
+		City:      cityShipping,
+		MaxWeight: 10000,
+		Cost:      shippingCost,
+	})
+	if err != nil {
+		panic(err)
+	}
 }
 
 func TestValidationPutToCart(t *testing.T) {
@@ -280,6 +298,61 @@ func TestGetAllDataCart(t *testing.T) {
 	assert.Equal(t, 200, response.Result().StatusCode)
 }
 
+func TestTokenVersionCart(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+	redisCli, _ := config.RedisConnect(cfg)
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+
+	// the password of the guest is changed on endpoint-auth
+	conn := redisCli.Get()
+	defer conn.Close()
+	key := fmt.Sprintf(constant.TokenVersionKey, user.Id)
+	conn.Do("SETEX", key, 60, 1)
+	defer conn.Do("DEL", key)
+
+	token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user.Id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	token["ver"] = 1
+	tokenNewVersion := auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+
+	tests := [...]struct {
+		name       string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "issued before the change",
+			token:      tokenGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "issued after the change",
+			token:      tokenNewVersion,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			req, _ := http.NewRequest(http.MethodGet, prefixCart, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			if test.statusCode == 401 {
+				assert.Equal(t, "token is revoked", data["detail_message"].(map[string]interface{})["_header"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
 func TestValidationDeleteCart(t *testing.T) {
 	_, s := setupEnvironment()
 
@@ -499,3 +572,59 @@ func TestGetAllItemInPaymentCart(t *testing.T) {
 	assert.NotNil(t, data["results"])
 	assert.Equal(t, 200, response.Result().StatusCode)
 }
+
+func TestGetCheckoutCart(t *testing.T) {
+	_, s := setupEnvironment()
+
+	tests := [...]struct {
+		name       string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "checkout not found",
+			expected:   "Checkout not found or already expired.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			req, _ := http.NewRequest(http.MethodGet, prefixCart+"/checkout", nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "checkout not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				results := data["results"].(map[string]interface{})
+				assert.Equal(t, 1, len(results["items"].([]interface{})))
+				assert.Equal(t, float64(1), results["total_qty"])
+				assert.Equal(t, float64(1), results["total_amount"])
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
@@ -1,19 +1,40 @@
 package tests
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/courier"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	cartsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/carts"
+	emailsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/emails"
 	ordersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/orders"
+	returnsentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/returns"
+	vouchersentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/vouchers"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/mailer"
+	paymentpkg "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/payment"
+	emailsusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/emails"
+	ordersusecase "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/usecase/orders"
+	"github.com/creent-production/cdk-go/auth"
+	"github.com/go-chi/jwtauth"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
 )
 
 const (
-	prefixOrder = "/orders"
+	prefixOrder      = "/orders"
+	prefixReturn     = "/orders/returns"
+	provinceShipping = "Test Province"
+	cityShipping     = "Test City"
 )
 
 func TestValidationCreateOrder(t *testing.T) {
@@ -82,6 +103,9 @@ func TestValidationCreateOrder(t *testing.T) {
 				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["fullname"].(string))
 				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["phone"].(string))
 				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["address"].(string))
+				// the destination stay optional for the client that doesn't send it yet
+				assert.NotContains(t, data["detail_message"], "province")
+				assert.NotContains(t, data["detail_message"], "city")
 			case "minimum":
 				assert.Equal(t, "Shorter than minimum length 3.", data["detail_message"].(map[string]interface{})["fullname"].(string))
 				assert.Equal(t, "Shorter than minimum length 5.", data["detail_message"].(map[string]interface{})["address"].(string))
@@ -101,6 +125,78 @@ func TestValidationCreateOrder(t *testing.T) {
 	}
 }
 
+func TestShippingQuote(t *testing.T) {
+	_, s := setupEnvironment()
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]interface{}
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "required",
+			payload:    map[string]interface{}{},
+			expected:   "Missing data for required field.",
+			token:      tokenGuest,
+			statusCode: 422,
+		},
+		{
+			name:       "item not found",
+			payload:    map[string]interface{}{"province": provinceShipping, "city": cityShipping},
+			expected:   "Ups, item in payment not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "not covered",
+			payload:    map[string]interface{}{"province": "Unknown Province", "city": cityShipping},
+			expected:   "Shipping is not available for the destination.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			payload:    map[string]interface{}{"province": provinceShipping, "city": cityShipping},
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixOrder+"/shipping-quote", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "required":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["province"].(string))
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["city"].(string))
+			case "success":
+				assert.Equal(t, "table", data["results"].(map[string]interface{})["provider"])
+				assert.Equal(t, float64(shippingCost), data["results"].(map[string]interface{})["cost"])
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
 func TestCreateOrder(t *testing.T) {
 	repo, s := setupEnvironment()
 
@@ -111,6 +207,10 @@ func TestCreateOrder(t *testing.T) {
 	cart, _ := repo.cartsRepo.GetCartByUserIdAndProductId(context.Background(), user.Id, productId)
 	cart.Qty = 99
 	repo.cartsRepo.Update(context.Background(), cart)
+	repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: user.Id, ListId: []int{cart.Id}}, time.Hour)
+
+	// price changed after the items moved to the payment
+	repo.productsRepo.UpdatePrice(context.Background(), productId, 2)
 
 	tests := [...]struct {
 		name       string
@@ -121,28 +221,42 @@ func TestCreateOrder(t *testing.T) {
 	}{
 		{
 			name:       "user not found",
-			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd"},
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping},
 			expected:   "User not found.",
 			token:      tokenNotFound,
 			statusCode: 401,
 		},
 		{
 			name:       "item not found",
-			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd"},
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping},
 			expected:   "Ups, item in payment not found.",
 			token:      tokenAdmin,
 			statusCode: 404,
 		},
+		{
+			name:       "price changed",
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping},
+			expected:   "The price of some products has changed, please move the items to the payment again.",
+			token:      tokenGuest,
+			statusCode: 409,
+		},
 		{
 			name:       "qty exceed",
-			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd"},
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping},
 			expected:   fmt.Sprintf("Available stock: %d, please reduce quantity product '%s'", 1, namee),
 			token:      tokenGuest,
 			statusCode: 400,
 		},
+		{
+			name:       "voucher not found",
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "voucher_code": "NOTEXISTS"},
+			expected:   "Voucher not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
 		{
 			name:       "success",
-			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd"},
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping},
 			expected:   "Successfully save the order.",
 			token:      tokenGuest,
 			statusCode: 201,
@@ -168,9 +282,23 @@ func TestCreateOrder(t *testing.T) {
 			switch test.name {
 			case "user not found":
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "price changed":
+				results := data["results"].([]interface{})
+				assert.Equal(t, 1, len(results))
+				assert.Equal(t, float64(productId), results[0].(map[string]interface{})["product_id"])
+				assert.Equal(t, float64(1), results[0].(map[string]interface{})["old_price"])
+				assert.Equal(t, float64(2), results[0].(map[string]interface{})["new_price"])
+				repo.productsRepo.UpdatePrice(context.Background(), productId, 1)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			case "qty exceed":
 				cart.Qty = 1
 				repo.cartsRepo.Update(context.Background(), cart)
+				repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: user.Id, ListId: []int{cart.Id}}, time.Hour)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				// stock reserved by the order
+				product, _ := repo.productsRepo.GetProductById(context.Background(), productId)
+				assert.Equal(t, 0, product.Stock)
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			default:
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
@@ -180,6 +308,93 @@ func TestCreateOrder(t *testing.T) {
 	}
 }
 
+func TestCreateOrderIdempotency(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+	redisCli, _ := config.RedisConnect(cfg)
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+
+	// simulate the same key still processed by another request
+	conn := redisCli.Get()
+	conn.Do("SET", fmt.Sprintf("idempotency:%d:%s:%s", admin.Id, prefixOrder, "processing-key"), `{"status_code":0,"fingerprint":""}`, "EX", 60)
+	conn.Close()
+
+	tests := [...]struct {
+		name       string
+		key        string
+		fullname   string
+		expected   string
+		replayed   string
+		statusCode int
+	}{
+		{
+			name:       "first request",
+			key:        "test-key",
+			fullname:   "asdasd",
+			expected:   "Ups, item in payment not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "replay request",
+			key:        "test-key",
+			fullname:   "asdasd",
+			expected:   "Ups, item in payment not found.",
+			replayed:   "true",
+			statusCode: 404,
+		},
+		{
+			name:       "different body",
+			key:        "test-key",
+			fullname:   "other",
+			expected:   "Idempotency-Key has already been used with a different request body.",
+			statusCode: 422,
+		},
+		{
+			name:       "concurrent request",
+			key:        "processing-key",
+			fullname:   "asdasd",
+			expected:   "A request with the same Idempotency-Key is still being processed.",
+			statusCode: 409,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg", "fullname": test.fullname, "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping})
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+			req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+			req.Header.Set("Content-Type", ct)
+			req.Header.Set("Idempotency-Key", test.key)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "concurrent request", "different body":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.replayed, response.Result().Header.Get("Idempotent-Replayed"))
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+
+	conn = redisCli.Get()
+	defer conn.Close()
+	conn.Do("DEL", fmt.Sprintf("idempotency:%d:%s:%s", admin.Id, prefixOrder, "test-key"))
+	conn.Do("DEL", fmt.Sprintf("idempotency:%d:%s:%s", admin.Id, prefixOrder, "processing-key"))
+}
+
 func TestValidationGetOrderAdmin(t *testing.T) {
 	_, s := setupEnvironment()
 
@@ -231,7 +446,7 @@ func TestValidationGetOrderAdmin(t *testing.T) {
 				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["page"].(string))
 				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["per_page"].(string))
 			case "one of":
-				assert.Equal(t, "Must be one of: 'ongoing', 'reject', 'on the way', 'success'.", data["detail_message"].(map[string]interface{})["status"].(string))
+				assert.Equal(t, "Must be one of: 'pending_payment', 'ongoing', 'reupload_requested', 'payment_verified', 'reject', 'on the way', 'success', 'cancelled', 'expired'.", data["detail_message"].(map[string]interface{})["status"].(string))
 			}
 			assert.Equal(t, 422, response.Result().StatusCode)
 		})
@@ -306,7 +521,7 @@ func TestValidationGetOrderGuest(t *testing.T) {
 				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["page"].(string))
 				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["per_page"].(string))
 			case "one of":
-				assert.Equal(t, "Must be one of: 'ongoing', 'reject', 'on the way', 'success'.", data["detail_message"].(map[string]interface{})["status"].(string))
+				assert.Equal(t, "Must be one of: 'pending_payment', 'ongoing', 'reupload_requested', 'payment_verified', 'reject', 'on the way', 'success', 'cancelled', 'expired'.", data["detail_message"].(map[string]interface{})["status"].(string))
 			}
 			assert.Equal(t, 422, response.Result().StatusCode)
 		})
@@ -374,7 +589,7 @@ func TestSetRejectOrder(t *testing.T) {
 		{
 			name:       "status not ongoing",
 			url:        prefixOrder + fmt.Sprintf("/set-reject/%d", order.Id),
-			expected:   "Cannot change status rejected if status other than ongoing.",
+			expected:   "Cannot change status rejected if status other than ongoing, reupload requested or payment verified.",
 			token:      tokenAdmin,
 			statusCode: 400,
 		},
@@ -406,6 +621,11 @@ func TestSetRejectOrder(t *testing.T) {
 					Status: "ongoing",
 				})
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				// stock restored after reject
+				product, _ := repo.productsRepo.GetProductById(context.Background(), productId)
+				assert.Equal(t, 1, product.Stock)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			default:
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			}
@@ -414,61 +634,119 @@ func TestSetRejectOrder(t *testing.T) {
 	}
 }
 
-func TestValidationSetOnTheWayOrder(t *testing.T) {
-	_, s := setupEnvironment()
+func TestRequestReuploadOrder(t *testing.T) {
+	repo, s := setupEnvironment()
 
 	var data map[string]interface{}
 
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+		Id:     order.Id,
+		Status: "success",
+	})
+
 	tests := [...]struct {
-		name     string
-		expected string
-		form     map[string]string
+		name       string
+		url        string
+		payload    map[string]interface{}
+		expected   string
+		token      string
+		statusCode int
 	}{
 		{
-			name:     "required",
-			expected: "Image is required.",
-			form:     map[string]string{},
+			name:       "validation",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", order.Id),
+			payload:    map[string]interface{}{"reason": "a"},
+			expected:   "Shorter than minimum length 3.",
+			token:      tokenAdmin,
+			statusCode: 422,
+		},
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", order.Id),
+			payload:    map[string]interface{}{"reason": "image is blurry"},
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "user not admin",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", order.Id),
+			payload:    map[string]interface{}{"reason": "image is blurry"},
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenGuest,
+			statusCode: 401,
 		},
 		{
-			name:     "danger file extension",
-			expected: "Image must be between jpeg, png.",
-			form:     map[string]string{"no_receipt": "@/app/static/test_image/test.txt"},
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", 999999),
+			payload:    map[string]interface{}{"reason": "image is blurry"},
+			expected:   "Order not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
 		},
 		{
-			name:     "not valid file extension",
-			expected: "Image must be between jpeg, png.",
-			form:     map[string]string{"no_receipt": "@/app/static/test_image/test.gif"},
+			name:       "status not ongoing",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", order.Id),
+			payload:    map[string]interface{}{"reason": "image is blurry"},
+			expected:   "Cannot request a new proof of payment if status other than ongoing.",
+			token:      tokenAdmin,
+			statusCode: 400,
 		},
 		{
-			name:     "file cannot grater than 4 Mb",
-			expected: "An image cannot greater than 4 Mb.",
-			form:     map[string]string{"no_receipt": "@/app/static/test_image/size.png"},
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/request-reupload/%d", order.Id),
+			payload:    map[string]interface{}{"reason": "image is blurry"},
+			expected:   "Successfully request a new proof of payment.",
+			token:      tokenAdmin,
+			statusCode: 200,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			ct, b, err := createForm(test.form)
+			body, err := json.Marshal(test.payload)
 			if err != nil {
 				panic(err)
 			}
 
-			req, _ := http.NewRequest(http.MethodPut, prefixOrder+"/set-on-the-way/999999", b)
-			req.Header.Add("Authorization", "Bearer "+tokenAdmin)
-			req.Header.Set("Content-Type", ct)
+			req, _ := http.NewRequest(http.MethodPut, test.url, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+test.token)
 
 			response := executeRequest(req, s)
 
-			body, _ := io.ReadAll(response.Result().Body)
+			body, _ = io.ReadAll(response.Result().Body)
 			json.Unmarshal(body, &data)
 
-			assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["no_receipt"].(string))
-			assert.Equal(t, 422, response.Result().StatusCode)
+			switch test.name {
+			case "validation":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["reason"].(string))
+			case "user not found", "user not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "status not ongoing":
+				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+					Id:     order.Id,
+					Status: "ongoing",
+				})
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				histories, _ := repo.ordersRepo.GetOrderStatusHistories(context.Background(), order.Id)
+				last := histories[len(histories)-1]
+				assert.Equal(t, ordersentity.StatusReuploadRequested, last.ToStatus)
+				assert.Equal(t, "image is blurry", last.Note.String)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
 		})
 	}
 }
 
-func TestSetOnTheWayOrder(t *testing.T) {
+func TestReuploadProofOrder(t *testing.T) {
 	repo, s := setupEnvironment()
 
 	var data map[string]interface{}
@@ -476,11 +754,6 @@ func TestSetOnTheWayOrder(t *testing.T) {
 	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
 	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
 
-	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
-		Id:     order.Id,
-		Status: "success",
-	})
-
 	tests := [...]struct {
 		name       string
 		url        string
@@ -489,46 +762,54 @@ func TestSetOnTheWayOrder(t *testing.T) {
 		token      string
 		statusCode int
 	}{
+		{
+			name:       "image required",
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", order.Id),
+			payload:    map[string]string{},
+			expected:   "Image is required.",
+			token:      tokenGuest,
+			statusCode: 422,
+		},
 		{
 			name:       "user not found",
-			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
-			payload:    map[string]string{"no_receipt": "@/app/static/test_image/image.jpeg"},
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", order.Id),
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg"},
 			expected:   "User not found.",
 			token:      tokenNotFound,
 			statusCode: 401,
 		},
-		{
-			name:       "user not admin",
-			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
-			payload:    map[string]string{"no_receipt": "@/app/static/test_image/image.jpeg"},
-			expected:   "Only users with admin privileges can do this action.",
-			token:      tokenGuest,
-			statusCode: 401,
-		},
 		{
 			name:       "order not found",
-			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", 999999),
-			payload:    map[string]string{"no_receipt": "@/app/static/test_image/image.jpeg"},
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", 999999),
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg"},
 			expected:   "Order not found.",
-			token:      tokenAdmin,
+			token:      tokenGuest,
 			statusCode: 404,
 		},
 		{
-			name:       "status not ongoing",
-			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
-			payload:    map[string]string{"no_receipt": "@/app/static/test_image/image.jpeg"},
-			expected:   "Cannot change status on the way if status other than ongoing.",
+			name:       "user not same as order",
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", order.Id),
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg"},
+			expected:   "User doesn't have this order.",
 			token:      tokenAdmin,
 			statusCode: 400,
 		},
 		{
 			name:       "success",
-			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
-			payload:    map[string]string{"no_receipt": "@/app/static/test_image/image.jpeg"},
-			expected:   "Successfully set the order to on the way.",
-			token:      tokenAdmin,
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", order.Id),
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg"},
+			expected:   "Successfully reupload the proof of payment.",
+			token:      tokenGuest,
 			statusCode: 200,
 		},
+		{
+			name:       "status not reupload requested",
+			url:        prefixOrder + fmt.Sprintf("/reupload-proof/%d", order.Id),
+			payload:    map[string]string{"proof_of_payment": "@/app/static/test_image/image.jpeg"},
+			expected:   "Cannot reupload the proof of payment if status other than reupload requested.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
 	}
 
 	for _, test := range tests {
@@ -548,13 +829,16 @@ func TestSetOnTheWayOrder(t *testing.T) {
 			json.Unmarshal(body, &data)
 
 			switch test.name {
-			case "user not found", "user not admin":
+			case "image required":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["proof_of_payment"].(string))
+			case "user not found":
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
-			case "status not ongoing":
-				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
-					Id:     order.Id,
-					Status: "ongoing",
-				})
+			case "success":
+				orderDb, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+				assert.Equal(t, ordersentity.StatusOngoing, orderDb.Status)
+				assert.NotEqual(t, order.ProofOfPayment, orderDb.ProofOfPayment)
+				assert.True(t, fileExists("/app/static/proof_payments/"+orderDb.ProofOfPayment))
+				assert.False(t, fileExists("/app/static/proof_payments/"+order.ProofOfPayment))
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			default:
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
@@ -564,19 +848,15 @@ func TestSetOnTheWayOrder(t *testing.T) {
 	}
 }
 
-func TestSetSuccessOrder(t *testing.T) {
+func TestVerifyPaymentOrder(t *testing.T) {
 	repo, s := setupEnvironment()
 
 	var data map[string]interface{}
 
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
 	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
 	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
 
-	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
-		Id:     order.Id,
-		Status: "success",
-	})
-
 	tests := [...]struct {
 		name       string
 		url        string
@@ -586,39 +866,39 @@ func TestSetSuccessOrder(t *testing.T) {
 	}{
 		{
 			name:       "user not found",
-			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
+			url:        prefixOrder + fmt.Sprintf("/verify-payment/%d", order.Id),
 			expected:   "User not found.",
 			token:      tokenNotFound,
 			statusCode: 401,
 		},
+		{
+			name:       "user not admin",
+			url:        prefixOrder + fmt.Sprintf("/verify-payment/%d", order.Id),
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenGuest,
+			statusCode: 401,
+		},
 		{
 			name:       "order not found",
-			url:        prefixOrder + fmt.Sprintf("/set-success/%d", 999999),
+			url:        prefixOrder + fmt.Sprintf("/verify-payment/%d", 999999),
 			expected:   "Order not found.",
-			token:      tokenGuest,
+			token:      tokenAdmin,
 			statusCode: 404,
 		},
 		{
-			name:       "status not on the way",
-			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
-			expected:   "Cannot change status success if status other than on the way.",
-			token:      tokenGuest,
-			statusCode: 400,
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/verify-payment/%d", order.Id),
+			expected:   "Successfully verify the payment.",
+			token:      tokenAdmin,
+			statusCode: 200,
 		},
 		{
-			name:       "user not same as order",
-			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
-			expected:   "User doesn't have this order.",
+			name:       "status not ongoing",
+			url:        prefixOrder + fmt.Sprintf("/verify-payment/%d", order.Id),
+			expected:   "Cannot verify the payment if status other than ongoing.",
 			token:      tokenAdmin,
 			statusCode: 400,
 		},
-		{
-			name:       "success",
-			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
-			expected:   "Successfully set the order to success.",
-			token:      tokenGuest,
-			statusCode: 200,
-		},
 	}
 
 	for _, test := range tests {
@@ -634,11 +914,11 @@ func TestSetSuccessOrder(t *testing.T) {
 			switch test.name {
 			case "user not found", "user not admin":
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
-			case "status not on the way":
-				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
-					Id:     order.Id,
-					Status: "on the way",
-				})
+			case "success":
+				orderDb, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+				assert.Equal(t, ordersentity.StatusPaymentVerified, orderDb.Status)
+				assert.Equal(t, int64(admin.Id), orderDb.VerifiedBy.Int64)
+				assert.True(t, orderDb.VerifiedAt.Valid)
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
 			default:
 				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
@@ -648,10 +928,1767 @@ func TestSetSuccessOrder(t *testing.T) {
 	}
 }
 
-func TestDownOrder(t *testing.T) {
-	repo, _ := setupEnvironment()
+func TestValidationSetOnTheWayOrder(t *testing.T) {
+	_, s := setupEnvironment()
 
-	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	var data map[string]interface{}
+
+	tests := [...]struct {
+		name string
+		form map[string]string
+	}{
+		{
+			name: "required",
+			form: map[string]string{},
+		},
+		{
+			name: "minimum",
+			form: map[string]string{"courier_code": "jne", "tracking_number": "a"},
+		},
+		{
+			name: "maximum",
+			form: map[string]string{"courier_code": "jne", "tracking_number": createMaximum(200)},
+		},
+		{
+			name: "invalid courier",
+			form: map[string]string{"courier_code": "a", "tracking_number": "JNE0123456789"},
+		},
+		{
+			name: "danger file extension",
+			form: map[string]string{"no_receipt": "@/app/static/test_image/test.txt"},
+		},
+		{
+			name: "not valid file extension",
+			form: map[string]string{"no_receipt": "@/app/static/test_image/test.gif"},
+		},
+		{
+			name: "file cannot grater than 4 Mb",
+			form: map[string]string{"no_receipt": "@/app/static/test_image/size.png"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(test.form)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, prefixOrder+"/set-on-the-way/999999", b)
+			req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+			req.Header.Set("Content-Type", ct)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "required":
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["courier_code"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["tracking_number"].(string))
+			case "minimum":
+				assert.Equal(t, "Shorter than minimum length 5.", data["detail_message"].(map[string]interface{})["tracking_number"].(string))
+			case "maximum":
+				assert.Equal(t, "Longer than maximum length 100.", data["detail_message"].(map[string]interface{})["tracking_number"].(string))
+			case "invalid courier":
+				assert.Equal(t, "Must be one of: jne, jnt, sicepat, pos, tiki, anteraja.", data["detail_message"].(map[string]interface{})["courier_code"].(string))
+			case "danger file extension", "not valid file extension":
+				assert.Equal(t, "Image must be between jpeg, png.", data["detail_message"].(map[string]interface{})["no_receipt"].(string))
+			case "file cannot grater than 4 Mb":
+				assert.Equal(t, "An image cannot greater than 4 Mb.", data["detail_message"].(map[string]interface{})["no_receipt"].(string))
+			}
+			assert.Equal(t, 422, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestSetOnTheWayOrder(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+		Id:     order.Id,
+		Status: "success",
+	})
+
+	shipmentForm := map[string]string{
+		"courier_code":    "jne",
+		"tracking_number": "JNE0123456789",
+		"no_receipt":      "@/app/static/test_image/image.jpeg",
+	}
+
+	tests := [...]struct {
+		name       string
+		url        string
+		payload    map[string]string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
+			payload:    shipmentForm,
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "user not admin",
+			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
+			payload:    shipmentForm,
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", 999999),
+			payload:    shipmentForm,
+			expected:   "Order not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "status not payment verified",
+			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
+			payload:    shipmentForm,
+			expected:   "Cannot change status on the way if status other than payment verified.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/set-on-the-way/%d", order.Id),
+			payload:    shipmentForm,
+			expected:   "Successfully set the order to on the way.",
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, test.url, b)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+			req.Header.Set("Content-Type", ct)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found", "user not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "status not payment verified":
+				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+					Id:     order.Id,
+					Status: "payment_verified",
+				})
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				shipment, err := repo.ordersRepo.GetShipmentByOrderId(context.Background(), order.Id)
+				assert.Nil(t, err)
+				assert.Equal(t, "jne", shipment.CourierCode)
+				assert.Equal(t, "JNE0123456789", shipment.TrackingNumber)
+				assert.True(t, shipment.ReceiptPhoto.Valid)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestSetSuccessOrder(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+		Id:     order.Id,
+		Status: "success",
+	})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/set-success/%d", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "status not on the way",
+			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
+			expected:   "Cannot change status success if status other than on the way.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "user not same as order",
+			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
+			expected:   "User doesn't have this order.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/set-success/%d", order.Id),
+			expected:   "Successfully set the order to success.",
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found", "user not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "status not on the way":
+				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+					Id:     order.Id,
+					Status: "on the way",
+				})
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestValidationCancelOrder(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	tests := [...]struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{
+			name:    "minimum",
+			payload: map[string]interface{}{"reason": "a"},
+		},
+		{
+			name:    "maximum",
+			payload: map[string]interface{}{"reason": createMaximum(300)},
+		},
+		{
+			name:    "type data",
+			payload: map[string]interface{}{"reason": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, prefixOrder+"/cancel/999999", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+tokenGuest)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "minimum":
+				assert.Equal(t, "Shorter than minimum length 3.", data["detail_message"].(map[string]interface{})["reason"].(string))
+			case "maximum":
+				assert.Equal(t, "Longer than maximum length 255.", data["detail_message"].(map[string]interface{})["reason"].(string))
+			case "type data":
+				assert.Equal(t, "Invalid input type.", data["detail_message"].(map[string]interface{})["_body"].(string))
+			}
+			assert.Equal(t, 422, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+	product, _ := repo.productsRepo.GetProductById(context.Background(), productId)
+
+	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+		Id:     order.Id,
+		Status: "success",
+	})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/cancel/%d", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/cancel/%d", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "status not ongoing",
+			url:        prefixOrder + fmt.Sprintf("/cancel/%d", order.Id),
+			expected:   "Cannot cancel the order if status other than pending payment, ongoing or reupload requested.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "user not same as order",
+			url:        prefixOrder + fmt.Sprintf("/cancel/%d", order.Id),
+			expected:   "User doesn't have this order.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/cancel/%d", order.Id),
+			expected:   "Successfully cancelled the order.",
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]interface{}{"reason": "changed my mind"})
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, test.url, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "status not ongoing":
+				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+					Id:     order.Id,
+					Status: "ongoing",
+				})
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				// stock restored after cancel
+				productDb, _ := repo.productsRepo.GetProductById(context.Background(), productId)
+				assert.Equal(t, product.Stock+1, productDb.Stock)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCancelOrderReleaseVoucher(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+
+	now := time.Now()
+	voucherId := repo.vouchersRepo.Insert(context.Background(), &vouchersentity.JsonCreateUpdateSchema{
+		Code:              "TESTORDERVOUCHER",
+		Kind:              vouchersentity.KindFixed,
+		Amount:            1,
+		UsageLimitPerUser: 1,
+		StartedAt:         now.Add(-time.Hour),
+		ExpiredAt:         now.Add(time.Hour),
+	})
+	defer repo.vouchersRepo.Delete(context.Background(), voucherId)
+
+	createOrder := func() int {
+		cartId := repo.cartsRepo.Insert(context.Background(), &cartsentity.Cart{Qty: 1, UserId: admin.Id, ProductId: productId2})
+		repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: admin.Id, ListId: []int{cartId}}, time.Hour)
+
+		ct, b, err := createForm(map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "payment_method": "virtual_account", "bank_code": "bca", "voucher_code": "TESTORDERVOUCHER"})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+		req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+		req.Header.Set("Content-Type", ct)
+
+		return executeRequest(req, s).Result().StatusCode
+	}
+
+	cancelOrder := func() int {
+		order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+
+		body, _ := json.Marshal(map[string]interface{}{"reason": "changed my mind"})
+		req, _ := http.NewRequest(http.MethodPut, prefixOrder+fmt.Sprintf("/cancel/%d", order.Id), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		return response.Result().StatusCode
+	}
+
+	assert.Equal(t, 201, createOrder())
+	assert.Equal(t, 200, cancelOrder())
+
+	usage, _ := repo.vouchersRepo.CountVoucherUsage(context.Background(), voucherId, admin.Id)
+	assert.Equal(t, 0, usage.User)
+
+	// the voucher can be applied again once the order is cancelled
+	assert.Equal(t, 201, createOrder())
+	assert.Equal(t, 200, cancelOrder())
+	assert.Equal(t, "Successfully cancelled the order.", data["detail_message"].(map[string]interface{})["_app"].(string))
+}
+
+func TestGetOrderHistory(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/history", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/history", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "success admin",
+			url:        prefixOrder + fmt.Sprintf("/%d/history", order.Id),
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+		{
+			name:       "success buyer",
+			url:        prefixOrder + fmt.Sprintf("/%d/history", order.Id),
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "order not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				histories := data["results"].([]interface{})
+				assert.Equal(t, "ongoing", histories[0].(map[string]interface{})["to_status"].(string))
+				assert.Equal(t, "cancelled", histories[len(histories)-1].(map[string]interface{})["to_status"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetOrderDetail(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	// another buyer, who must not see the order
+	otherId := repo.authRepo.InsertUser(context.Background(), &authentity.JsonRegisterSchema{Email: "testtestingother@test.com", Password: "asdasd"})
+	otherConfirmId := repo.authRepo.InsertUserConfirm(context.Background(), otherId)
+	repo.authRepo.SetUserConfirmActivatedTrue(context.Background(), otherId)
+	defer repo.authRepo.DeleteUser(context.Background(), otherId)
+	defer repo.authRepo.DeleteUserConfirm(context.Background(), otherConfirmId)
+
+	token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(otherId), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	tokenOther := auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/%d", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/%d", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "user not same as order",
+			url:        prefixOrder + fmt.Sprintf("/%d", order.Id),
+			expected:   "User doesn't have this order.",
+			token:      tokenOther,
+			statusCode: 400,
+		},
+		{
+			name:       "success admin",
+			url:        prefixOrder + fmt.Sprintf("/%d", order.Id),
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+		{
+			name:       "success buyer",
+			url:        prefixOrder + fmt.Sprintf("/%d", order.Id),
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "order not found", "user not same as order":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+				assert.Nil(t, data["results"])
+			default:
+				results := data["results"].(map[string]interface{})
+				assert.Equal(t, float64(order.Id), results["id"].(float64))
+				assert.Equal(t, "/static/proof_payments/"+order.ProofOfPayment, results["proof_of_payment_url"].(string))
+				assert.NotEmpty(t, results["order_items"])
+				assert.NotEmpty(t, results["status_histories"])
+				assert.Equal(t, "JNE0123456789", results["shipment"].(map[string]interface{})["tracking_number"].(string))
+				assert.NotEmpty(t, results["no_receipt_url"])
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCreateOrderWithoutDestination(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	cartId := repo.cartsRepo.Insert(context.Background(), &cartsentity.Cart{Qty: 1, UserId: admin.Id, ProductId: productId2})
+	repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: admin.Id, ListId: []int{cartId}}, time.Hour)
+
+	ct, b, err := createForm(map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "payment_method": "virtual_account", "bank_code": "bca"})
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+	req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+	req.Header.Set("Content-Type", ct)
+
+	response := executeRequest(req, s)
+
+	body, _ := io.ReadAll(response.Result().Body)
+	json.Unmarshal(body, &data)
+
+	// charged with the most expensive rate that can carry the items
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+	assert.Equal(t, shippingCost, order.ShippingCost)
+	assert.False(t, order.Province.Valid)
+	assert.Equal(t, "Successfully save the order, please complete the payment.", data["detail_message"].(map[string]interface{})["_app"].(string))
+	assert.Equal(t, 201, response.Result().StatusCode)
+
+	repo.ordersRepo.UpdateStatus(context.Background(), &ordersentity.Order{Id: order.Id, Status: ordersentity.StatusCancelled},
+		&ordersentity.OrderStatusHistory{FromStatus: null.StringFrom(ordersentity.StatusPendingPayment)}, true)
+}
+
+func TestPaymentWebhookCancelledOrder(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	cartId := repo.cartsRepo.Insert(context.Background(), &cartsentity.Cart{Qty: 1, UserId: admin.Id, ProductId: productId2})
+	repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: admin.Id, ListId: []int{cartId}}, time.Hour)
+
+	ct, b, err := createForm(map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "payment_method": "virtual_account", "bank_code": "bca"})
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+	req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+	req.Header.Set("Content-Type", ct)
+	executeRequest(req, s)
+
+	// the buyer cancelled the order before the payment arrived
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+	repo.ordersRepo.UpdateStatus(context.Background(), &ordersentity.Order{Id: order.Id, Status: ordersentity.StatusCancelled},
+		&ordersentity.OrderStatusHistory{FromStatus: null.StringFrom(ordersentity.StatusPendingPayment)}, true)
+	payment, _ := repo.paymentsRepo.GetPaymentByOrderId(context.Background(), order.Id)
+
+	body, err := json.Marshal(map[string]interface{}{"external_id": payment.ExternalId, "status": "paid", "amount": payment.Amount})
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBuffer(body))
+	req.Header.Set(paymentpkg.SignatureHeader, paymentpkg.Sign(cfg.Payment.WebhookSecret, body))
+
+	response := executeRequest(req, s)
+
+	body, _ = io.ReadAll(response.Result().Body)
+	json.Unmarshal(body, &data)
+
+	assert.Equal(t, "Order is no longer waiting for payment, the payment will be refunded.", data["detail_message"].(map[string]interface{})["_app"].(string))
+	assert.Equal(t, 200, response.Result().StatusCode)
+
+	payment, _ = repo.paymentsRepo.GetPaymentByOrderId(context.Background(), order.Id)
+	assert.Equal(t, "refund_required", payment.Status)
+	assert.True(t, payment.PaidAt.Valid)
+
+	order, _ = repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+	assert.Equal(t, ordersentity.StatusCancelled, order.Status)
+}
+
+func TestCreateOrderVirtualAccount(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	cartId := repo.cartsRepo.Insert(context.Background(), &cartsentity.Cart{Qty: 1, UserId: admin.Id, ProductId: productId2})
+	repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: admin.Id, ListId: []int{cartId}}, time.Hour)
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "bank code required",
+			payload:    map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "payment_method": "virtual_account"},
+			expected:   "Missing data for required field.",
+			statusCode: 422,
+		},
+		{
+			name:       "success",
+			payload:    map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "payment_method": "virtual_account", "bank_code": "bca"},
+			expected:   "Successfully save the order, please complete the payment.",
+			statusCode: 201,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+			req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+			req.Header.Set("Content-Type", ct)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "bank code required":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["bank_code"].(string))
+			case "success":
+				results := data["results"].(map[string]interface{})
+				assert.Equal(t, "virtual_account", results["method"].(string))
+				assert.Equal(t, "pending", results["status"].(string))
+				assert.NotEmpty(t, results["va_number"])
+				assert.Equal(t, float64(1+shippingCost), results["amount"].(float64))
+
+				order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+				assert.Equal(t, ordersentity.StatusPendingPayment, order.Status)
+				assert.Empty(t, order.ProofOfPayment)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	repo, s := setupEnvironment()
+	cfg, _ := config.New()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+	payment, _ := repo.paymentsRepo.GetPaymentByOrderId(context.Background(), order.Id)
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]interface{}
+		secret     string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "invalid signature",
+			payload:    map[string]interface{}{"external_id": payment.ExternalId, "status": "paid", "amount": payment.Amount},
+			secret:     "invalid",
+			expected:   "Invalid signature.",
+			statusCode: 401,
+		},
+		{
+			name:       "validation",
+			payload:    map[string]interface{}{"external_id": payment.ExternalId, "status": "asd", "amount": payment.Amount},
+			secret:     cfg.Payment.WebhookSecret,
+			expected:   "Must be one of: paid, expired, failed.",
+			statusCode: 422,
+		},
+		{
+			name:       "payment not found",
+			payload:    map[string]interface{}{"external_id": "asd", "status": "paid", "amount": payment.Amount},
+			secret:     cfg.Payment.WebhookSecret,
+			expected:   "Payment not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "amount not match",
+			payload:    map[string]interface{}{"external_id": payment.ExternalId, "status": "paid", "amount": 1},
+			secret:     cfg.Payment.WebhookSecret,
+			expected:   "The amount doesn't match the payment.",
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			payload:    map[string]interface{}{"external_id": payment.ExternalId, "status": "paid", "amount": payment.Amount},
+			secret:     cfg.Payment.WebhookSecret,
+			expected:   "Successfully process the payment.",
+			statusCode: 200,
+		},
+		{
+			name:       "already processed",
+			payload:    map[string]interface{}{"external_id": payment.ExternalId, "status": "expired", "amount": payment.Amount},
+			secret:     cfg.Payment.WebhookSecret,
+			expected:   "Payment already processed.",
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBuffer(body))
+			req.Header.Set(paymentpkg.SignatureHeader, paymentpkg.Sign(test.secret, body))
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "invalid signature":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "validation":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["status"].(string))
+			case "success":
+				order, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+				assert.Equal(t, ordersentity.StatusPaymentVerified, order.Status)
+				assert.True(t, order.VerifiedAt.Valid)
+				assert.False(t, order.VerifiedBy.Valid)
+				payment, _ := repo.paymentsRepo.GetPaymentByOrderId(context.Background(), order.Id)
+				assert.Equal(t, "paid", payment.Status)
+				assert.True(t, payment.PaidAt.Valid)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetOrderTracking(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	orderNotShipped, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/tracking", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/tracking", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "user doesn't have order",
+			url:        prefixOrder + fmt.Sprintf("/%d/tracking", orderNotShipped.Id),
+			expected:   "User doesn't have this order.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "shipment not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/tracking", orderNotShipped.Id),
+			expected:   "Shipment not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/%d/tracking", order.Id),
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "success":
+				results := data["results"].(map[string]interface{})
+				assert.Equal(t, "JNE0123456789", results["tracking_number"].(string))
+				assert.False(t, results["delivered"].(bool))
+				assert.Len(t, results["events"], 1)
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestExpireOrders(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	cartId := repo.cartsRepo.Insert(context.Background(), &cartsentity.Cart{Qty: 1, UserId: admin.Id, ProductId: productId2})
+	repo.cartsRepo.MoveItemToPayment(context.Background(), &cartsentity.JsonMultipleSchema{UserId: admin.Id, ListId: []int{cartId}}, time.Hour)
+
+	ct, b, err := createForm(map[string]string{"fullname": "asdasd", "phone": "08786226533", "address": "asdasd", "province": provinceShipping, "city": cityShipping, "payment_method": "qris"})
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, prefixOrder, b)
+	req.Header.Add("Authorization", "Bearer "+tokenAdmin)
+	req.Header.Set("Content-Type", ct)
+
+	response := executeRequest(req, s)
+
+	body, _ := io.ReadAll(response.Result().Body)
+	json.Unmarshal(body, &data)
+	assert.Equal(t, 201, response.Result().StatusCode)
+
+	orderId := int(data["results"].(map[string]interface{})["order_id"].(float64))
+	product, _ := repo.productsRepo.GetProductById(context.Background(), productId2)
+	assert.Equal(t, 0, product.Stock)
+
+	uc := ordersusecase.NewOrdersUsecase(&repo.ordersRepo, &repo.authRepo, &repo.cartsRepo, &repo.vouchersRepo,
+		&repo.shippingRepo, &repo.paymentsRepo, paymentpkg.NewFake(time.Hour), courier.NewStub())
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := uc.ExpireOrders(context.Background(), ordersentity.StatusSuccess, 0, 100)
+		assert.EqualError(t, err, "Cannot expire the order if status other than pending payment, ongoing or reupload requested.")
+	})
+
+	t.Run("before deadline", func(t *testing.T) {
+		_, err := uc.ExpireOrders(context.Background(), ordersentity.StatusPendingPayment, time.Hour, 100)
+		assert.NoError(t, err)
+
+		order, _ := repo.ordersRepo.GetOrderById(context.Background(), orderId)
+		assert.Equal(t, ordersentity.StatusPendingPayment, order.Status)
+	})
+
+	t.Run("after deadline", func(t *testing.T) {
+		expired, err := uc.ExpireOrders(context.Background(), ordersentity.StatusPendingPayment, 0, 100)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, expired, 1)
+
+		order, _ := repo.ordersRepo.GetOrderById(context.Background(), orderId)
+		assert.Equal(t, ordersentity.StatusExpired, order.Status)
+		payment, _ := repo.paymentsRepo.GetPaymentByOrderId(context.Background(), orderId)
+		assert.Equal(t, "expired", payment.Status)
+		// stock given back
+		product, _ := repo.productsRepo.GetProductById(context.Background(), productId2)
+		assert.Equal(t, 1, product.Stock)
+
+		histories, _ := repo.ordersRepo.GetOrderStatusHistories(context.Background(), orderId)
+		last := histories[len(histories)-1]
+		assert.Equal(t, ordersentity.StatusPendingPayment, last.FromStatus.String)
+		assert.Equal(t, ordersentity.StatusExpired, last.ToStatus)
+		assert.False(t, last.ActorId.Valid)
+	})
+
+	t.Run("paid after expired", func(t *testing.T) {
+		cfg, _ := config.New()
+		payment, _ := repo.paymentsRepo.GetPaymentByOrderId(context.Background(), orderId)
+
+		body, err := json.Marshal(map[string]interface{}{"external_id": payment.ExternalId, "status": "paid", "amount": payment.Amount})
+		if err != nil {
+			panic(err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBuffer(body))
+		req.Header.Set(paymentpkg.SignatureHeader, paymentpkg.Sign(cfg.Payment.WebhookSecret, body))
+
+		response := executeRequest(req, s)
+
+		body, _ = io.ReadAll(response.Result().Body)
+		json.Unmarshal(body, &data)
+
+		assert.Equal(t, "Order is no longer waiting for payment, the payment will be refunded.", data["detail_message"].(map[string]interface{})["_app"].(string))
+		assert.Equal(t, 200, response.Result().StatusCode)
+
+		payment, _ = repo.paymentsRepo.GetPaymentByOrderId(context.Background(), orderId)
+		assert.Equal(t, "refund_required", payment.Status)
+		assert.True(t, payment.PaidAt.Valid)
+
+		order, _ := repo.ordersRepo.GetOrderById(context.Background(), orderId)
+		assert.Equal(t, ordersentity.StatusExpired, order.Status)
+	})
+}
+
+func TestCompleteOrders(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{
+		Id:     order.Id,
+		Status: "on the way",
+	})
+
+	uc := ordersusecase.NewOrdersUsecase(&repo.ordersRepo, &repo.authRepo, &repo.cartsRepo, &repo.vouchersRepo,
+		&repo.shippingRepo, &repo.paymentsRepo, paymentpkg.NewFake(time.Hour), courier.NewStub())
+
+	t.Run("before deadline", func(t *testing.T) {
+		_, err := uc.CompleteOrders(context.Background(), time.Hour, 100)
+		assert.NoError(t, err)
+
+		order, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+		assert.Equal(t, ordersentity.StatusOnTheWay, order.Status)
+	})
+
+	t.Run("after deadline", func(t *testing.T) {
+		completed, err := uc.CompleteOrders(context.Background(), 0, 100)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, completed, 1)
+
+		order, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+		assert.Equal(t, ordersentity.StatusSuccess, order.Status)
+		assert.True(t, order.AutoCompleted)
+
+		histories, _ := repo.ordersRepo.GetOrderStatusHistories(context.Background(), order.Id)
+		last := histories[len(histories)-1]
+		assert.Equal(t, ordersentity.StatusOnTheWay, last.FromStatus.String)
+		assert.Equal(t, ordersentity.StatusSuccess, last.ToStatus)
+		assert.False(t, last.ActorId.Valid)
+		assert.Equal(t, "Completed automatically after 0s on the way.", last.Note.String)
+	})
+}
+
+func TestValidationCreateReturn(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	tests := [...]struct {
+		name    string
+		payload map[string]string
+	}{
+		{
+			name:    "required file",
+			payload: map[string]string{},
+		},
+		{
+			name:    "required form",
+			payload: map[string]string{"photo": "@/app/static/test_image/image.jpeg"},
+		},
+		{
+			name:    "minimum",
+			payload: map[string]string{"photo": "@/app/static/test_image/image.jpeg", "order_id": "0", "reason": "a", "items.0.order_item_id": "0", "items.0.qty": "0"},
+		},
+		{
+			name:    "maximum",
+			payload: map[string]string{"photo": "@/app/static/test_image/image.jpeg", "reason": createMaximum(300)},
+		},
+		{
+			name:    "unique item",
+			payload: map[string]string{"photo": "@/app/static/test_image/image.jpeg", "items.0.order_item_id": "1", "items.0.qty": "1", "items.1.order_item_id": "1", "items.1.qty": "1"},
+		},
+		{
+			name:    "danger file extension",
+			payload: map[string]string{"photo": "@/app/static/test_image/test.txt"},
+		},
+		{
+			name:    "not valid file extension",
+			payload: map[string]string{"photo": "@/app/static/test_image/test.gif"},
+		},
+		{
+			name:    "file cannot grater than 4 Mb",
+			payload: map[string]string{"photo": "@/app/static/test_image/size.png"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixReturn, b)
+			req.Header.Add("Authorization", "Bearer "+tokenGuest)
+			req.Header.Set("Content-Type", ct)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "required file":
+				assert.Equal(t, "Image is required.", data["detail_message"].(map[string]interface{})["photo"].(string))
+			case "required form":
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["order_id"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["reason"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["items"].(string))
+			case "minimum":
+				item := data["detail_message"].(map[string]interface{})["items"].(map[string]interface{})["0"].(map[string]interface{})
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["order_id"].(string))
+				assert.Equal(t, "Shorter than minimum length 5.", data["detail_message"].(map[string]interface{})["reason"].(string))
+				assert.Equal(t, "Missing data for required field.", item["order_item_id"].(string))
+				assert.Equal(t, "Missing data for required field.", item["qty"].(string))
+			case "maximum":
+				assert.Equal(t, "Longer than maximum length 255.", data["detail_message"].(map[string]interface{})["reason"].(string))
+			case "unique item":
+				assert.Equal(t, "Must be unique.", data["detail_message"].(map[string]interface{})["items"].(string))
+			case "danger file extension", "not valid file extension":
+				assert.Equal(t, "Image must be between jpeg, png.", data["detail_message"].(map[string]interface{})["photo"].(string))
+			case "file cannot grater than 4 Mb":
+				assert.Equal(t, "An image cannot greater than 4 Mb.", data["detail_message"].(map[string]interface{})["photo"].(string))
+			}
+			assert.Equal(t, 422, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCreateReturn(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+	orderItems, _ := repo.ordersRepo.GetAllOrderItems(context.Background(), order.Id)
+	orderItem := orderItems[0]
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	orderNotSuccess, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+
+	form := func(orderId, orderItemId, qty int) map[string]string {
+		return map[string]string{
+			"photo":                 "@/app/static/test_image/image.jpeg",
+			"order_id":              fmt.Sprintf("%d", orderId),
+			"reason":                "The package arrived broken.",
+			"items.0.order_item_id": fmt.Sprintf("%d", orderItemId),
+			"items.0.qty":           fmt.Sprintf("%d", qty),
+		}
+	}
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			payload:    form(order.Id, orderItem.OrderItemsId, 1),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			payload:    form(999999, orderItem.OrderItemsId, 1),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "user doesn't have order",
+			payload:    form(order.Id, orderItem.OrderItemsId, 1),
+			expected:   "User doesn't have this order.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "status not success",
+			payload:    form(orderNotSuccess.Id, orderItem.OrderItemsId, 1),
+			expected:   "Cannot return the order if status other than success.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "order item not found",
+			payload:    form(order.Id, 999999, 1),
+			expected:   "Order item 999999 not found in the order.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "exceed qty",
+			payload:    form(order.Id, orderItem.OrderItemsId, orderItem.OrderItemsQty+1),
+			expected:   fmt.Sprintf("Available qty to return: %d, please reduce qty of order item %d.", orderItem.OrderItemsQty, orderItem.OrderItemsId),
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			payload:    form(order.Id, orderItem.OrderItemsId, 1),
+			expected:   "Successfully open the return request.",
+			token:      tokenGuest,
+			statusCode: 201,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct, b, err := createForm(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixReturn, b)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+			req.Header.Set("Content-Type", ct)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestApproveReturn(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	returns, _ := repo.returnsRepo.GetAllReturnPaginate(context.Background(),
+		&returnsentity.QueryParamAllReturnSchema{UserId: user.Id, Page: 1, PerPage: 1}, false)
+	returnId := returns.Data[0].Id
+
+	items, _ := repo.returnsRepo.GetReturnItems(context.Background(), returnId)
+	product, _ := repo.productsRepo.GetProductById(context.Background(), items[0].ProductId)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixReturn + fmt.Sprintf("/approve/%d", returnId),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "user not admin",
+			url:        prefixReturn + fmt.Sprintf("/approve/%d", returnId),
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "return not found",
+			url:        prefixReturn + fmt.Sprintf("/approve/%d", 999999),
+			expected:   "Return not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixReturn + fmt.Sprintf("/approve/%d", returnId),
+			expected:   "Successfully approve the return.",
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+		{
+			name:       "status not pending",
+			url:        prefixReturn + fmt.Sprintf("/approve/%d", returnId),
+			expected:   "Cannot approve the return if status other than pending.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found", "user not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "success":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+				// stock given back and the refund is waiting to be sent
+				restocked, _ := repo.productsRepo.GetProductById(context.Background(), items[0].ProductId)
+				assert.Equal(t, product.Stock+items[0].Qty, restocked.Stock)
+				refund, err := repo.returnsRepo.GetRefundByReturnId(context.Background(), returnId)
+				assert.Nil(t, err)
+				assert.Equal(t, returnsentity.RefundStatusPending, refund.Status)
+				assert.Greater(t, refund.Amount, 0)
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestRejectReturn(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+	orderItems, _ := repo.ordersRepo.GetAllOrderItems(context.Background(), order.Id)
+	orderItem := orderItems[0]
+
+	returnId, _ := repo.returnsRepo.Create(context.Background(), &returnsentity.FormCreateSchema{
+		OrderId: order.Id,
+		Reason:  "The package arrived broken.",
+		Photo:   "default.jpg",
+		UserId:  user.Id,
+	}, []returnsentity.ReturnItem{{
+		Qty:         1,
+		Price:       orderItem.OrderItemsPrice,
+		OrderItemId: orderItem.OrderItemsId,
+		ProductId:   orderItem.OrderItemsProductId,
+	}}, map[int]int{orderItem.OrderItemsId: 2})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		payload    map[string]string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "validation",
+			url:        prefixReturn + fmt.Sprintf("/reject/%d", returnId),
+			payload:    map[string]string{"reason": "a"},
+			expected:   "Shorter than minimum length 3.",
+			token:      tokenAdmin,
+			statusCode: 422,
+		},
+		{
+			name:       "user not admin",
+			url:        prefixReturn + fmt.Sprintf("/reject/%d", returnId),
+			payload:    map[string]string{},
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "return not found",
+			url:        prefixReturn + fmt.Sprintf("/reject/%d", 999999),
+			payload:    map[string]string{},
+			expected:   "Return not found.",
+			token:      tokenAdmin,
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixReturn + fmt.Sprintf("/reject/%d", returnId),
+			payload:    map[string]string{"reason": "The item is not broken."},
+			expected:   "Successfully reject the return.",
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+		{
+			name:       "status not pending",
+			url:        prefixReturn + fmt.Sprintf("/reject/%d", returnId),
+			payload:    map[string]string{},
+			expected:   "Cannot reject the return if status other than pending.",
+			token:      tokenAdmin,
+			statusCode: 400,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, test.url, bytes.NewBuffer(body))
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "validation":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["reason"].(string))
+			case "user not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "success":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+				processed, _ := repo.returnsRepo.GetReturnById(context.Background(), returnId)
+				assert.Equal(t, returnsentity.StatusRejected, processed.Status)
+				assert.Equal(t, "The item is not broken.", processed.RejectReason.String)
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetReturnDetail(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	returns, _ := repo.returnsRepo.GetAllReturnPaginate(context.Background(),
+		&returnsentity.QueryParamAllReturnSchema{UserId: user.Id, Page: 1, PerPage: 10, Status: returnsentity.StatusApproved}, false)
+	returnId := returns.Data[0].Id
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixReturn + fmt.Sprintf("/%d", returnId),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "return not found",
+			url:        prefixReturn + fmt.Sprintf("/%d", 999999),
+			expected:   "Return not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "success admin",
+			url:        prefixReturn + fmt.Sprintf("/%d", returnId),
+			token:      tokenAdmin,
+			statusCode: 200,
+		},
+		{
+			name:       "success buyer",
+			url:        prefixReturn + fmt.Sprintf("/%d", returnId),
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "return not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			default:
+				results := data["results"].(map[string]interface{})
+				assert.Equal(t, float64(returnId), results["id"].(float64))
+				assert.NotEmpty(t, results["items"])
+				assert.NotNil(t, results["refund"])
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetOrderInvoice(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	admin, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+	orderAdmin, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), admin.Id)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/invoice.pdf", order.Id),
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "order not found",
+			url:        prefixOrder + fmt.Sprintf("/%d/invoice.pdf", 999999),
+			expected:   "Order not found.",
+			token:      tokenGuest,
+			statusCode: 404,
+		},
+		{
+			name:       "user doesn't have order",
+			url:        prefixOrder + fmt.Sprintf("/%d/invoice.pdf", orderAdmin.Id),
+			expected:   "User doesn't have this order.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "order not paid",
+			url:        prefixOrder + fmt.Sprintf("/%d/invoice.pdf", order.Id),
+			expected:   "The invoice is only available once the payment is verified.",
+			token:      tokenGuest,
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixOrder + fmt.Sprintf("/%d/invoice.pdf", order.Id),
+			token:      tokenGuest,
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.name == "order not paid" {
+				repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{Id: order.Id, Status: ordersentity.StatusCancelled})
+				defer repo.ordersRepo.UpdateOrder(context.Background(), &ordersentity.Order{Id: order.Id, Status: order.Status})
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			switch test.name {
+			case "user not found":
+				json.Unmarshal(body, &data)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			case "success":
+				assert.Equal(t, "application/pdf", response.Result().Header.Get("Content-Type"))
+				assert.True(t, bytes.HasPrefix(body, []byte("%PDF")))
+
+				invoiced, _ := repo.ordersRepo.GetOrderById(context.Background(), order.Id)
+				assert.Regexp(t, `^INV/\d{4}/\d{2}/\d{6}$`, invoiced.InvoiceNumber.String)
+				// counted in the month the payment was verified
+				assert.True(t, strings.HasPrefix(invoiced.InvoiceNumber.String, "INV/"+ordersentity.InvoicePeriod(invoiced.InvoiceDate())+"/"))
+				assert.Contains(t, response.Result().Header.Get("Content-Disposition"),
+					strings.ReplaceAll(invoiced.InvoiceNumber.String, "/", "-")+".pdf")
+			default:
+				json.Unmarshal(body, &data)
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestDeliverEmails(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	smtp := newSMTPStandIn()
+	defer smtp.Close()
+
+	// no backoff, so the failed email is due again right away
+	uc := emailsusecase.NewEmailsUsecase(&repo.emailsRepo, &repo.ordersRepo, &repo.authRepo,
+		mailer.New("127.0.0.1", smtp.Port(), "", "", "dont-reply@example.com"), 3, 0)
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email_2)
+	order, _ := repo.ordersRepo.GetOrderByUserIdLimit(context.Background(), user.Id)
+
+	t.Run("every change of the order is in the outbox", func(t *testing.T) {
+		emails, _ := repo.emailsRepo.GetEmailsByOrderId(context.Background(), order.Id)
+		assert.Equal(t, emailsentity.EventOrderCreated, emails[0].Event)
+		assert.Equal(t, emailsentity.EventOrderStatusChanged, emails[len(emails)-1].Event)
+		assert.Equal(t, ordersentity.StatusSuccess, emails[len(emails)-1].OrderStatus)
+		for _, email := range emails {
+			assert.Equal(t, emailsentity.StatusPending, email.Status)
+		}
+	})
+
+	t.Run("smtp failure is retried", func(t *testing.T) {
+		smtp.SetReject(true)
+
+		_, err := uc.DeliverEmails(context.Background(), 1000)
+		assert.NoError(t, err)
+		assert.Len(t, smtp.Messages(), 0)
+
+		emails, _ := repo.emailsRepo.GetEmailsByOrderId(context.Background(), order.Id)
+		for _, email := range emails {
+			assert.Equal(t, emailsentity.StatusPending, email.Status)
+			assert.Equal(t, 1, email.Attempts)
+			assert.True(t, email.LastError.Valid)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		smtp.SetReject(false)
+
+		sent, err := uc.DeliverEmails(context.Background(), 1000)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, sent, 1)
+
+		emails, _ := repo.emailsRepo.GetEmailsByOrderId(context.Background(), order.Id)
+		for _, email := range emails {
+			assert.Equal(t, emailsentity.StatusSent, email.Status)
+			assert.Equal(t, 2, email.Attempts)
+			assert.True(t, email.SentAt.Valid)
+		}
+
+		var completed *smtpMessage
+		messages := smtp.Messages()
+		for i := range messages {
+			if messages[i].Subject() == fmt.Sprintf("Order #%d Completed", order.Id) {
+				completed = &messages[i]
+			}
+		}
+		if assert.NotNil(t, completed) {
+			assert.Contains(t, completed.To[0], email_2)
+			assert.Contains(t, completed.Body(), order.InvoiceNumber.String)
+		}
+	})
+
+	t.Run("sent email is not delivered twice", func(t *testing.T) {
+		total := len(smtp.Messages())
+
+		uc.DeliverEmails(context.Background(), 1000)
+
+		var again int
+		for _, msg := range smtp.Messages()[total:] {
+			if strings.Contains(msg.Subject(), fmt.Sprintf("Order #%d ", order.Id)) {
+				again++
+			}
+		}
+		assert.Equal(t, 0, again)
+	})
+}
+
+func TestGetAllOrderQueryCount(t *testing.T) {
+	s := setupCountingServer()
+
+	loadPage := func(url, token string, perPage int) int64 {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?page=1&per_page=%d", url, perPage), nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+
+		counter.Reset()
+		response := executeRequest(req, s)
+		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
+
+		return counter.Total()
+	}
+
+	tests := [...]struct {
+		name  string
+		url   string
+		token string
+	}{
+		{name: "all order", url: prefixOrder + "/", token: tokenAdmin},
+		{name: "my order", url: prefixOrder + "/mine", token: tokenAdmin},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the number of query must not grow with the page size
+			small, large := loadPage(test.url, test.token, 1), loadPage(test.url, test.token, 100)
+			assert.Equal(t, small, large, "query count grows with page size")
+		})
+	}
+}
+
+func TestDownOrder(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
 	userConfirm, _ := repo.authRepo.GetUserConfirmByUserId(context.Background(), user.Id)
 
 	repo.authRepo.DeleteUserConfirm(context.Background(), userConfirm.Id)
@@ -673,4 +2710,6 @@ func TestDownOrder(t *testing.T) {
 
 	repo.productsRepo.Delete(context.Background(), productId)
 	repo.productsRepo.Delete(context.Background(), productId2)
+
+	repo.shippingRepo.Delete(context.Background(), shippingRateId)
 }
@@ -2,20 +2,30 @@ package tests
 
 import (
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
 	handler_http "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/endpoint/http/handler"
 	authrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/auth"
 	cartsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/carts"
 	categoriesrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/categories"
+	emailsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/emails"
 	ordersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/orders"
+	paymentsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/payments"
 	productsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/products"
+	returnsrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/returns"
+	shippingrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/shipping"
+	vouchersrepo "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/repo/vouchers"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type setupRepo struct {
@@ -23,7 +33,12 @@ type setupRepo struct {
 	categoriesRepo categoriesrepo.RepoCategories
 	productsRepo   productsrepo.RepoProducts
 	cartsRepo      cartsrepo.RepoCarts
+	emailsRepo     emailsrepo.RepoEmails
 	ordersRepo     ordersrepo.RepoOrders
+	vouchersRepo   vouchersrepo.RepoVouchers
+	shippingRepo   shippingrepo.RepoShipping
+	paymentsRepo   paymentsrepo.RepoPayments
+	returnsRepo    returnsrepo.RepoReturns
 }
 
 func setupEnvironment() (*setupRepo, *handler_http.Server) {
@@ -52,14 +67,24 @@ func setupEnvironment() (*setupRepo, *handler_http.Server) {
 	categoriesRepo, _ := categoriesrepo.New(db)
 	productsRepo, _ := productsrepo.New(db)
 	cartsRepo, _ := cartsrepo.New(db)
+	emailsRepo, _ := emailsrepo.New(db)
 	ordersRepo, _ := ordersrepo.New(db)
+	vouchersRepo, _ := vouchersrepo.New(db)
+	shippingRepo, _ := shippingrepo.New(db)
+	paymentsRepo, _ := paymentsrepo.New(db)
+	returnsRepo, _ := returnsrepo.New(db)
 
 	setuprepo := setupRepo{
 		authRepo:       *authRepo,
 		categoriesRepo: *categoriesRepo,
 		productsRepo:   *productsRepo,
 		cartsRepo:      *cartsRepo,
+		emailsRepo:     *emailsRepo,
 		ordersRepo:     *ordersRepo,
+		vouchersRepo:   *vouchersRepo,
+		shippingRepo:   *shippingRepo,
+		paymentsRepo:   *paymentsRepo,
+		returnsRepo:    *returnsRepo,
 	}
 
 	return &setuprepo, r
@@ -122,3 +147,88 @@ func fileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// queryCounter is a postgres driver that counts every statement executed,
+// useful to make sure a handler doesn't run a query per row.
+type queryCounter struct {
+	pq.Driver
+	total int64
+}
+
+type countingConn struct {
+	driver.Conn
+	counter *queryCounter
+}
+
+type countingStmt struct {
+	driver.Stmt
+	counter *queryCounter
+}
+
+var counter = &queryCounter{}
+
+func init() {
+	sql.Register("postgres-counter", counter)
+}
+
+func (c *queryCounter) Open(name string) (driver.Conn, error) {
+	conn, err := c.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, counter: c}, nil
+}
+
+func (c *queryCounter) Reset() { atomic.StoreInt64(&c.total, 0) }
+
+func (c *queryCounter) Total() int64 { return atomic.LoadInt64(&c.total) }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &countingStmt{Stmt: stmt, counter: c.counter}, nil
+}
+
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	atomic.AddInt64(&s.counter.total, 1)
+	return s.Stmt.Exec(args)
+}
+
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(&s.counter.total, 1)
+	return s.Stmt.Query(args)
+}
+
+// setupCountingDB connect to the db through queryCounter
+func setupCountingDB() *sqlx.DB {
+	cfg, err := config.New()
+	if err != nil {
+		panic(err)
+	}
+	db, err := sql.Open("postgres-counter", cfg.Database.FollowerDsn)
+	if err != nil {
+		panic(err)
+	}
+
+	return sqlx.NewDb(db, "postgres")
+}
+
+// setupCountingServer mount the handlers on top of setupCountingDB
+func setupCountingServer() *handler_http.Server {
+	cfg, err := config.New()
+	if err != nil {
+		panic(err)
+	}
+	redisCli, err := config.RedisConnect(cfg)
+	if err != nil {
+		panic(err)
+	}
+	r := handler_http.CreateNewServer(setupCountingDB(), redisCli, cfg)
+	if err := r.MountHandlers(); err != nil {
+		panic(err)
+	}
+
+	return r
+}
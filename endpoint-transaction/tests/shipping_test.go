@@ -0,0 +1,380 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	shippingentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/shipping"
+	"github.com/creent-production/cdk-go/auth"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	prefixShipping    = "/shipping-rates"
+	emailShipping     = "testtestingshipping@test.com"
+	emailShipping2    = "testtestingshipping2@test.com"
+	provinceShipping2 = "Test Shipping Province"
+	cityShipping2     = "Test Shipping City"
+)
+
+var (
+	tokenShippingAdmin = ""
+	tokenShippingGuest = ""
+)
+
+func TestUpShipping(t *testing.T) {
+	repo, _ := setupEnvironment()
+	cfg, _ := config.New()
+	// create admin
+	user_id := repo.authRepo.InsertUser(context.Background(), &authentity.JsonRegisterSchema{Email: emailShipping, Password: "asdasd"})
+	repo.authRepo.InsertUserConfirm(context.Background(), user_id)
+	repo.authRepo.SetUserConfirmActivatedTrue(context.Background(), user_id)
+	repo.authRepo.SetUserRoleAdmin(context.Background(), emailShipping)
+
+	token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user_id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	tokenShippingAdmin = auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+
+	// create guest
+	user_id = repo.authRepo.InsertUser(context.Background(), &authentity.JsonRegisterSchema{Email: emailShipping2, Password: "asdasd"})
+	repo.authRepo.InsertUserConfirm(context.Background(), user_id)
+	repo.authRepo.SetUserConfirmActivatedTrue(context.Background(), user_id)
+
+	token = auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user_id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	tokenShippingGuest = auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+}
+
+func TestValidationCreateShipping(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	tests := [...]struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{
+			name:    "required",
+			payload: map[string]interface{}{},
+		},
+		{
+			name:    "minimum",
+			payload: map[string]interface{}{"province": "a", "city": "a", "max_weight": -1, "cost": -1},
+		},
+		{
+			name:    "maximum",
+			payload: map[string]interface{}{"province": createMaximum(200), "city": createMaximum(200), "max_weight": 1, "cost": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixShipping+"/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+tokenShippingAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "required":
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["province"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["max_weight"].(string))
+			case "minimum":
+				assert.Equal(t, "Shorter than minimum length 3.", data["detail_message"].(map[string]interface{})["province"].(string))
+				assert.Equal(t, "Shorter than minimum length 3.", data["detail_message"].(map[string]interface{})["city"].(string))
+				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["max_weight"].(string))
+				assert.Equal(t, "Must be greater than or equal to 0.", data["detail_message"].(map[string]interface{})["cost"].(string))
+			case "maximum":
+				assert.Equal(t, "Longer than maximum length 100.", data["detail_message"].(map[string]interface{})["province"].(string))
+				assert.Equal(t, "Longer than maximum length 100.", data["detail_message"].(map[string]interface{})["city"].(string))
+			}
+			assert.Equal(t, 422, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCreateShipping(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]interface{}
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 1000, "cost": 5000},
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "not admin",
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 1000, "cost": 5000},
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenShippingGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "success",
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 1000, "cost": 5000},
+			expected:   "Successfully add a new shipping rate.",
+			token:      tokenShippingAdmin,
+			statusCode: 201,
+		},
+		{
+			name:       "duplicate tier",
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 1000, "cost": 7000},
+			expected:   "The max_weight has already been taken.",
+			token:      tokenShippingAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "success city",
+			payload:    map[string]interface{}{"province": provinceShipping2, "city": cityShipping2, "max_weight": 1000, "cost": 3000},
+			expected:   "Successfully add a new shipping rate.",
+			token:      tokenShippingAdmin,
+			statusCode: 201,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixShipping+"/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found", "not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetAllShipping(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	req, _ := http.NewRequest(http.MethodGet, prefixShipping+"/?page=1&per_page=10&q=test+shipping", nil)
+	req.Header.Add("Authorization", "Bearer "+tokenShippingAdmin)
+
+	response := executeRequest(req, s)
+
+	body, _ := io.ReadAll(response.Result().Body)
+	json.Unmarshal(body, &data)
+
+	assert.Equal(t, float64(2), data["results"].(map[string]interface{})["total"])
+	assert.Equal(t, 200, response.Result().StatusCode)
+}
+
+func TestGetShippingById(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	rate, _ := repo.shippingRepo.GetRateByTier(context.Background(),
+		&shippingentity.JsonCreateUpdateRateSchema{Province: provinceShipping2, MaxWeight: 1000})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "rate not found",
+			url:        prefixShipping + "/99999999",
+			expected:   "Shipping rate not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixShipping + "/" + strconv.Itoa(rate.Id),
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+tokenShippingAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "rate not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				assert.Equal(t, provinceShipping2, data["results"].(map[string]interface{})["province"])
+				assert.Nil(t, data["results"].(map[string]interface{})["city"])
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestUpdateShipping(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	rate, _ := repo.shippingRepo.GetRateByTier(context.Background(),
+		&shippingentity.JsonCreateUpdateRateSchema{Province: provinceShipping2, MaxWeight: 1000})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		payload    map[string]interface{}
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "rate not found",
+			url:        prefixShipping + "/99999999",
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 2000, "cost": 6000},
+			expected:   "Shipping rate not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "duplicate tier",
+			url:        prefixShipping + "/" + strconv.Itoa(rate.Id),
+			payload:    map[string]interface{}{"province": provinceShipping2, "city": cityShipping2, "max_weight": 1000, "cost": 6000},
+			expected:   "The max_weight has already been taken.",
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixShipping + "/" + strconv.Itoa(rate.Id),
+			payload:    map[string]interface{}{"province": provinceShipping2, "max_weight": 2000, "cost": 6000},
+			expected:   "Successfully update the shipping rate.",
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, test.url, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+tokenShippingAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			if test.name == "success" {
+				rate, _ := repo.shippingRepo.GetRateById(context.Background(), rate.Id)
+				assert.Equal(t, 2000, rate.MaxWeight)
+				assert.Equal(t, 6000, rate.Cost)
+			}
+			assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestDeleteShipping(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	rate, _ := repo.shippingRepo.GetRateByTier(context.Background(),
+		&shippingentity.JsonCreateUpdateRateSchema{Province: provinceShipping2, MaxWeight: 2000})
+	rate2, _ := repo.shippingRepo.GetRateByTier(context.Background(),
+		&shippingentity.JsonCreateUpdateRateSchema{Province: provinceShipping2, City: cityShipping2, MaxWeight: 1000})
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "rate not found",
+			url:        prefixShipping + "/99999999",
+			expected:   "Shipping rate not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixShipping + "/" + strconv.Itoa(rate.Id),
+			expected:   "Successfully delete the shipping rate.",
+			statusCode: 200,
+		},
+		{
+			name:       "success city",
+			url:        prefixShipping + "/" + strconv.Itoa(rate2.Id),
+			expected:   "Successfully delete the shipping rate.",
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodDelete, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+tokenShippingAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestDownShipping(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	for _, email := range []string{emailShipping, emailShipping2} {
+		user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+		userConfirm, _ := repo.authRepo.GetUserConfirmByUserId(context.Background(), user.Id)
+
+		repo.authRepo.DeleteUserConfirm(context.Background(), userConfirm.Id)
+		repo.authRepo.DeleteUser(context.Background(), user.Id)
+	}
+}
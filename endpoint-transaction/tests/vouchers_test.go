@@ -0,0 +1,404 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	authentity "github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/entity/auth"
+	"github.com/creent-production/cdk-go/auth"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	prefixVoucher = "/vouchers"
+	emailVoucher  = "testtestingvoucher@test.com"
+	emailVoucher2 = "testtestingvoucher2@test.com"
+	codeVoucher   = "TESTVOUCHER"
+	codeVoucher2  = "TESTVOUCHER2"
+)
+
+var (
+	tokenVoucherAdmin = ""
+	tokenVoucherGuest = ""
+)
+
+func TestUpVoucher(t *testing.T) {
+	repo, _ := setupEnvironment()
+	cfg, _ := config.New()
+	// create admin
+	user_id := repo.authRepo.InsertUser(context.Background(), &authentity.JsonRegisterSchema{Email: emailVoucher, Password: "asdasd"})
+	repo.authRepo.InsertUserConfirm(context.Background(), user_id)
+	repo.authRepo.SetUserConfirmActivatedTrue(context.Background(), user_id)
+	repo.authRepo.SetUserRoleAdmin(context.Background(), emailVoucher)
+
+	token := auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user_id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	tokenVoucherAdmin = auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+
+	// create guest
+	user_id = repo.authRepo.InsertUser(context.Background(), &authentity.JsonRegisterSchema{Email: emailVoucher2, Password: "asdasd"})
+	repo.authRepo.InsertUserConfirm(context.Background(), user_id)
+	repo.authRepo.SetUserConfirmActivatedTrue(context.Background(), user_id)
+
+	token = auth.GenerateAccessToken(&auth.AccessToken{Sub: strconv.Itoa(user_id), Exp: jwtauth.ExpireIn(cfg.JWT.AccessExpires), Fresh: true})
+	tokenVoucherGuest = auth.NewJwtTokenRSA(cfg.JWT.PublicKey, cfg.JWT.PrivateKey, cfg.JWT.Algorithm, token)
+}
+
+func TestValidationCreateVoucher(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	now := time.Now()
+
+	tests := [...]struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{
+			name:    "required",
+			payload: map[string]interface{}{},
+		},
+		{
+			name:    "minimum",
+			payload: map[string]interface{}{"code": "a", "kind": "fixed", "amount": 0, "min_spend": -1, "started_at": now, "expired_at": now},
+		},
+		{
+			name:    "maximum",
+			payload: map[string]interface{}{"code": createMaximum(100), "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now},
+		},
+		{
+			name:    "one of",
+			payload: map[string]interface{}{"code": "asd", "kind": "a", "amount": 1, "started_at": now, "expired_at": now},
+		},
+		{
+			name:    "unique",
+			payload: map[string]interface{}{"code": "asd", "kind": "fixed", "amount": 1, "category_ids": []int{1, 1}, "started_at": now, "expired_at": now},
+		},
+		{
+			name:    "percentage",
+			payload: map[string]interface{}{"code": "asd", "kind": "percentage", "amount": 101, "started_at": now, "expired_at": now.Add(time.Hour)},
+		},
+		{
+			name:    "expired",
+			payload: map[string]interface{}{"code": "asd", "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixVoucher+"/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+tokenVoucherAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "required":
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["code"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["kind"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["amount"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["started_at"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["expired_at"].(string))
+			case "minimum":
+				assert.Equal(t, "Shorter than minimum length 3.", data["detail_message"].(map[string]interface{})["code"].(string))
+				assert.Equal(t, "Missing data for required field.", data["detail_message"].(map[string]interface{})["amount"].(string))
+				assert.Equal(t, "Must be greater than or equal to 1.", data["detail_message"].(map[string]interface{})["min_spend"].(string))
+			case "maximum":
+				assert.Equal(t, "Longer than maximum length 50.", data["detail_message"].(map[string]interface{})["code"].(string))
+			case "one of":
+				assert.Equal(t, "Must be one of: percentage, fixed.", data["detail_message"].(map[string]interface{})["kind"].(string))
+			case "unique":
+				assert.Equal(t, "Must be unique.", data["detail_message"].(map[string]interface{})["category_ids"].(string))
+			case "percentage":
+				assert.Equal(t, "Must be less than or equal to 100.", data["detail_message"].(map[string]interface{})["amount"].(string))
+			case "expired":
+				assert.Equal(t, "Must be greater than started_at.", data["detail_message"].(map[string]interface{})["expired_at"].(string))
+			}
+			assert.Equal(t, 422, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestCreateVoucher(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	now := time.Now()
+
+	tests := [...]struct {
+		name       string
+		payload    map[string]interface{}
+		expected   string
+		token      string
+		statusCode int
+	}{
+		{
+			name:       "user not found",
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "User not found.",
+			token:      tokenNotFound,
+			statusCode: 401,
+		},
+		{
+			name:       "not admin",
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "Only users with admin privileges can do this action.",
+			token:      tokenVoucherGuest,
+			statusCode: 401,
+		},
+		{
+			name:       "success",
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "percentage", "amount": 10, "max_discount": 5, "usage_limit_per_user": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "Successfully add a new voucher.",
+			token:      tokenVoucherAdmin,
+			statusCode: 201,
+		},
+		{
+			name:       "duplicate code",
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "The code has already been taken.",
+			token:      tokenVoucherAdmin,
+			statusCode: 400,
+		},
+		{
+			name:       "success scoped",
+			payload:    map[string]interface{}{"code": codeVoucher2, "kind": "fixed", "amount": 1, "product_ids": []int{1}, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "Successfully add a new voucher.",
+			token:      tokenVoucherAdmin,
+			statusCode: 201,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, prefixVoucher+"/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+test.token)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "user not found", "not admin":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_header"].(string))
+			default:
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestGetAllVoucher(t *testing.T) {
+	_, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	req, _ := http.NewRequest(http.MethodGet, prefixVoucher+"/?page=1&per_page=1&q="+codeVoucher, nil)
+	req.Header.Add("Authorization", "Bearer "+tokenVoucherAdmin)
+
+	response := executeRequest(req, s)
+
+	body, _ := io.ReadAll(response.Result().Body)
+	json.Unmarshal(body, &data)
+
+	assert.NotNil(t, data["results"].(map[string]interface{})["data"])
+	assert.Equal(t, 200, response.Result().StatusCode)
+}
+
+func TestGetVoucherById(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	voucher, _ := repo.vouchersRepo.GetVoucherByCode(context.Background(), codeVoucher)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "voucher not found",
+			url:        prefixVoucher + "/99999999",
+			expected:   "Voucher not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixVoucher + "/" + strconv.Itoa(voucher.Id),
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data map[string]interface{}
+
+			req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+tokenVoucherAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			switch test.name {
+			case "voucher not found":
+				assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			case "success":
+				assert.Equal(t, codeVoucher, data["results"].(map[string]interface{})["code"])
+			}
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestUpdateVoucher(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	voucher, _ := repo.vouchersRepo.GetVoucherByCode(context.Background(), codeVoucher)
+	now := time.Now()
+
+	tests := [...]struct {
+		name       string
+		url        string
+		payload    map[string]interface{}
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "voucher not found",
+			url:        prefixVoucher + "/99999999",
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "Voucher not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "duplicate code",
+			url:        prefixVoucher + "/" + strconv.Itoa(voucher.Id),
+			payload:    map[string]interface{}{"code": codeVoucher2, "kind": "fixed", "amount": 1, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "The code has already been taken.",
+			statusCode: 400,
+		},
+		{
+			name:       "success",
+			url:        prefixVoucher + "/" + strconv.Itoa(voucher.Id),
+			payload:    map[string]interface{}{"code": codeVoucher, "kind": "fixed", "amount": 2, "started_at": now, "expired_at": now.Add(time.Hour)},
+			expected:   "Successfully update the voucher.",
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.payload)
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, test.url, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("Authorization", "Bearer "+tokenVoucherAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ = io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			if test.name == "success" {
+				voucher, _ := repo.vouchersRepo.GetVoucherById(context.Background(), voucher.Id)
+				assert.Equal(t, 2, voucher.Amount)
+			}
+			assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestDeleteVoucher(t *testing.T) {
+	repo, s := setupEnvironment()
+
+	var data map[string]interface{}
+
+	voucher, _ := repo.vouchersRepo.GetVoucherByCode(context.Background(), codeVoucher)
+	voucher2, _ := repo.vouchersRepo.GetVoucherByCode(context.Background(), codeVoucher2)
+
+	tests := [...]struct {
+		name       string
+		url        string
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "voucher not found",
+			url:        prefixVoucher + "/99999999",
+			expected:   "Voucher not found.",
+			statusCode: 404,
+		},
+		{
+			name:       "success",
+			url:        prefixVoucher + "/" + strconv.Itoa(voucher.Id),
+			expected:   "Successfully delete the voucher.",
+			statusCode: 200,
+		},
+		{
+			name:       "success scoped",
+			url:        prefixVoucher + "/" + strconv.Itoa(voucher2.Id),
+			expected:   "Successfully delete the voucher.",
+			statusCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodDelete, test.url, nil)
+			req.Header.Add("Authorization", "Bearer "+tokenVoucherAdmin)
+
+			response := executeRequest(req, s)
+
+			body, _ := io.ReadAll(response.Result().Body)
+			json.Unmarshal(body, &data)
+
+			assert.Equal(t, test.expected, data["detail_message"].(map[string]interface{})["_app"].(string))
+			assert.Equal(t, test.statusCode, response.Result().StatusCode)
+		})
+	}
+}
+
+func TestDownVoucher(t *testing.T) {
+	repo, _ := setupEnvironment()
+
+	for _, email := range []string{emailVoucher, emailVoucher2} {
+		user, _ := repo.authRepo.GetUserByEmail(context.Background(), email)
+		userConfirm, _ := repo.authRepo.GetUserConfirmByUserId(context.Background(), user.Id)
+
+		repo.authRepo.DeleteUserConfirm(context.Background(), userConfirm.Id)
+		repo.authRepo.DeleteUser(context.Background(), user.Id)
+	}
+}
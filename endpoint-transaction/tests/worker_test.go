@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/config"
+	"github.com/IndominusByte/warung-pintar-be/endpoint-transaction/internal/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerLeader(t *testing.T) {
+	cfg, _ := config.New()
+	redisCli, _ := config.RedisConnect(cfg)
+
+	key := "test:worker:leader"
+	first := worker.NewLeader(redisCli, key, time.Minute)
+	second := worker.NewLeader(redisCli, key, time.Minute)
+
+	t.Run("first replica become the leader", func(t *testing.T) {
+		leader, err := first.Acquire()
+		assert.NoError(t, err)
+		assert.True(t, leader)
+	})
+
+	t.Run("second replica wait", func(t *testing.T) {
+		leader, err := second.Acquire()
+		assert.NoError(t, err)
+		assert.False(t, leader)
+	})
+
+	t.Run("leader keep the lock", func(t *testing.T) {
+		leader, err := first.Acquire()
+		assert.NoError(t, err)
+		assert.True(t, leader)
+	})
+
+	t.Run("second replica take over after release", func(t *testing.T) {
+		assert.NoError(t, first.Release())
+
+		leader, err := second.Acquire()
+		assert.NoError(t, err)
+		assert.True(t, leader)
+
+		leader, err = first.Acquire()
+		assert.NoError(t, err)
+		assert.False(t, leader)
+	})
+
+	second.Release()
+}
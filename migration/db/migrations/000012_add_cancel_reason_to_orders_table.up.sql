@@ -0,0 +1 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(255);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.order_status_histories;
+DROP INDEX IF EXISTS idx_transaction_order_status_histories_order_id;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS transaction.order_status_histories(
+  id SERIAL PRIMARY KEY,
+  from_status VARCHAR(20),
+  to_status VARCHAR(20) NOT NULL,
+  actor_id INT,
+  note TEXT,
+  order_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_order_status_histories_order_id ON transaction.order_status_histories(order_id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS transaction.checkouts(
+  id SERIAL PRIMARY KEY,
+  user_id INT UNIQUE NOT NULL,
+  expired_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.checkout_items;
+DROP INDEX IF EXISTS idx_transaction_checkout_items_checkout_id;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS transaction.checkout_items(
+  id SERIAL PRIMARY KEY,
+  notes VARCHAR(100),
+  qty BIGINT NOT NULL,
+  price BIGINT NOT NULL,
+  cart_id INT NOT NULL,
+  product_id INT NOT NULL,
+  checkout_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_checkout_items_checkout_id ON transaction.checkout_items(checkout_id);
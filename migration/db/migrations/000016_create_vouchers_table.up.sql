@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS transaction.vouchers(
+  id SERIAL PRIMARY KEY,
+  code VARCHAR(50) UNIQUE NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  amount BIGINT NOT NULL,
+  max_discount BIGINT NOT NULL DEFAULT 0,
+  min_spend BIGINT NOT NULL DEFAULT 0,
+  usage_limit INT NOT NULL DEFAULT 0,
+  usage_limit_per_user INT NOT NULL DEFAULT 0,
+  category_ids INT[] NOT NULL DEFAULT '{}',
+  product_ids INT[] NOT NULL DEFAULT '{}',
+  started_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  expired_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.voucher_usages;
+DROP INDEX IF EXISTS idx_transaction_voucher_usages_voucher_id_user_id;
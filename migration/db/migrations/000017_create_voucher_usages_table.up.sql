@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS transaction.voucher_usages(
+  id SERIAL PRIMARY KEY,
+  discount_amount BIGINT NOT NULL,
+  voucher_id INT NOT NULL,
+  user_id INT NOT NULL,
+  order_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_voucher_usages_voucher_id_user_id ON transaction.voucher_usages(voucher_id, user_id);
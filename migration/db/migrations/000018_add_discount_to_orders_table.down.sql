@@ -0,0 +1,2 @@
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS voucher_id;
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS discount_amount;
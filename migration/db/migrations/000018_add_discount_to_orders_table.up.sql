@@ -0,0 +1,2 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS discount_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS voucher_id INT;
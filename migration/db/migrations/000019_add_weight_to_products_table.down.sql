@@ -0,0 +1 @@
+ALTER TABLE product.products DROP COLUMN IF EXISTS weight;
@@ -0,0 +1 @@
+ALTER TABLE product.products ADD COLUMN IF NOT EXISTS weight INT NOT NULL DEFAULT 1000;
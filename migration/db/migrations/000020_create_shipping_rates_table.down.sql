@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.shipping_rates;
+DROP INDEX IF EXISTS idx_transaction_shipping_rates_province;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS transaction.shipping_rates(
+  id SERIAL PRIMARY KEY,
+  province VARCHAR(100) NOT NULL,
+  city VARCHAR(100),
+  max_weight INT NOT NULL,
+  cost BIGINT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_shipping_rates_province ON transaction.shipping_rates(lower(province));
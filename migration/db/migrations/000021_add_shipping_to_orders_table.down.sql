@@ -0,0 +1,3 @@
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS shipping_cost;
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS city;
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS province;
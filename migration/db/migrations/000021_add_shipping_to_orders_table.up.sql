@@ -0,0 +1,3 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS province VARCHAR(100);
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS city VARCHAR(100);
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS shipping_cost BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS transaction.payments(
+  id SERIAL PRIMARY KEY,
+  provider VARCHAR(50) NOT NULL,
+  method VARCHAR(50) NOT NULL,
+  bank_code VARCHAR(20),
+  external_id VARCHAR(100) UNIQUE NOT NULL,
+  amount BIGINT NOT NULL,
+  status VARCHAR(20) DEFAULT 'pending',
+  va_number VARCHAR(50),
+  qr_string TEXT,
+  expired_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  paid_at TIMESTAMP WITHOUT TIME ZONE,
+  order_id INT UNIQUE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
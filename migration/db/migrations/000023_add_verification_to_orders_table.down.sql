@@ -0,0 +1,2 @@
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS verified_at;
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS verified_by;
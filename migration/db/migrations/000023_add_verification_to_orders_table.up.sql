@@ -0,0 +1,2 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS verified_by INT;
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP WITHOUT TIME ZONE;
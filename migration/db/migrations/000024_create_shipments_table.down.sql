@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.shipments;
+DROP INDEX IF EXISTS idx_transaction_shipments_tracking_number;
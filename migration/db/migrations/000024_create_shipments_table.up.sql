@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS transaction.shipments(
+  id SERIAL PRIMARY KEY,
+  courier_code VARCHAR(20) NOT NULL,
+  tracking_number VARCHAR(100) NOT NULL,
+  shipped_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  receipt_photo VARCHAR(100),
+  order_id INT UNIQUE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_shipments_tracking_number ON transaction.shipments(courier_code, tracking_number);
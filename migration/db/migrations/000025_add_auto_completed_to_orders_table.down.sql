@@ -0,0 +1 @@
+ALTER TABLE transaction.orders DROP COLUMN IF EXISTS auto_completed;
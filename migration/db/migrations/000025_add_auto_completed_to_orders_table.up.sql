@@ -0,0 +1 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS auto_completed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.returns;
+DROP INDEX IF EXISTS idx_transaction_returns_order_id;
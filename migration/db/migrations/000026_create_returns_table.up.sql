@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS transaction.returns(
+  id SERIAL PRIMARY KEY,
+  reason VARCHAR(255) NOT NULL,
+  photo VARCHAR(100) NOT NULL,
+  status VARCHAR(20) DEFAULT 'pending',
+  reject_reason VARCHAR(255),
+  order_id INT NOT NULL,
+  user_id INT NOT NULL,
+  processed_by INT,
+  processed_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_returns_order_id ON transaction.returns(order_id);
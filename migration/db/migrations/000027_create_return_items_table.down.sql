@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction.return_items;
+DROP INDEX IF EXISTS idx_transaction_return_items_return_id;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS transaction.return_items(
+  id SERIAL PRIMARY KEY,
+  qty BIGINT NOT NULL,
+  price BIGINT NOT NULL,
+  order_item_id INT NOT NULL,
+  product_id INT NOT NULL,
+  return_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_return_items_return_id ON transaction.return_items(return_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS transaction.refunds(
+  id SERIAL PRIMARY KEY,
+  amount BIGINT NOT NULL,
+  status VARCHAR(20) DEFAULT 'pending',
+  return_id INT UNIQUE NOT NULL,
+  order_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS transaction.invoice_sequences(
+  period VARCHAR(7) PRIMARY KEY,
+  last_number INT NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
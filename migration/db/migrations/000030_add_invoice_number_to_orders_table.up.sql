@@ -0,0 +1 @@
+ALTER TABLE transaction.orders ADD COLUMN IF NOT EXISTS invoice_number VARCHAR(30) UNIQUE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transaction.email_outbox;
+DROP INDEX IF EXISTS idx_transaction_email_outbox_status_next_attempt_at;
+DROP INDEX IF EXISTS idx_transaction_email_outbox_order_id;
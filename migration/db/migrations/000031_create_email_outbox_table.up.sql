@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS transaction.email_outbox(
+  id SERIAL PRIMARY KEY,
+  event VARCHAR(50) NOT NULL,
+  order_status VARCHAR(50) NOT NULL,
+  status VARCHAR(20) DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  sent_at TIMESTAMP WITHOUT TIME ZONE,
+  order_id INT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_email_outbox_status_next_attempt_at ON transaction.email_outbox(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_transaction_email_outbox_order_id ON transaction.email_outbox(order_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS account.email_outbox;
+DROP INDEX IF EXISTS idx_account_email_outbox_status_next_attempt_at;
+DROP INDEX IF EXISTS idx_account_email_outbox_recipient;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS account.email_outbox(
+  id SERIAL PRIMARY KEY,
+  event VARCHAR(50) NOT NULL,
+  recipient VARCHAR(100) NOT NULL,
+  reference_id VARCHAR(100) NOT NULL,
+  status VARCHAR(20) DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  sent_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_email_outbox_status_next_attempt_at ON account.email_outbox(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_account_email_outbox_recipient ON account.email_outbox(recipient);
@@ -0,0 +1 @@
+ALTER TABLE account.email_outbox DROP COLUMN IF EXISTS locale;
@@ -0,0 +1 @@
+ALTER TABLE account.email_outbox ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS account.refresh_tokens;
+DROP INDEX IF EXISTS idx_account_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_account_refresh_tokens_user_id;
+DROP INDEX IF EXISTS idx_account_refresh_tokens_expired_at;
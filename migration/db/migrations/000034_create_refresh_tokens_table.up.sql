@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS account.refresh_tokens(
+  id VARCHAR(100) PRIMARY KEY,
+  family_id VARCHAR(100) NOT NULL,
+  user_id INT NOT NULL,
+  used_at TIMESTAMP WITHOUT TIME ZONE,
+  revoked BOOLEAN DEFAULT false,
+  expired_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_refresh_tokens_family_id ON account.refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_account_refresh_tokens_user_id ON account.refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_account_refresh_tokens_expired_at ON account.refresh_tokens(expired_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS account.sessions;
+DROP INDEX IF EXISTS idx_account_sessions_user_id;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS account.sessions(
+  id VARCHAR(100) DEFAULT uuid_in(overlay(overlay(md5(random()::text || ':' || clock_timestamp()::text) placing '4' from 13) placing to_hex(floor(random()*(11-8+1) + 8)::int)::text from 17)::cstring),
+  user_id INT NOT NULL,
+  device VARCHAR(255),
+  ip VARCHAR(50),
+  revoked BOOLEAN DEFAULT false,
+  last_used_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_sessions_user_id ON account.sessions(user_id);
@@ -0,0 +1 @@
+ALTER TABLE account.users DROP COLUMN IF EXISTS token_version;
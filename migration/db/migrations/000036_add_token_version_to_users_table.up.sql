@@ -0,0 +1 @@
+ALTER TABLE account.users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE account.users DROP COLUMN IF EXISTS totp_secret;
+ALTER TABLE account.users DROP COLUMN IF EXISTS totp_enabled;
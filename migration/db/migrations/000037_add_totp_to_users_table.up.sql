@@ -0,0 +1,2 @@
+ALTER TABLE account.users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(255);
+ALTER TABLE account.users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS account.recovery_codes;
+DROP INDEX IF EXISTS idx_account_recovery_codes_user_id;
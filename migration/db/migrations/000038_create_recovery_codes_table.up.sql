@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS account.recovery_codes(
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  code_hash VARCHAR(100) NOT NULL,
+  used_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_recovery_codes_user_id ON account.recovery_codes(user_id);